@@ -0,0 +1,77 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/auth"
+)
+
+// accessLog logs a line per request with its client, status, size and
+// duration. The client is the principal, followed by its tenant, or "-" if
+// the request was not authenticated.
+func accessLog(logger *log.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			entry := &logEntry{}
+			recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), logEntryContextKey{}, entry)))
+
+			client := "-"
+			if entry.principal != nil {
+				client = entry.principal.Name
+				if entry.principal.Tenant != "" {
+					client += "/" + entry.principal.Tenant
+				}
+			}
+			logger.Printf("%s %s %s %s %d %d %s", clientIP(r.RemoteAddr), client, r.Method, r.URL.RequestURI(), recorder.status, recorder.size, time.Since(start))
+		})
+	}
+}
+
+// logEntry collects details of a request for its access log line.
+type logEntry struct {
+	principal *auth.Principal
+}
+
+type logEntryContextKey struct{}
+
+// setLogPrincipal records the principal of a request for the access log.
+func setLogPrincipal(ctx context.Context, principal *auth.Principal) {
+	if entry, ok := ctx.Value(logEntryContextKey{}).(*logEntry); ok {
+		entry.principal = principal
+	}
+}
+
+// statusRecorder passes a response through and records its status and
+// size. It forwards flushes, so event streams keep working.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	size        int64
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
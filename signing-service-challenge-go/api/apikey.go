@@ -0,0 +1,79 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/service"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/validation"
+	"github.com/gorilla/mux"
+)
+
+// handleCreateAPIKey issues an API key with the requested permissions.
+func (s *Server) handleCreateAPIKey(service service.APIKeyService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		input := &validation.CreateAPIKeyInput{}
+		body, err := io.ReadAll(r.Body)
+		if err != nil || len(body) == 0 {
+			WriteErrorResponse(w, http.StatusBadRequest, errors.New("request body is required"))
+			return
+		}
+		if err := json.Unmarshal(body, input); err != nil {
+			WriteErrorResponse(w, http.StatusBadRequest, err)
+			return
+		}
+
+		output, err := service.CreateAPIKey(input)
+		if err != nil {
+			WriteErrorResponse(w, http.StatusBadRequest, err)
+			return
+		}
+
+		WriteAPIResponse(w, http.StatusCreated, output)
+	}
+}
+
+// handleListAPIKeys handles the listing of issued API keys.
+func (s *Server) handleListAPIKeys(service service.APIKeyService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		output, err := service.ListAPIKey()
+		if err != nil {
+			WriteErrorResponse(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		WriteAPIResponse(w, http.StatusOK, output)
+	}
+}
+
+// handleGetAPIKey handles the retrieval of a specific API key.
+func (s *Server) handleGetAPIKey(service service.APIKeyService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		output, err := service.GetAPIKey(&validation.GetAPIKeyInput{ID: vars["id"]})
+		if err != nil {
+			WriteErrorResponse(w, http.StatusNotFound, err)
+			return
+		}
+
+		WriteAPIResponse(w, http.StatusOK, output)
+	}
+}
+
+// handleRevokeAPIKey revokes an API key.
+func (s *Server) handleRevokeAPIKey(service service.APIKeyService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		output, err := service.RevokeAPIKey(&validation.RevokeAPIKeyInput{ID: vars["id"]})
+		if err != nil {
+			WriteErrorResponse(w, http.StatusNotFound, err)
+			return
+		}
+
+		WriteAPIResponse(w, http.StatusOK, output)
+	}
+}
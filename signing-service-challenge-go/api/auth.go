@@ -0,0 +1,287 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/auth"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/entity"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/service"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/validation"
+	signingv0 "github.com/fiskaly/coding-challenges/signing-service-challenge/proto/signing/v0"
+	"github.com/gorilla/mux"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+var (
+	// errUnauthenticated is returned for requests without a valid API key
+	// or client certificate.
+	errUnauthenticated = errors.New("missing or invalid credentials")
+	// errUnknownClient is returned for valid client certificates that are
+	// not mapped to a principal.
+	errUnknownClient = errors.New("client certificate is not authorized")
+	// errForbidden is returned if the principal lacks the permission of an
+	// operation.
+	errForbidden = errors.New("permission denied")
+	// errAdminDisabled is returned for admin requests to a service without
+	// configured API keys or client certificates.
+	errAdminDisabled = errors.New("the admin API requires configured API keys or client certificates")
+)
+
+// publicPaths are served without authentication, so load balancers and
+// clients can probe the service.
+var publicPaths = map[string]bool{
+	"/api/v0/health":       true,
+	"/api/v0/openapi.json": true,
+}
+
+// apiKeyPrincipal is the principal of requests with a configured API key.
+// Configured keys bootstrap the service: they have all permissions and can
+// issue further keys.
+var apiKeyPrincipal = &auth.Principal{Name: "api-key", Permissions: auth.Permissions}
+
+// grpcPermissions are the permissions required by the gRPC methods.
+var grpcPermissions = map[string]auth.Permission{
+	signingv0.DeviceService_CreateSignatureDevice_FullMethodName: auth.PermissionDevicesWrite,
+	signingv0.DeviceService_GetSignatureDevice_FullMethodName:    auth.PermissionDevicesRead,
+	signingv0.DeviceService_ListSignatureDevices_FullMethodName:  auth.PermissionDevicesRead,
+	signingv0.DeviceService_SignTransaction_FullMethodName:       auth.PermissionSign,
+	signingv0.DeviceService_GetTransaction_FullMethodName:        auth.PermissionTransactionsRead,
+	signingv0.DeviceService_ListTransactions_FullMethodName:      auth.PermissionTransactionsRead,
+	signingv0.DeviceService_VerifySignature_FullMethodName:       auth.PermissionDevicesRead,
+}
+
+// authenticator identifies the principal of a request by its verified
+// client certificate or its API key, sent as bearer token. API keys are
+// either configured or issued through the admin API; only the SHA-256
+// hashes of both are known. Without configured keys, client mappings and
+// issued keys every request is made by auth.Anonymous, which cannot use the
+// admin API. Keys can therefore only be issued by a configured principal.
+type authenticator struct {
+	apiKeys [][]byte
+	// clients maps the subject common names of client certificates.
+	clients map[string]*auth.Principal
+	keys    service.APIKeyService
+}
+
+// enabled reports whether requests must be authenticated. If the issued
+// keys cannot be checked, it fails closed.
+func (a *authenticator) enabled() bool {
+	if len(a.apiKeys) > 0 || len(a.clients) > 0 {
+		return true
+	}
+	issued, err := a.keys.HasAPIKeys()
+	return err != nil || issued
+}
+
+// authenticate returns the principal of a connection and its authorization
+// header. A client certificate takes precedence over an API key.
+func (a *authenticator) authenticate(state *tls.ConnectionState, authorization string) (*auth.Principal, error) {
+	if !a.enabled() {
+		return auth.Anonymous, nil
+	}
+	if state != nil && len(state.VerifiedChains) > 0 {
+		principal, ok := a.clients[state.VerifiedChains[0][0].Subject.CommonName]
+		if !ok {
+			return nil, errUnknownClient
+		}
+		return principal, nil
+	}
+	token := bearerToken(authorization)
+	if a.validKey(token) {
+		return apiKeyPrincipal, nil
+	}
+	if token != "" {
+		if key, err := a.keys.Authenticate(token); err == nil {
+			return keyPrincipal(key), nil
+		}
+	}
+	return nil, errUnauthenticated
+}
+
+// keyPrincipal returns the principal of an issued API key. It is named by
+// the key ID, which the access log records.
+func keyPrincipal(key *entity.APIKey) *auth.Principal {
+	return &auth.Principal{
+		Name:        key.ID,
+		Permissions: auth.ParsePermissions(key.Permissions),
+		DeviceIDs:   key.DeviceIDs,
+	}
+}
+
+// validKey reports whether key hashes to one of the accepted hashes. All
+// hashes are compared in constant time.
+func (a *authenticator) validKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	hash := sha256.Sum256([]byte(key))
+	valid := 0
+	for _, h := range a.apiKeys {
+		valid |= subtle.ConstantTimeCompare(hash[:], h)
+	}
+	return valid == 1
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header value.
+func bearerToken(authorization string) string {
+	scheme, token, ok := strings.Cut(authorization, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// middleware authenticates requests to non-public paths and adds their
+// principal to the request context.
+func (a *authenticator) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if publicPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		principal, err := a.authenticate(r.TLS, r.Header.Get("Authorization"))
+		if err != nil {
+			if errors.Is(err, errUnauthenticated) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="signing-service"`)
+			}
+			WriteErrorResponse(w, errorStatus(err, http.StatusUnauthorized), err)
+			return
+		}
+		setLogPrincipal(r.Context(), principal)
+		next.ServeHTTP(w, r.WithContext(auth.NewContext(r.Context(), principal)))
+	})
+}
+
+// authorize serves a request only if its principal has the permission.
+func authorize(permission auth.Permission, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal := auth.FromContext(r.Context())
+		if principal == auth.Anonymous && permission == auth.PermissionAdmin {
+			WriteErrorResponse(w, http.StatusForbidden, errAdminDisabled)
+			return
+		}
+		if principal == nil || !principal.Has(permission) {
+			WriteErrorResponse(w, http.StatusForbidden, errForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authorizeDevice is authorize for the routes of one device, identified by
+// the id route variable. Principals restricted to other devices are refused.
+func authorizeDevice(permission auth.Permission, next http.Handler) http.Handler {
+	return authorize(permission, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := checkDevice(r.Context(), mux.Vars(r)["id"]); err != nil {
+			WriteErrorResponse(w, http.StatusForbidden, err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}))
+}
+
+// checkDevice returns errForbidden unless the principal of ctx may use the
+// device.
+func checkDevice(ctx context.Context, deviceID string) error {
+	principal := auth.FromContext(ctx)
+	if principal == nil || !principal.CanAccessDevice(deviceID) {
+		return errForbidden
+	}
+	return nil
+}
+
+// allowedDevices returns the devices the principal of ctx may use.
+func allowedDevices(ctx context.Context, devices []*validation.Device) []*validation.Device {
+	principal := auth.FromContext(ctx)
+	if principal != nil && !principal.Restricted() {
+		return devices
+	}
+	allowed := make([]*validation.Device, 0, len(devices))
+	for _, device := range devices {
+		if checkDevice(ctx, device.ID) == nil {
+			allowed = append(allowed, device)
+		}
+	}
+	return allowed
+}
+
+// allowedTransactions returns the transactions of the devices the principal
+// of ctx may use.
+func allowedTransactions(ctx context.Context, transactions []*entity.Transaction) []*entity.Transaction {
+	principal := auth.FromContext(ctx)
+	if principal != nil && !principal.Restricted() {
+		return transactions
+	}
+	allowed := make([]*entity.Transaction, 0, len(transactions))
+	for _, transaction := range transactions {
+		if checkDevice(ctx, transaction.DeviceID) == nil {
+			allowed = append(allowed, transaction)
+		}
+	}
+	return allowed
+}
+
+// unaryInterceptor authenticates and authorizes gRPC calls.
+func (a *authenticator) unaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := a.authorizeCall(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// streamInterceptor is unaryInterceptor for streaming calls.
+func (a *authenticator) streamInterceptor(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.authorizeCall(stream.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &principalStream{ServerStream: stream, ctx: ctx})
+}
+
+// principalStream is a server stream whose context carries the principal.
+type principalStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *principalStream) Context() context.Context {
+	return s.ctx
+}
+
+// authorizeCall checks the client certificate or the API key in the
+// authorization metadata of a gRPC call against the permission of the
+// method. It returns the context with the principal.
+func (a *authenticator) authorizeCall(ctx context.Context, method string) (context.Context, error) {
+	var state *tls.ConnectionState
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			state = &info.State
+		}
+	}
+	var authorization string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			authorization = values[0]
+		}
+	}
+
+	principal, err := a.authenticate(state, authorization)
+	if err != nil {
+		return nil, grpcError(err, http.StatusUnauthorized)
+	}
+	permission, ok := grpcPermissions[method]
+	if !ok || !principal.Has(permission) {
+		return nil, grpcError(errForbidden, http.StatusForbidden)
+	}
+	return auth.NewContext(ctx, principal), nil
+}
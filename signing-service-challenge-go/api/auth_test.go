@@ -0,0 +1,255 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/repository"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/service"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/validation"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/events"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	signingv0 "github.com/fiskaly/coding-challenges/signing-service-challenge/proto/signing/v0"
+)
+
+const testRootKey = "root-key"
+
+// newAuthTestServer returns a server with the devices "allowed" and "other".
+func newAuthTestServer(t *testing.T, opts ...Option) (*Server, service.APIKeyService) {
+	t.Helper()
+	logger := log.New(io.Discard, "", 0)
+	repo := repository.NewRepository(persistence.NewDatabase())
+	bus := events.NewBus(16)
+	deviceSvc := service.NewDeviceService(logger, repo, service.WithEventBus(bus))
+	for _, id := range []string{"allowed", "other"} {
+		if _, err := deviceSvc.CreateSignatureDevice(&validation.CreateSignatureDeviceInput{ID: id, Algorithm: "ECC"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	apiKeySvc := service.NewAPIKeyService(repo)
+	server := NewServer("", deviceSvc, service.NewWebhookService(logger, repo, bus), apiKeySvc, bus, append([]Option{WithLogger(logger)}, opts...)...)
+	return server, apiKeySvc
+}
+
+func withRootKey() Option {
+	hash := sha256.Sum256([]byte(testRootKey))
+	return WithAPIKeys([][]byte{hash[:]})
+}
+
+// restCall sends a request with key as bearer token, if set, and returns
+// the status and body of the response.
+func restCall(t *testing.T, ts *httptest.Server, method, path, key string, body any) (int, []byte) {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, ts.URL+path, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, data
+}
+
+// issueKey issues an API key through the admin API with the root key.
+func issueKey(t *testing.T, ts *httptest.Server, input *validation.CreateAPIKeyInput) *validation.CreateAPIKeyOutput {
+	t.Helper()
+	code, data := restCall(t, ts, http.MethodPost, "/api/v0/admin/api-keys", testRootKey, input)
+	if code != http.StatusCreated {
+		t.Fatalf("issuing %s: got %d: %s", input.Name, code, data)
+	}
+	output := &validation.CreateAPIKeyOutput{}
+	if err := json.Unmarshal(data, output); err != nil {
+		t.Fatal(err)
+	}
+	return output
+}
+
+func TestAdminRequiresConfiguredAuth(t *testing.T) {
+	server, apiKeys := newAuthTestServer(t)
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	// Without configured authentication the API is open, except for the
+	// admin API.
+	if code, data := restCall(t, ts, http.MethodGet, "/api/v0/signature-device/allowed", "", nil); code != http.StatusOK {
+		t.Errorf("device without auth: got %d: %s", code, data)
+	}
+	for _, route := range []struct{ method, path string }{
+		{http.MethodPost, "/api/v0/admin/api-keys"},
+		{http.MethodGet, "/api/v0/admin/api-keys/list"},
+		{http.MethodGet, "/api/v0/admin/backup"},
+		{http.MethodPost, "/api/v0/webhooks"},
+	} {
+		code, data := restCall(t, ts, route.method, route.path, "", &validation.CreateAPIKeyInput{Name: "first", Permissions: []string{"admin"}})
+		if code != http.StatusForbidden || !bytes.Contains(data, []byte(errAdminDisabled.Error())) {
+			t.Errorf("%s %s without auth: got %d: %s", route.method, route.path, code, data)
+		}
+	}
+	if issued, err := apiKeys.HasAPIKeys(); err != nil || issued {
+		t.Errorf("keys issued without auth: %v, %v", issued, err)
+	}
+}
+
+func TestRESTAuthorization(t *testing.T) {
+	server, _ := newAuthTestServer(t, withRootKey())
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	reader := issueKey(t, ts, &validation.CreateAPIKeyInput{Name: "reader", Permissions: []string{"devices:read"}, DeviceIDs: []string{"allowed"}})
+	signer := issueKey(t, ts, &validation.CreateAPIKeyInput{Name: "signer", Permissions: []string{"sign"}})
+	admin := issueKey(t, ts, &validation.CreateAPIKeyInput{Name: "admin", Permissions: []string{"admin"}})
+	sign := &validation.SignTransactionInput{DeviceID: "other", Data: []byte("data")}
+
+	tests := []struct {
+		name         string
+		method, path string
+		key          string
+		body         any
+		want         int
+	}{
+		{"no key", http.MethodGet, "/api/v0/signature-device/allowed", "", nil, http.StatusUnauthorized},
+		{"unknown key", http.MethodGet, "/api/v0/signature-device/allowed", "ssk_unknown", nil, http.StatusUnauthorized},
+		{"health without key", http.MethodGet, "/api/v0/health", "", nil, http.StatusOK},
+		{"root key", http.MethodGet, "/api/v0/admin/api-keys/list", testRootKey, nil, http.StatusOK},
+		{"allowed device", http.MethodGet, "/api/v0/signature-device/allowed", reader.Key, nil, http.StatusOK},
+		{"device not allowed", http.MethodGet, "/api/v0/signature-device/other", reader.Key, nil, http.StatusForbidden},
+		{"missing scope", http.MethodPost, "/api/v0/sign-transaction", reader.Key, &validation.SignTransactionInput{DeviceID: "allowed", Data: []byte("data")}, http.StatusForbidden},
+		{"admin without scope", http.MethodGet, "/api/v0/admin/api-keys/list", signer.Key, nil, http.StatusForbidden},
+		{"sign", http.MethodPost, "/api/v0/sign-transaction", signer.Key, sign, http.StatusOK},
+		{"read without scope", http.MethodGet, "/api/v0/signature-device/other", signer.Key, nil, http.StatusForbidden},
+		{"issued admin key", http.MethodPost, "/api/v0/admin/api-keys", admin.Key, &validation.CreateAPIKeyInput{Name: "ops", Permissions: []string{"devices:read"}}, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, data := restCall(t, ts, tt.method, tt.path, tt.key, tt.body); code != tt.want {
+				t.Errorf("got %d, want %d: %s", code, tt.want, data)
+			}
+		})
+	}
+
+	// Listings only hold the devices of the allow-list.
+	code, data := restCall(t, ts, http.MethodGet, "/api/v0/signature-device/list", reader.Key, nil)
+	var list validation.ListSignatureDeviceOutput
+	if code != http.StatusOK || json.Unmarshal(data, &list) != nil {
+		t.Fatalf("list: got %d: %s", code, data)
+	}
+	if len(list.Device) != 1 || list.Device[0].ID != "allowed" {
+		t.Errorf("restricted key lists %+v, want only the allowed device", list.Device)
+	}
+
+	if code, _ := restCall(t, ts, http.MethodDelete, "/api/v0/admin/api-keys/"+reader.APIKey.ID, testRootKey, nil); code != http.StatusOK {
+		t.Fatalf("revoke: got %d", code)
+	}
+	if code, _ := restCall(t, ts, http.MethodGet, "/api/v0/signature-device/allowed", reader.Key, nil); code != http.StatusUnauthorized {
+		t.Errorf("revoked key: got %d, want %d", code, http.StatusUnauthorized)
+	}
+}
+
+// newGRPCClient serves the gRPC API of server with its interceptors.
+func newGRPCClient(t *testing.T, server *Server) signingv0.DeviceServiceClient {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grpcServer := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(server.authenticator.unaryInterceptor),
+		grpc.ChainStreamInterceptor(server.authenticator.streamInterceptor),
+	)
+	signingv0.RegisterDeviceServiceServer(grpcServer, &grpcDeviceServer{service: server.deviceSvc})
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return signingv0.NewDeviceServiceClient(conn)
+}
+
+func withKey(key string) context.Context {
+	if key == "" {
+		return context.Background()
+	}
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+key)
+}
+
+func TestGRPCAuthorization(t *testing.T) {
+	server, _ := newAuthTestServer(t, withRootKey())
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+	grpcClient := newGRPCClient(t, server)
+
+	reader := issueKey(t, ts, &validation.CreateAPIKeyInput{Name: "reader", Permissions: []string{"devices:read"}, DeviceIDs: []string{"allowed"}})
+	signer := issueKey(t, ts, &validation.CreateAPIKeyInput{Name: "signer", Permissions: []string{"sign"}})
+
+	getDevice := func(key, id string) error {
+		_, err := grpcClient.GetSignatureDevice(withKey(key), &signingv0.GetSignatureDeviceRequest{Id: id})
+		return err
+	}
+	signTransaction := func(key, id string) error {
+		_, err := grpcClient.SignTransaction(withKey(key), &signingv0.SignTransactionRequest{DeviceId: id, Data: []byte("data")})
+		return err
+	}
+	tests := []struct {
+		name string
+		call func() error
+		want codes.Code
+	}{
+		{"no key", func() error { return getDevice("", "allowed") }, codes.Unauthenticated},
+		{"unknown key", func() error { return getDevice("ssk_unknown", "allowed") }, codes.Unauthenticated},
+		{"root key", func() error { return getDevice(testRootKey, "other") }, codes.OK},
+		{"allowed device", func() error { return getDevice(reader.Key, "allowed") }, codes.OK},
+		{"device not allowed", func() error { return getDevice(reader.Key, "other") }, codes.PermissionDenied},
+		{"missing scope", func() error { return signTransaction(reader.Key, "allowed") }, codes.PermissionDenied},
+		{"sign", func() error { return signTransaction(signer.Key, "other") }, codes.OK},
+		{"read without scope", func() error { return getDevice(signer.Key, "other") }, codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := status.Code(tt.call()); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	list, err := grpcClient.ListSignatureDevices(withKey(reader.Key), &signingv0.ListSignatureDevicesRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.GetDevices()) != 1 || list.GetDevices()[0].GetId() != "allowed" {
+		t.Errorf("restricted key lists %v, want only the allowed device", list.GetDevices())
+	}
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/service"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/validation"
+)
+
+// ContentTypeOctetStream is the media type of encrypted backups.
+const ContentTypeOctetStream = "application/octet-stream"
+
+// handleBackup returns an encrypted snapshot of all devices, their keys and
+// transactions.
+func (s *Server) handleBackup(service service.DeviceService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		output, err := service.Backup()
+		if err != nil {
+			WriteErrorResponse(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		filename := "signing-service-" + time.Now().UTC().Format("20060102T150405Z") + ".backup"
+		w.Header().Set("Content-Type", ContentTypeOctetStream)
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+		w.WriteHeader(http.StatusOK)
+		w.Write(output.Backup)
+	}
+}
+
+// handleRestore restores an encrypted backup into the empty store.
+func (s *Server) handleRestore(service service.DeviceService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil || len(body) == 0 {
+			WriteErrorResponse(w, http.StatusBadRequest, errors.New("request body is required"))
+			return
+		}
+
+		output, err := service.Restore(&validation.RestoreInput{Backup: body})
+		if err != nil {
+			WriteErrorResponse(w, http.StatusBadRequest, err)
+			return
+		}
+
+		WriteAPIResponse(w, http.StatusOK, output)
+	}
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// ContentTypeCBOR is the media type of CBOR (RFC 8949) bodies.
+const ContentTypeCBOR = "application/cbor"
+
+// isCBOR reports whether a Content-Type header value denotes CBOR.
+func isCBOR(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == ContentTypeCBOR
+}
+
+// acceptsCBOR reports whether the client asked for a CBOR response.
+func acceptsCBOR(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			if isCBOR(strings.TrimSpace(mediaRange)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// unmarshalBody decodes a request body as CBOR or JSON depending on its Content-Type.
+func unmarshalBody(r *http.Request, body []byte, v interface{}) error {
+	if isCBOR(r.Header.Get("Content-Type")) {
+		return cbor.Unmarshal(body, v)
+	}
+	return json.Unmarshal(body, v)
+}
+
+// WriteCBORResponse writes an HTTP response with the provided status code and data encoded as CBOR.
+func WriteCBORResponse(w http.ResponseWriter, code int, data interface{}) {
+	encoded, err := cbor.Marshal(data)
+	if err != nil {
+		WriteInternalError(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", ContentTypeCBOR)
+	w.WriteHeader(code)
+	w.Write(encoded)
+}
+
+// WriteNegotiatedResponse writes data as CBOR if the client accepts it and as JSON otherwise.
+func WriteNegotiatedResponse(w http.ResponseWriter, r *http.Request, code int, data interface{}) {
+	if acceptsCBOR(r) {
+		WriteCBORResponse(w, code, data)
+		return
+	}
+	WriteAPIResponse(w, code, data)
+}
+
+// WriteNegotiatedErrorResponse writes an error as CBOR if the client accepts it and as JSON otherwise.
+func WriteNegotiatedErrorResponse(w http.ResponseWriter, r *http.Request, code int, err error) {
+	if acceptsCBOR(r) {
+		WriteCBORResponse(w, errorStatus(err, code), ErrorResponse{Errors: err.Error()})
+		return
+	}
+	WriteErrorResponse(w, code, err)
+}
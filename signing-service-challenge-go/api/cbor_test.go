@@ -0,0 +1,94 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/validation"
+	"github.com/fxamacker/cbor/v2"
+)
+
+func TestSignTransactionCBOR(t *testing.T) {
+	server, _ := newAuthTestServer(t)
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	send := func(contentType, accept string, body []byte) (int, string, []byte) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, ts.URL+"/api/v0/sign-transaction", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Accept", accept)
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, resp.Header.Get("Content-Type"), data
+	}
+	encode := func(v any) []byte {
+		t.Helper()
+		data, err := cbor.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	request := encode(map[string]any{"device_id": "allowed", "data": []byte("first"), "output": "cose"})
+	code, contentType, data := send(ContentTypeCBOR, "application/json, "+ContentTypeCBOR, request)
+	if code != http.StatusOK || contentType != ContentTypeCBOR {
+		t.Fatalf("got %d %s: %x", code, contentType, data)
+	}
+	var output validation.SignTransactionOutput
+	if err := cbor.Unmarshal(data, &output); err != nil {
+		t.Fatal(err)
+	}
+	if want := "0_first_" + base64.StdEncoding.EncodeToString([]byte("allowed")); output.SignedData != want {
+		t.Errorf("signed data is %q, want %q", output.SignedData, want)
+	}
+	if output.Transaction == "" || len(output.COSE) == 0 {
+		t.Errorf("response lacks the signature or COSE_Sign1: %+v", output)
+	}
+
+	// A CBOR request may ask for JSON and the other way round.
+	code, contentType, data = send(ContentTypeCBOR+"; charset=binary", "", encode(map[string]any{"device_id": "allowed", "data": []byte("second")}))
+	if code != http.StatusOK || contentType != "application/json" || json.Unmarshal(data, &output) != nil || !strings.HasPrefix(output.SignedData, "1_") {
+		t.Errorf("CBOR request with JSON response: got %d %s: %s", code, contentType, data)
+	}
+	code, contentType, data = send("application/json", ContentTypeCBOR, []byte(`{"device_id":"allowed","data":"dGhpcmQ="}`))
+	if code != http.StatusOK || contentType != ContentTypeCBOR || cbor.Unmarshal(data, &output) != nil || !strings.HasPrefix(output.SignedData, "2_") {
+		t.Errorf("JSON request with CBOR response: got %d %s: %x", code, contentType, data)
+	}
+
+	// Errors are CBOR encoded as well.
+	tests := []struct {
+		name string
+		body []byte
+		want int
+	}{
+		{"unknown device", encode(map[string]any{"device_id": "missing", "data": []byte("data")}), http.StatusNotFound},
+		{"malformed body", []byte{0xff}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, contentType, data := send(ContentTypeCBOR, ContentTypeCBOR, tt.body)
+			var response ErrorResponse
+			if code != tt.want || contentType != ContentTypeCBOR || cbor.Unmarshal(data, &response) != nil || response.Errors == "" {
+				t.Errorf("got %d %s: %x, want a CBOR error with status %d", code, contentType, data, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/service"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/validation"
+	"github.com/gorilla/mux"
+)
+
+// handleGetDeviceCertificate returns the certificate chain of a signature device.
+func (s *Server) handleGetDeviceCertificate(svc service.DeviceService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		input := &validation.GetDeviceCertificateInput{ID: vars["id"]}
+
+		output, err := svc.GetDeviceCertificate(input)
+		if errors.Is(err, service.ErrCertificateNotFound) {
+			WriteErrorResponse(w, http.StatusNotFound, err)
+			return
+		}
+		if err != nil {
+			WriteErrorResponse(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		WritePEMResponse(w, http.StatusOK, ContentTypePEMCertificateChain, output.Certificate)
+	}
+}
+
+// handleGetCACertificate publishes the certificate of the built-in CA.
+func (s *Server) handleGetCACertificate(svc service.DeviceService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		output, err := svc.GetCACertificate()
+		if errors.Is(err, service.ErrNoCertificateAuthority) {
+			WriteErrorResponse(w, http.StatusNotFound, err)
+			return
+		}
+		if err != nil {
+			WriteErrorResponse(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		WritePEMResponse(w, http.StatusOK, ContentTypePEMCertificateChain, output.Certificate)
+	}
+}
+
+// handleCreateCertificateRequest returns a PKCS #10 request signed with the device key.
+func (s *Server) handleCreateCertificateRequest(svc service.DeviceService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		input := &validation.CreateCertificateRequestInput{ID: vars["id"]}
+
+		output, err := svc.CreateCertificateRequest(input)
+		if err != nil {
+			WriteErrorResponse(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		WritePEMResponse(w, http.StatusCreated, ContentTypePEM, output.CertificateRequest)
+	}
+}
+
+// handleUploadDeviceCertificate stores a device certificate issued by an external CA.
+func (s *Server) handleUploadDeviceCertificate(svc service.DeviceService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			WriteErrorResponse(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		input := &validation.UploadDeviceCertificateInput{ID: vars["id"], Certificate: body}
+
+		output, err := svc.UploadDeviceCertificate(input)
+		if errors.Is(err, service.ErrNoTrustAnchors) {
+			WriteErrorResponse(w, http.StatusNotImplemented, err)
+			return
+		}
+		if err != nil {
+			WriteErrorResponse(w, http.StatusBadRequest, err)
+			return
+		}
+
+		WriteAPIResponse(w, http.StatusOK, output)
+	}
+}
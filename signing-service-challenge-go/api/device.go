@@ -2,8 +2,11 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
+	"mime"
 	"net/http"
+	"strings"
 
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/service"
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/validation"
@@ -15,13 +18,17 @@ func (s *Server) handleCreateSignatureDevice(service service.DeviceService) http
 		input := &validation.CreateSignatureDeviceInput{}
 		body, err := io.ReadAll(r.Body)
 		if err != nil || len(body) == 0 {
-			WriteErrorResponse(w, http.StatusInternalServerError, nil)
+			WriteErrorResponse(w, http.StatusBadRequest, errors.New("request body is required"))
 			return
 		}
 		if err := json.Unmarshal(body, input); err != nil {
 			WriteErrorResponse(w, http.StatusBadRequest, err)
 			return
 		}
+		if err := checkDevice(r.Context(), input.ID); err != nil {
+			WriteErrorResponse(w, http.StatusForbidden, err)
+			return
+		}
 
 		output, err := service.CreateSignatureDevice(input)
 
@@ -49,6 +56,7 @@ func (s *Server) handleListSignatureDevices(service service.DeviceService) http.
 			WriteErrorResponse(w, http.StatusInternalServerError, err)
 			return
 		}
+		devices.Device = allowedDevices(r.Context(), devices.Device)
 
 		// Write a success response
 		WriteAPIResponse(w, http.StatusOK, devices)
@@ -76,35 +84,147 @@ func (s *Server) handleGetSignatureDevices(service service.DeviceService) http.H
 	}
 }
 
+// handleSignTransaction handles the signing of transaction data. Request and
+// response bodies are JSON or CBOR, as selected by Content-Type and Accept.
 func (s *Server) handleSignTransaction(service service.DeviceService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		input := &validation.SignTransactionInput{}
 		body, err := io.ReadAll(r.Body)
 		if err != nil || len(body) == 0 {
-			WriteErrorResponse(w, http.StatusInternalServerError, nil)
+			WriteNegotiatedErrorResponse(w, r, http.StatusBadRequest, errors.New("request body is required"))
 			return
 		}
-		if err := json.Unmarshal(body, input); err != nil {
-			WriteErrorResponse(w, http.StatusBadRequest, err)
+		if err := unmarshalBody(r, body, input); err != nil {
+			WriteNegotiatedErrorResponse(w, r, http.StatusBadRequest, err)
+			return
+		}
+		if err := checkDevice(r.Context(), input.DeviceID); err != nil {
+			WriteNegotiatedErrorResponse(w, r, http.StatusForbidden, err)
 			return
 		}
 
 		output, err := service.SignTransaction(input)
 
+		if err != nil {
+			WriteNegotiatedErrorResponse(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		WriteNegotiatedResponse(w, r, http.StatusOK, output)
+	}
+}
+
+// handleSignBatched adds transaction data to the device's current batch and
+// responds once the batch root is signed. Request and response bodies are
+// JSON or CBOR, as selected by Content-Type and Accept.
+func (s *Server) handleSignBatched(service service.DeviceService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		input := &validation.SignBatchedInput{}
+		body, err := io.ReadAll(r.Body)
+		if err != nil || len(body) == 0 {
+			WriteNegotiatedErrorResponse(w, r, http.StatusBadRequest, errors.New("request body is required"))
+			return
+		}
+		if err := unmarshalBody(r, body, input); err != nil {
+			WriteNegotiatedErrorResponse(w, r, http.StatusBadRequest, err)
+			return
+		}
+		if err := checkDevice(r.Context(), input.DeviceID); err != nil {
+			WriteNegotiatedErrorResponse(w, r, http.StatusForbidden, err)
+			return
+		}
+
+		output, err := service.SignBatched(input)
+		if err != nil {
+			WriteNegotiatedErrorResponse(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		WriteNegotiatedResponse(w, r, http.StatusOK, output)
+	}
+}
+
+// handleSignDocument signs the request body with a detached CMS signature.
+// The signature is returned as DER if the client accepts
+// application/pkcs7-signature and wrapped in JSON otherwise.
+func (s *Server) handleSignDocument(service service.DeviceService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		input := &validation.SignDocumentInput{DeviceID: vars["id"], Content: r.Body}
+
+		output, err := service.SignDocument(input)
 		if err != nil {
 			WriteErrorResponse(w, http.StatusBadRequest, err)
 			return
 		}
 
+		if strings.Contains(r.Header.Get("Accept"), ContentTypePKCS7Signature) {
+			w.Header().Set("Content-Type", ContentTypePKCS7Signature)
+			w.Header().Set("X-Transaction-Id", output.TransactionID)
+			w.WriteHeader(http.StatusOK)
+			w.Write(output.CMS)
+			return
+		}
+
 		WriteAPIResponse(w, http.StatusOK, output)
 	}
 }
 
+// ContentTypeTar is the media type of device archives.
+const ContentTypeTar = "application/x-tar"
+
+// handleExportDevice streams the TAR archive of a device and its
+// transactions. Errors before the first byte are reported as usual; later
+// errors abort the response, so the client never mistakes a truncated
+// archive for a complete one.
+func (s *Server) handleExportDevice(service service.DeviceService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		archive := &archiveResponseWriter{
+			ResponseWriter: w,
+			filename:       "signature-device-" + vars["id"] + ".tar",
+		}
+		input := &validation.ExportDeviceInput{ID: vars["id"], Writer: archive}
+
+		if _, err := service.ExportDevice(input); err != nil {
+			if archive.started {
+				panic(http.ErrAbortHandler)
+			}
+			WriteErrorResponse(w, http.StatusInternalServerError, err)
+		}
+	}
+}
+
+// archiveResponseWriter sends the archive headers with the first write.
+type archiveResponseWriter struct {
+	http.ResponseWriter
+	filename string
+	started  bool
+}
+
+func (a *archiveResponseWriter) Write(p []byte) (int, error) {
+	if !a.started {
+		a.started = true
+		a.Header().Set("Content-Type", ContentTypeTar)
+		a.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.filename}))
+		a.WriteHeader(http.StatusOK)
+	}
+	return a.ResponseWriter.Write(p)
+}
+
 // handleListTransactions handles the listing of transactions.
 func (s *Server) handleListTransactions(service service.DeviceService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Extract the device ID from the URL or request parameters
 		deviceID := r.URL.Query().Get("device_id")
+		if deviceID != "" {
+			if err := checkDevice(r.Context(), deviceID); err != nil {
+				WriteErrorResponse(w, http.StatusForbidden, err)
+				return
+			}
+		}
 
 		input := &validation.ListTransactionInput{DeviceID: deviceID}
 
@@ -115,6 +235,7 @@ func (s *Server) handleListTransactions(service service.DeviceService) http.Hand
 			WriteErrorResponse(w, http.StatusInternalServerError, err)
 			return
 		}
+		transactions.Transaction = allowedTransactions(r.Context(), transactions.Transaction)
 
 		// Write a success response
 		WriteAPIResponse(w, http.StatusOK, transactions)
@@ -136,8 +257,84 @@ func (s *Server) handleGetTransaction(service service.DeviceService) http.Handle
 			WriteErrorResponse(w, http.StatusInternalServerError, err)
 			return
 		}
+		if err := checkDevice(r.Context(), transaction.Transaction.DeviceID); err != nil {
+			WriteErrorResponse(w, http.StatusForbidden, err)
+			return
+		}
 
 		// Write a success response
 		WriteAPIResponse(w, http.StatusOK, transaction)
 	}
 }
+
+// handleGetDeviceJWK returns the public key of a signature device as a JWK.
+func (s *Server) handleGetDeviceJWK(service service.DeviceService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		input := &validation.GetDeviceJWKInput{ID: vars["id"]}
+
+		output, err := service.GetDeviceJWK(input)
+		if err != nil {
+			WriteErrorResponse(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		WriteAPIResponse(w, http.StatusOK, output)
+	}
+}
+
+// handleVerifySignature checks a signature against the key of a signature device.
+func (s *Server) handleVerifySignature(service service.DeviceService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		input := &validation.VerifySignatureInput{}
+		body, err := io.ReadAll(r.Body)
+		if err != nil || len(body) == 0 {
+			WriteErrorResponse(w, http.StatusBadRequest, errors.New("request body is required"))
+			return
+		}
+		if err := json.Unmarshal(body, input); err != nil {
+			WriteErrorResponse(w, http.StatusBadRequest, err)
+			return
+		}
+		input.DeviceID = vars["id"]
+
+		output, err := service.VerifySignature(input)
+		if err != nil {
+			WriteErrorResponse(w, http.StatusBadRequest, err)
+			return
+		}
+
+		WriteAPIResponse(w, http.StatusOK, output)
+	}
+}
+
+// handleVerifyInclusion checks the inclusion proof and root signature of a
+// batched signature.
+func (s *Server) handleVerifyInclusion(service service.DeviceService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		input := &validation.VerifyInclusionInput{}
+		body, err := io.ReadAll(r.Body)
+		if err != nil || len(body) == 0 {
+			WriteErrorResponse(w, http.StatusBadRequest, errors.New("request body is required"))
+			return
+		}
+		if err := json.Unmarshal(body, input); err != nil {
+			WriteErrorResponse(w, http.StatusBadRequest, err)
+			return
+		}
+		input.DeviceID = vars["id"]
+
+		output, err := service.VerifyInclusion(input)
+		if err != nil {
+			WriteErrorResponse(w, http.StatusBadRequest, err)
+			return
+		}
+
+		WriteAPIResponse(w, http.StatusOK, output)
+	}
+}
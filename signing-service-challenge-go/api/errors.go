@@ -0,0 +1,79 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/repository"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/service"
+	"google.golang.org/grpc/codes"
+)
+
+// errorStatus classifies errors of the service layer. Known errors map to a
+// fixed HTTP status, all others keep the status chosen by the handler. The
+// gRPC API derives its status codes from the same classification.
+func errorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, repository.ErrDeviceNotFound),
+		errors.Is(err, repository.ErrTransactionNotFound),
+		errors.Is(err, repository.ErrWebhookNotFound),
+		errors.Is(err, repository.ErrDeliveryNotFound),
+		errors.Is(err, repository.ErrAPIKeyNotFound),
+		errors.Is(err, service.ErrCertificateNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, repository.ErrDeviceExists),
+		errors.Is(err, repository.ErrTransactionExists),
+		errors.Is(err, repository.ErrWebhookExists),
+		errors.Is(err, repository.ErrDeliveryExists),
+		errors.Is(err, repository.ErrAPIKeyExists),
+		errors.Is(err, repository.ErrCounterOutOfSequence),
+		errors.Is(err, service.ErrStoreNotEmpty):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrNoTrustAnchors),
+		errors.Is(err, service.ErrNoBackupKey):
+		return http.StatusNotImplemented
+	case errors.Is(err, service.ErrShuttingDown):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, errUnauthenticated):
+		return http.StatusUnauthorized
+	case errors.Is(err, errUnknownClient),
+		errors.Is(err, errForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, errRateLimited):
+		return http.StatusTooManyRequests
+	}
+	return fallback
+}
+
+// grpcCode returns the gRPC status code for an error, based on errorStatus
+// with the given HTTP status as fallback.
+func grpcCode(err error, fallback int) codes.Code {
+	switch {
+	case errors.Is(err, service.ErrBatchingDisabled),
+		errors.Is(err, service.ErrDeliveryNotFailed),
+		errors.Is(err, service.ErrNoCertificateAuthority),
+		errors.Is(err, service.ErrStoreNotEmpty):
+		return codes.FailedPrecondition
+	}
+
+	switch errorStatus(err, fallback) {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	default:
+		return codes.Internal
+	}
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/auth"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/events"
+)
+
+// ContentTypeEventStream is the media type of Server-Sent Events.
+const ContentTypeEventStream = "text/event-stream"
+
+// eventStreamHeartbeat is the interval of comments that keep idle event
+// streams open through proxies.
+const eventStreamHeartbeat = 15 * time.Second
+
+// handleEvents streams device and transaction events as Server-Sent Events.
+// The device_id and type query parameters filter the stream and accept
+// comma separated lists. Clients resume after reconnecting with the
+// Last-Event-ID header, or the last_event_id query parameter, from the
+// replay buffer of the event bus.
+func (s *Server) handleEvents(bus *events.Bus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			WriteErrorResponse(w, http.StatusInternalServerError, errors.New("streaming is not supported"))
+			return
+		}
+
+		filter := events.Filter{
+			DeviceIDs: queryList(r, "device_id"),
+			Types:     queryList(r, "type"),
+		}
+		for _, eventType := range filter.Types {
+			if !isEventType(eventType) {
+				WriteErrorResponse(w, http.StatusBadRequest, fmt.Errorf("unknown event type: %s", eventType))
+				return
+			}
+		}
+		// Principals restricted to some devices only see their events.
+		if principal := auth.FromContext(r.Context()); principal != nil && principal.Restricted() && len(filter.DeviceIDs) == 0 {
+			filter.DeviceIDs = principal.DeviceIDs
+		}
+		for _, deviceID := range filter.DeviceIDs {
+			if err := checkDevice(r.Context(), deviceID); err != nil {
+				WriteErrorResponse(w, http.StatusForbidden, err)
+				return
+			}
+		}
+
+		lastEventID := r.Header.Get("Last-Event-ID")
+		if lastEventID == "" {
+			lastEventID = r.URL.Query().Get("last_event_id")
+		}
+		var lastID uint64
+		resume := lastEventID != ""
+		if resume {
+			var err error
+			if lastID, err = strconv.ParseUint(lastEventID, 10, 64); err != nil {
+				WriteErrorResponse(w, http.StatusBadRequest, errors.New("invalid last event id"))
+				return
+			}
+		}
+
+		subscription, replay, complete := bus.Subscribe(filter, lastID, resume)
+		defer subscription.Close()
+
+		w.Header().Set("Content-Type", ContentTypeEventStream)
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+
+		if !complete {
+			fmt.Fprint(w, ": some events since the last event id are no longer available\n\n")
+		}
+		for _, event := range replay {
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(eventStreamHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-s.closing:
+				// Shutdown waits for running requests; clients reconnect
+				// and resume elsewhere.
+				return
+			case event, ok := <-subscription.Events():
+				if !ok {
+					// The client fell behind; it reconnects and resumes.
+					return
+				}
+				if err := writeEvent(w, event); err != nil {
+					return
+				}
+				flusher.Flush()
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+					return
+				}
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+// writeEvent writes an event in the text/event-stream format.
+func writeEvent(w http.ResponseWriter, event events.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
+
+// queryList returns the values of a query parameter that may be repeated
+// or hold a comma separated list.
+func queryList(r *http.Request, key string) []string {
+	var values []string
+	for _, value := range r.URL.Query()[key] {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+	return values
+}
+
+func isEventType(eventType string) bool {
+	for _, t := range events.Types {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
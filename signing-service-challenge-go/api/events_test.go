@@ -0,0 +1,105 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/validation"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/events"
+)
+
+// waitForSubscribers waits until the bus has n subscriptions.
+func waitForSubscribers(t *testing.T, bus *events.Bus, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for bus.Subscribers() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("bus has %d subscribers, want %d", bus.Subscribers(), n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// readEvent reads the next event of a text/event-stream, skipping comments.
+func readEvent(t *testing.T, stream *bufio.Reader) (id, eventType, data string) {
+	t.Helper()
+	for {
+		line, err := stream.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && eventType != "":
+			return id, eventType, data
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			eventType = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	server, _ := newAuthTestServer(t)
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/api/v0/events?device_id=allowed&type="+events.EventTransactionSigned, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != ContentTypeEventStream {
+		t.Fatalf("got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	waitForSubscribers(t, server.bus, 1)
+
+	// Events of other devices are filtered out.
+	sign := func(id string) {
+		t.Helper()
+		if _, err := server.deviceSvc.SignTransaction(&validation.SignTransactionInput{DeviceID: id, Data: []byte("data")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sign("other")
+	sign("allowed")
+
+	id, eventType, data := readEvent(t, bufio.NewReader(resp.Body))
+	var event struct {
+		ID       uint64                   `json:"id"`
+		Type     string                   `json:"type"`
+		DeviceID string                   `json:"device_id"`
+		Data     events.TransactionSigned `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(data), &event); err != nil {
+		t.Fatal(err)
+	}
+	if eventType != events.EventTransactionSigned || event.Type != eventType || event.DeviceID != "allowed" {
+		t.Errorf("got %s event of device %s, want %s of device allowed", eventType, event.DeviceID, events.EventTransactionSigned)
+	}
+	if id != "4" || event.ID != 4 {
+		t.Errorf("event id is %s, want the fourth event after two devices and a transaction", id)
+	}
+	if event.Data.TransactionID == "" || event.Data.SignatureCounter != 0 || len(event.Data.Signature) == 0 {
+		t.Errorf("event data is %+v", event.Data)
+	}
+
+	// The subscription ends with the client's connection.
+	cancel()
+	waitForSubscribers(t, server.bus, 0)
+}
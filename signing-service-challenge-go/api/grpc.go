@@ -0,0 +1,218 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"sort"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/entity"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/service"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/validation"
+	signingv0 "github.com/fiskaly/coding-challenges/signing-service-challenge/proto/signing/v0"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcTransactionPageSize is the number of transactions ListTransactions reads
+// from the repository at a time.
+const grpcTransactionPageSize = 100
+
+// grpcDeviceServer serves the gRPC DeviceService from the same service
+// instance as the REST handlers.
+type grpcDeviceServer struct {
+	signingv0.UnimplementedDeviceServiceServer
+	service service.DeviceService
+}
+
+// grpcError converts a service error into a gRPC status error. The
+// fallback is the HTTP status the REST API uses for the same operation.
+func grpcError(err error, fallback int) error {
+	return status.Error(grpcCode(err, fallback), err.Error())
+}
+
+func (g *grpcDeviceServer) CreateSignatureDevice(ctx context.Context, request *signingv0.CreateSignatureDeviceRequest) (*signingv0.SignatureDevice, error) {
+	input := &validation.CreateSignatureDeviceInput{
+		ID:              request.GetId(),
+		Algorithm:       request.GetAlgorithm(),
+		Label:           request.GetLabel(),
+		Deterministic:   request.GetDeterministic(),
+		SignatureScheme: request.GetSignatureScheme(),
+		SignatureFormat: request.GetSignatureFormat(),
+		BatchWindowMS:   int(request.GetBatchWindowMs()),
+	}
+	if err := checkDevice(ctx, input.ID); err != nil {
+		return nil, grpcError(err, http.StatusForbidden)
+	}
+	if _, err := g.service.CreateSignatureDevice(input); err != nil {
+		return nil, grpcError(err, http.StatusBadRequest)
+	}
+
+	output, err := g.service.GetSignatureDevice(&validation.GetSignatureDeviceInput{ID: input.ID})
+	if err != nil {
+		return nil, grpcError(err, http.StatusInternalServerError)
+	}
+	return toProtoDevice(output.Device), nil
+}
+
+func (g *grpcDeviceServer) GetSignatureDevice(ctx context.Context, request *signingv0.GetSignatureDeviceRequest) (*signingv0.SignatureDevice, error) {
+	if err := checkDevice(ctx, request.GetId()); err != nil {
+		return nil, grpcError(err, http.StatusForbidden)
+	}
+	output, err := g.service.GetSignatureDevice(&validation.GetSignatureDeviceInput{ID: request.GetId()})
+	if err != nil {
+		return nil, grpcError(err, http.StatusInternalServerError)
+	}
+	return toProtoDevice(output.Device), nil
+}
+
+func (g *grpcDeviceServer) ListSignatureDevices(ctx context.Context, request *signingv0.ListSignatureDevicesRequest) (*signingv0.ListSignatureDevicesResponse, error) {
+	output, err := g.service.ListSignatureDevice(&validation.ListSignatureDeviceInput{
+		ID:        request.GetId(),
+		Label:     request.GetLabel(),
+		Algorithm: request.GetAlgorithm(),
+	})
+	if err != nil {
+		return nil, grpcError(err, http.StatusInternalServerError)
+	}
+
+	response := &signingv0.ListSignatureDevicesResponse{}
+	for _, device := range allowedDevices(ctx, output.Device) {
+		response.Devices = append(response.Devices, toProtoDevice(device))
+	}
+	sort.Slice(response.Devices, func(i, j int) bool {
+		return response.Devices[i].Id < response.Devices[j].Id
+	})
+	return response, nil
+}
+
+func (g *grpcDeviceServer) SignTransaction(ctx context.Context, request *signingv0.SignTransactionRequest) (*signingv0.SignTransactionResponse, error) {
+	if err := checkDevice(ctx, request.GetDeviceId()); err != nil {
+		return nil, grpcError(err, http.StatusForbidden)
+	}
+	output, err := g.service.SignTransaction(&validation.SignTransactionInput{
+		DeviceID:        request.GetDeviceId(),
+		Data:            request.GetData(),
+		SignatureFormat: request.GetSignatureFormat(),
+		Output:          request.GetOutput(),
+	})
+	if err != nil {
+		return nil, grpcError(err, http.StatusBadRequest)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(output.Transaction)
+	if err != nil {
+		return nil, grpcError(err, http.StatusInternalServerError)
+	}
+	return &signingv0.SignTransactionResponse{
+		Signature:      signature,
+		SignedData:     output.SignedData,
+		Jws:            output.JWS,
+		Cose:           output.COSE,
+		TimestampToken: output.TimestampToken,
+	}, nil
+}
+
+func (g *grpcDeviceServer) GetTransaction(ctx context.Context, request *signingv0.GetTransactionRequest) (*signingv0.Transaction, error) {
+	output, err := g.service.GetTransaction(&validation.GetTransactionInput{ID: request.GetId()})
+	if err != nil {
+		return nil, grpcError(err, http.StatusInternalServerError)
+	}
+	if err := checkDevice(ctx, output.Transaction.DeviceID); err != nil {
+		return nil, grpcError(err, http.StatusForbidden)
+	}
+	return toProtoTransaction(output.Transaction), nil
+}
+
+func (g *grpcDeviceServer) ListTransactions(request *signingv0.ListTransactionsRequest, stream signingv0.DeviceService_ListTransactionsServer) error {
+	deviceIDs := []string{request.GetDeviceId()}
+	if request.GetDeviceId() != "" {
+		if err := checkDevice(stream.Context(), request.GetDeviceId()); err != nil {
+			return grpcError(err, http.StatusForbidden)
+		}
+	} else {
+		output, err := g.service.ListSignatureDevice(&validation.ListSignatureDeviceInput{})
+		if err != nil {
+			return grpcError(err, http.StatusInternalServerError)
+		}
+		devices := allowedDevices(stream.Context(), output.Device)
+		deviceIDs = make([]string, 0, len(devices))
+		for _, device := range devices {
+			deviceIDs = append(deviceIDs, device.ID)
+		}
+		sort.Strings(deviceIDs)
+	}
+
+	// Transactions are read and sent a page at a time, so large exports are
+	// never held in memory at once.
+	for _, deviceID := range deviceIDs {
+		for counter := 0; ; {
+			output, err := g.service.ListTransaction(&validation.ListTransactionInput{
+				DeviceID: deviceID,
+				Counter:  counter,
+				Limit:    grpcTransactionPageSize,
+			})
+			if err != nil {
+				return grpcError(err, http.StatusInternalServerError)
+			}
+			for _, transaction := range output.Transaction {
+				if err := stream.Send(toProtoTransaction(transaction)); err != nil {
+					return err
+				}
+			}
+			if len(output.Transaction) < grpcTransactionPageSize {
+				break
+			}
+			counter += len(output.Transaction)
+		}
+	}
+	return nil
+}
+
+func (g *grpcDeviceServer) VerifySignature(ctx context.Context, request *signingv0.VerifySignatureRequest) (*signingv0.VerifySignatureResponse, error) {
+	if err := checkDevice(ctx, request.GetDeviceId()); err != nil {
+		return nil, grpcError(err, http.StatusForbidden)
+	}
+	output, err := g.service.VerifySignature(&validation.VerifySignatureInput{
+		DeviceID:  request.GetDeviceId(),
+		Data:      request.GetData(),
+		Signature: request.GetSignature(),
+	})
+	if err != nil {
+		return nil, grpcError(err, http.StatusBadRequest)
+	}
+	return &signingv0.VerifySignatureResponse{Valid: output.Valid}, nil
+}
+
+func toProtoDevice(device *validation.Device) *signingv0.SignatureDevice {
+	return &signingv0.SignatureDevice{
+		Id:               device.ID,
+		Label:            device.Label,
+		Algorithm:        device.Algorithm,
+		PublicKey:        device.PublicKey,
+		Certificate:      device.Certificate,
+		Deterministic:    device.Deterministic,
+		SignatureScheme:  device.SignatureScheme,
+		SignatureFormat:  device.SignatureFormat,
+		SignatureCounter: int64(device.SignatureCounter),
+		LastSignature:    device.LastSignature,
+		BatchWindowMs:    device.BatchWindow.Milliseconds(),
+	}
+}
+
+func toProtoTransaction(transaction *entity.Transaction) *signingv0.Transaction {
+	t := &signingv0.Transaction{
+		Id:               transaction.ID,
+		DeviceId:         transaction.DeviceID,
+		SignatureCounter: int64(transaction.SignatureCounter),
+		Data:             transaction.Data,
+		LastSignatureId:  transaction.LastSignatureID,
+		Signature:        transaction.Signature,
+		SignedData:       transaction.SecuredData(),
+		TimestampToken:   transaction.TimestampToken,
+	}
+	if !transaction.SignedAt.IsZero() {
+		t.SignedAt = timestamppb.New(transaction.SignedAt)
+	}
+	return t
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+
+	"google.golang.org/grpc"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/auth"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/repository"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/service"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/validation"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	signingv0 "github.com/fiskaly/coding-challenges/signing-service-challenge/proto/signing/v0"
+)
+
+// pagingService records the transaction pages read from the device service.
+type pagingService struct {
+	service.DeviceService
+	pages []*validation.ListTransactionInput
+}
+
+func (p *pagingService) ListTransaction(input *validation.ListTransactionInput) (*validation.ListTransactionOutput, error) {
+	p.pages = append(p.pages, input)
+	return p.DeviceService.ListTransaction(input)
+}
+
+// transactionStream collects the transactions sent by ListTransactions.
+type transactionStream struct {
+	grpc.ServerStream
+	ctx          context.Context
+	transactions []*signingv0.Transaction
+}
+
+func (s *transactionStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *transactionStream) Send(transaction *signingv0.Transaction) error {
+	s.transactions = append(s.transactions, transaction)
+	return nil
+}
+
+func TestGRPCListTransactionsPages(t *testing.T) {
+	repo := repository.NewRepository(persistence.NewDatabase())
+	deviceSvc := service.NewDeviceService(log.New(io.Discard, "", 0), repo)
+	signed := map[string]int{"a": 2*grpcTransactionPageSize + 1, "b": grpcTransactionPageSize, "c": 0}
+	for id, n := range signed {
+		if _, err := deviceSvc.CreateSignatureDevice(&validation.CreateSignatureDeviceInput{ID: id, Algorithm: "ECC"}); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < n; i++ {
+			if _, err := deviceSvc.SignTransaction(&validation.SignTransactionInput{DeviceID: id, Data: []byte("data")}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	tests := []struct {
+		name      string
+		request   *signingv0.ListTransactionsRequest
+		principal *auth.Principal
+		devices   []string
+	}{
+		{"all devices", &signingv0.ListTransactionsRequest{}, auth.Anonymous, []string{"a", "b", "c"}},
+		{"one device", &signingv0.ListTransactionsRequest{DeviceId: "a"}, auth.Anonymous, []string{"a"}},
+		{"restricted", &signingv0.ListTransactionsRequest{}, &auth.Principal{Permissions: auth.Permissions, DeviceIDs: []string{"b"}}, []string{"b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &pagingService{DeviceService: deviceSvc}
+			stream := &transactionStream{ctx: auth.NewContext(context.Background(), tt.principal)}
+			if err := (&grpcDeviceServer{service: svc}).ListTransactions(tt.request, stream); err != nil {
+				t.Fatal(err)
+			}
+
+			var want []string
+			for _, id := range tt.devices {
+				for i := 0; i < signed[id]; i++ {
+					want = append(want, id)
+				}
+			}
+			if len(stream.transactions) != len(want) {
+				t.Fatalf("got %d transactions, want %d", len(stream.transactions), len(want))
+			}
+			counters := map[string]int64{}
+			for i, transaction := range stream.transactions {
+				if transaction.GetDeviceId() != want[i] || transaction.GetSignatureCounter() != counters[want[i]] {
+					t.Fatalf("transaction %d is %s/%d, want %s/%d", i, transaction.GetDeviceId(), transaction.GetSignatureCounter(), want[i], counters[want[i]])
+				}
+				counters[want[i]]++
+			}
+
+			for _, page := range svc.pages {
+				if page.DeviceID == "" || page.Limit != grpcTransactionPageSize {
+					t.Errorf("read page %+v, want a page of %d transactions of one device", page, grpcTransactionPageSize)
+				}
+			}
+		})
+	}
+}
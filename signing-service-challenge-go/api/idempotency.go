@@ -0,0 +1,207 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/auth"
+)
+
+// IdempotencyKeyHeader carries a client chosen key that makes retrying a
+// POST request safe: the first response for a key is stored and replayed
+// for every later request of the same principal with the same key.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader is set on responses replayed for a known key.
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// idempotencyKeyTTL is how long responses are kept for replay.
+const idempotencyKeyTTL = 24 * time.Hour
+
+// idempotencyMaxKeys bounds the stored responses. Beyond it the oldest are
+// dropped before they expire.
+const idempotencyMaxKeys = 10000
+
+var errIdempotencyKeyReused = errors.New("idempotency key was used for a different request")
+
+// idempotentResponse is a response stored under an idempotency key. done is
+// closed once the first request with the key has completed.
+type idempotentResponse struct {
+	key         string
+	fingerprint [sha256.Size]byte
+	done        chan struct{}
+	stored      bool
+	status      int
+	header      http.Header
+	body        []byte
+	expires     time.Time
+}
+
+// idempotencyStore keeps the responses of POST requests by principal and
+// idempotency key.
+type idempotencyStore struct {
+	mu        sync.Mutex
+	responses map[string]*idempotentResponse
+	// expiry holds the stored responses in the order they expire.
+	expiry  []*idempotentResponse
+	ttl     time.Duration
+	maxKeys int
+}
+
+func newIdempotencyStore(ttl time.Duration, maxKeys int) *idempotencyStore {
+	return &idempotencyStore{
+		responses: make(map[string]*idempotentResponse),
+		ttl:       ttl,
+		maxKeys:   maxKeys,
+	}
+}
+
+// middleware replays stored responses for POST requests carrying an
+// Idempotency-Key header. Keys are scoped to the principal of the request,
+// so it has to wrap the route's authorization. Concurrent requests with the
+// same key wait for the first one to complete. Server errors are not
+// stored, so a retry runs the request again.
+func (s *idempotencyStore) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if r.Method != http.MethodPost || key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		var scope string
+		if principal := auth.FromContext(r.Context()); principal != nil {
+			scope = principal.Name
+		}
+		key = scope + "\x00" + key
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			WriteErrorResponse(w, http.StatusBadRequest, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		fingerprint := requestFingerprint(r, body)
+
+		for {
+			entry, first := s.acquire(key, fingerprint)
+			if entry.fingerprint != fingerprint {
+				WriteErrorResponse(w, http.StatusUnprocessableEntity, errIdempotencyKeyReused)
+				return
+			}
+			if first {
+				s.record(key, entry, w, r, next)
+				return
+			}
+
+			select {
+			case <-entry.done:
+			case <-r.Context().Done():
+				return
+			}
+			if entry.stored {
+				entry.replay(w)
+				return
+			}
+			// The first request failed and was not stored; run this one.
+		}
+	})
+}
+
+// acquire returns the entry of a key, creating it if the key is unknown.
+// first reports whether the caller created the entry and has to fill it.
+func (s *idempotencyStore) acquire(key string, fingerprint [sha256.Size]byte) (entry *idempotentResponse, first bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expire(time.Now())
+	if entry, ok := s.responses[key]; ok {
+		return entry, false
+	}
+	entry = &idempotentResponse{key: key, fingerprint: fingerprint, done: make(chan struct{})}
+	s.responses[key] = entry
+	return entry, true
+}
+
+// record serves the request and stores its response under key.
+func (s *idempotencyStore) record(key string, entry *idempotentResponse, w http.ResponseWriter, r *http.Request, next http.Handler) {
+	recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+	defer func() {
+		s.mu.Lock()
+		if recorder.status < http.StatusInternalServerError {
+			entry.stored = true
+			entry.status = recorder.status
+			entry.header = w.Header().Clone()
+			entry.body = recorder.body.Bytes()
+			entry.expires = time.Now().Add(s.ttl)
+			s.expiry = append(s.expiry, entry)
+			s.expire(time.Now())
+		} else {
+			delete(s.responses, key)
+		}
+		s.mu.Unlock()
+		close(entry.done)
+	}()
+	next.ServeHTTP(recorder, r)
+}
+
+// expire drops the stored responses that expired at now, and the oldest
+// ones beyond maxKeys. The caller must hold mu.
+func (s *idempotencyStore) expire(now time.Time) {
+	for len(s.expiry) > 0 && (len(s.expiry) > s.maxKeys || now.After(s.expiry[0].expires)) {
+		entry := s.expiry[0]
+		s.expiry[0] = nil
+		s.expiry = s.expiry[1:]
+		if s.responses[entry.key] == entry {
+			delete(s.responses, entry.key)
+		}
+	}
+}
+
+func (e *idempotentResponse) replay(w http.ResponseWriter) {
+	for name, values := range e.header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(e.status)
+	w.Write(e.body)
+}
+
+// requestFingerprint identifies a request by method, path, query and body.
+func requestFingerprint(r *http.Request, body []byte) [sha256.Size]byte {
+	h := sha256.New()
+	io.WriteString(h, r.Method)
+	h.Write([]byte{0})
+	io.WriteString(h, r.URL.RequestURI())
+	h.Write([]byte{0})
+	h.Write(body)
+	var fingerprint [sha256.Size]byte
+	copy(fingerprint[:], h.Sum(nil))
+	return fingerprint
+}
+
+// responseRecorder passes a response through and keeps a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(p)
+	return r.ResponseWriter.Write(p)
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/auth"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/validation"
+)
+
+// countingHandler answers every request with the number of requests it
+// has served.
+type countingHandler struct {
+	served int
+}
+
+func (h *countingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.served++
+	fmt.Fprint(w, h.served)
+}
+
+// post sends a request with an idempotency key through handler as principal
+// and returns the response.
+func post(handler http.Handler, principal *auth.Principal, key, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/api/v0/sign-transaction", strings.NewReader(body))
+	r.Header.Set(IdempotencyKeyHeader, key)
+	if principal != nil {
+		r = r.WithContext(auth.NewContext(r.Context(), principal))
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestIdempotencyKeysArePerPrincipal(t *testing.T) {
+	next := &countingHandler{}
+	handler := newIdempotencyStore(time.Hour, 10).middleware(next)
+	alice := &auth.Principal{Name: "alice"}
+	bob := &auth.Principal{Name: "bob"}
+
+	if got := post(handler, alice, "key", "body").Body.String(); got != "1" {
+		t.Fatalf("first request: got %q", got)
+	}
+	replayed := post(handler, alice, "key", "body")
+	if replayed.Body.String() != "1" || replayed.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Errorf("retry of the same principal was not replayed: %q", replayed.Body.String())
+	}
+	if got := post(handler, bob, "key", "body"); got.Body.String() != "2" || got.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Errorf("another principal got the stored response: %q", got.Body.String())
+	}
+	if got := post(handler, alice, "key", "other body").Code; got != http.StatusUnprocessableEntity {
+		t.Errorf("reused key: got %d, want %d", got, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestIdempotencyStoreExpiry(t *testing.T) {
+	store := newIdempotencyStore(time.Hour, 2)
+	next := &countingHandler{}
+	handler := store.middleware(next)
+	for _, key := range []string{"a", "b", "c"} {
+		post(handler, nil, key, "body")
+	}
+	if len(store.responses) != 2 || len(store.expiry) != 2 {
+		t.Fatalf("store holds %d responses and %d expiries, want 2", len(store.responses), len(store.expiry))
+	}
+	// The oldest key was dropped and runs again.
+	if got := post(handler, nil, "a", "body").Body.String(); got != "4" {
+		t.Errorf("dropped key: got %q, want a new response", got)
+	}
+	if got := post(handler, nil, "c", "body").Body.String(); got != "3" {
+		t.Errorf("kept key: got %q, want the stored response", got)
+	}
+
+	store.mu.Lock()
+	store.expire(time.Now().Add(2 * time.Hour))
+	store.mu.Unlock()
+	if len(store.responses) != 0 || len(store.expiry) != 0 {
+		t.Errorf("after expiry the store holds %d responses and %d expiries", len(store.responses), len(store.expiry))
+	}
+}
+
+// TestIdempotencyAfterAuthorization checks that a stored signature is not
+// replayed to a principal the route refuses.
+func TestIdempotencyAfterAuthorization(t *testing.T) {
+	server, _ := newAuthTestServer(t, withRootKey())
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+	signer := issueKey(t, ts, &validation.CreateAPIKeyInput{Name: "signer", Permissions: []string{"sign"}})
+	reader := issueKey(t, ts, &validation.CreateAPIKeyInput{Name: "reader", Permissions: []string{"devices:read"}})
+
+	sign := func(key string) (int, string) {
+		r, err := http.NewRequest(http.MethodPost, ts.URL+"/api/v0/sign-transaction", strings.NewReader(`{"device_id":"allowed","data":"aGk="}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Header.Set("Authorization", "Bearer "+key)
+		r.Header.Set(IdempotencyKeyHeader, "shared")
+		resp, err := ts.Client().Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode, resp.Header.Get(IdempotentReplayedHeader)
+	}
+	if code, _ := sign(signer.Key); code != http.StatusOK {
+		t.Fatalf("sign: got %d", code)
+	}
+	if code, replayed := sign(reader.Key); code != http.StatusForbidden || replayed != "" {
+		t.Errorf("principal without sign permission: got %d, replayed %q", code, replayed)
+	}
+	if code, replayed := sign(testRootKey); code != http.StatusOK || replayed != "" {
+		t.Errorf("other principal: got %d, replayed %q, want a new signature", code, replayed)
+	}
+}
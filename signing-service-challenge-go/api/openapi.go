@@ -0,0 +1,19 @@
+package api
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// openAPISpec is the OpenAPI 3 description of the REST API. It has to be
+// updated together with the routes registered in Server.Run.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// handleOpenAPI serves the OpenAPI 3 description of the REST API.
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(openAPISpec)
+}
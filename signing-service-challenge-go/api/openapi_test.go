@@ -0,0 +1,403 @@
+package api_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/api"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/ca"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/repository"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/service"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/events"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+)
+
+// newTestServer serves the real API with an in-memory database, a test CA
+// and a backup key. The webhook worker runs until the test ends.
+func newTestServer(t *testing.T, opts ...api.Option) (*api.Server, *httptest.Server) {
+	t.Helper()
+	logger := log.New(io.Discard, "", 0)
+	repo := repository.NewRepository(persistence.NewDatabase())
+	bus := events.NewBus(64)
+
+	deviceSvc := service.NewDeviceService(logger, repo,
+		service.WithEventBus(bus),
+		service.WithCertificateAuthority(newTestAuthority(t)),
+		service.WithBackupKey(bytes.Repeat([]byte{7}, 32)),
+	)
+	webhookSvc := service.NewWebhookService(logger, repo, bus, service.WithWebhookPrivateTargets())
+	server := api.NewServer("", deviceSvc, webhookSvc, service.NewAPIKeyService(repo), bus, append([]api.Option{api.WithLogger(logger)}, opts...)...)
+
+	stop := make(chan struct{})
+	go webhookSvc.Run(stop)
+	ts := httptest.NewServer(server.Handler())
+	t.Cleanup(func() {
+		ts.Close()
+		close(stop)
+	})
+	return server, ts
+}
+
+func newTestAuthority(t *testing.T) *ca.Authority {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authority, err := ca.NewAuthority(
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}),
+		time.Hour,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return authority
+}
+
+// specChecker sends requests to the test server and validates requests and
+// responses against the OpenAPI document the server publishes.
+type specChecker struct {
+	t       *testing.T
+	base    string
+	doc     *openapi3.T
+	router  routers.Router
+	covered map[string]bool
+	token   string
+}
+
+func newSpecChecker(t *testing.T, base string) *specChecker {
+	for _, contentType := range []string{"application/pem-certificate-chain", "application/x-pem-file", "application/pkcs7-signature", "application/x-tar"} {
+		openapi3filter.RegisterBodyDecoder(contentType, openapi3filter.FileBodyDecoder)
+	}
+
+	resp, err := http.Get(base + "/api/v0/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := openapi3.NewLoader().LoadFromData(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		t.Fatalf("invalid spec: %v", err)
+	}
+	doc.Servers = openapi3.Servers{{URL: base}}
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &specChecker{t: t, base: base, doc: doc, router: router, covered: map[string]bool{}}
+}
+
+// call sends a request, checks that it gets the wanted status and that the
+// response matches the spec, and returns the response body.
+func (c *specChecker) call(method, path, contentType, accept string, body []byte, want int) []byte {
+	c.t.Helper()
+	req, err := http.NewRequest(method, c.base+path, bytes.NewReader(body))
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	route, params, err := c.router.FindRoute(req)
+	if err != nil {
+		c.t.Errorf("%s %s: not in spec: %v", method, path, err)
+		return nil
+	}
+	c.covered[method+" "+route.Path] = true
+	input := &openapi3filter.RequestValidationInput{
+		Request:    req,
+		PathParams: params,
+		Route:      route,
+		Options: &openapi3filter.Options{
+			ExcludeRequestBody: contentType == "application/cbor",
+			AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+		},
+	}
+	if err := openapi3filter.ValidateRequest(context.Background(), input); err != nil && want < 400 {
+		c.t.Errorf("%s %s: request does not match spec: %v", method, path, err)
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if resp.StatusCode != want {
+		c.t.Errorf("%s %s: got status %d, want %d: %s", method, path, resp.StatusCode, want, data)
+	}
+
+	output := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: input,
+		Status:                 resp.StatusCode,
+		Header:                 resp.Header,
+		Options: &openapi3filter.Options{
+			IncludeResponseStatus: true,
+			ExcludeResponseBody:   resp.Header.Get("Content-Type") == "application/cbor",
+		},
+	}
+	output.SetBodyBytes(data)
+	if err := openapi3filter.ValidateResponse(context.Background(), output); err != nil {
+		c.t.Errorf("%s %s: response does not match spec: %v", method, path, err)
+	}
+	return data
+}
+
+// uncovered returns the operations of the spec that were not called.
+func (c *specChecker) uncovered() []string {
+	var missing []string
+	for path, item := range c.doc.Paths {
+		for method := range item.Operations() {
+			if !c.covered[method+" "+path] {
+				missing = append(missing, method+" "+path)
+			}
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+func decode(t *testing.T, data []byte, v any) {
+	t.Helper()
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("decode %s: %v", data, err)
+	}
+}
+
+// TestOpenAPI calls every operation of the spec against the real server and
+// validates requests and responses against it.
+func TestOpenAPI(t *testing.T) {
+	// The admin API needs a configured key.
+	const rootKey = "root-key"
+	rootHash := sha256.Sum256([]byte(rootKey))
+	_, ts := newTestServer(t, api.WithAPIKeys([][]byte{rootHash[:]}))
+	c := newSpecChecker(t, ts.URL)
+	c.token = rootKey
+
+	const (
+		j   = "application/json"
+		dev = "3f1c1a52-4a55-4a4b-9c1e-6f7e1f0d0a01"
+		rsa = "3f1c1a52-4a55-4a4b-9c1e-6f7e1f0d0a02"
+		bat = "3f1c1a52-4a55-4a4b-9c1e-6f7e1f0d0a03"
+	)
+	b64 := base64.StdEncoding.EncodeToString
+
+	c.call("GET", "/api/v0/openapi.json", "", "", nil, 200)
+	c.call("GET", "/api/v0/health", "", "", nil, 200)
+
+	// The event stream is only checked for its status and content type
+	// here and for its first event at the end.
+	req, _ := http.NewRequest("GET", ts.URL+"/api/v0/events?type=transaction.signed", nil)
+	req.Header.Set("Authorization", "Bearer "+rootKey)
+	route, _, err := c.router.FindRoute(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.covered["GET "+route.Path] = true
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Body.Close()
+	if stream.StatusCode != 200 || stream.Header.Get("Content-Type") != "text/event-stream" {
+		t.Errorf("events: got %d %q", stream.StatusCode, stream.Header.Get("Content-Type"))
+	}
+	c.call("GET", "/api/v0/events?type=nope", "", "", nil, 400)
+	c.call("GET", "/api/v0/events?type=device.key_rotated", "", "", nil, 400)
+
+	// Devices.
+	c.call("POST", "/api/v0/signature-device", j, "", []byte(`{"id":"`+dev+`","algorithm":"ECC","label":"till"}`), 201)
+	c.call("POST", "/api/v0/signature-device", j, "", []byte(`{"id":"`+dev+`","algorithm":"ECC"}`), 409)
+	c.call("POST", "/api/v0/signature-device", j, "", []byte(`{"id":"`+rsa+`","algorithm":"RSA","signature_scheme":"PKCS1v15"}`), 201)
+	c.call("POST", "/api/v0/signature-device", j, "", []byte(`{"id":"`+bat+`","algorithm":"ECC","batch_window_ms":50}`), 201)
+	c.call("POST", "/api/v0/signature-device", j, "", []byte(`{"algorithm":"ECC"}`), 400)
+	c.call("GET", "/api/v0/signature-device/list", "", "", nil, 200)
+	c.call("GET", "/api/v0/signature-device/list?algorithm=DSA", "", "", nil, 200)
+	device := c.call("GET", "/api/v0/signature-device/"+dev, "", "", nil, 200)
+	if bytes.Contains(device, []byte("PrivateKey")) {
+		t.Errorf("device response contains the private key: %s", device)
+	}
+	c.call("GET", "/api/v0/signature-device/missing", "", "", nil, 404)
+	c.call("GET", "/api/v0/signature-device/"+rsa+"/jwk", "", "", nil, 200)
+	c.call("GET", "/api/v0/signature-device/"+dev+"/jwk", "", "", nil, 200)
+
+	// Signing.
+	var signed struct {
+		Signature  string `json:"signature"`
+		SignedData string `json:"signed_data"`
+	}
+	decode(t, c.call("POST", "/api/v0/sign-transaction", j, "", []byte(`{"device_id":"`+dev+`","data":"`+b64([]byte("hello"))+`","output":"jws"}`), 200), &signed)
+	c.call("POST", "/api/v0/sign-transaction", j, "", []byte(`{"device_id":"`+dev+`","data":"`+b64([]byte("again"))+`","output":"cose"}`), 200)
+	c.call("POST", "/api/v0/sign-transaction", "application/cbor", "application/cbor", []byte{0xa2, 0x69, 'd', 'e', 'v', 'i', 'c', 'e', '_', 'i', 'd', 0x78, byte(len(dev))}, 400)
+	c.call("POST", "/api/v0/sign-transaction", j, "", nil, 400)
+	c.call("POST", "/api/v0/sign-transaction", j, "", []byte(`{"device_id":"missing","data":"aGk="}`), 404)
+	c.call("POST", "/api/v0/sign-transaction", j, "", []byte(`{"device_id":"`+dev+`","data":"aGk=","output":"xml"}`), 400)
+	c.call("POST", "/api/v0/sign-transaction/batched", j, "", []byte(`{"device_id":"`+dev+`","data":"aGk="}`), 400)
+	var batched map[string]any
+	decode(t, c.call("POST", "/api/v0/sign-transaction/batched", j, "", []byte(`{"device_id":"`+bat+`","data":"aGk="}`), 200), &batched)
+
+	signature, err := base64.StdEncoding.DecodeString(signed.Signature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.call("POST", "/api/v0/signature-device/"+dev+"/verify", j, "", []byte(`{"data":"`+b64([]byte(signed.SignedData))+`","signature":"`+b64(signature)+`"}`), 200)
+	c.call("POST", "/api/v0/signature-device/"+dev+"/verify", j, "", []byte(`{}`), 400)
+	batched["data"] = "aGk="
+	body, _ := json.Marshal(batched)
+	c.call("POST", "/api/v0/signature-device/"+bat+"/verify-inclusion", j, "", body, 200)
+	c.call("POST", "/api/v0/signature-device/"+bat+"/verify-inclusion", j, "", []byte(`{"data":"aGk="}`), 400)
+	c.call("POST", "/api/v0/signature-device/"+dev+"/sign-document", "application/octet-stream", "", []byte("document"), 200)
+	c.call("POST", "/api/v0/signature-device/"+dev+"/sign-document", "application/octet-stream", "application/pkcs7-signature", []byte("document"), 200)
+	c.call("POST", "/api/v0/signature-device/missing/sign-document", "application/octet-stream", "", []byte("document"), 404)
+
+	// Transactions.
+	var list struct {
+		Transactions []struct{ ID string } `json:"transactions"`
+	}
+	decode(t, c.call("GET", "/api/v0/sign-transaction/list?device_id="+dev, "", "", nil, 200), &list)
+	if len(list.Transactions) == 0 {
+		t.Fatal("no transactions listed")
+	}
+	c.call("GET", "/api/v0/sign-transaction/list", "", "", nil, 200)
+	c.call("GET", "/api/v0/sign-transaction/"+list.Transactions[0].ID, "", "", nil, 200)
+	c.call("GET", "/api/v0/sign-transaction/missing", "", "", nil, 404)
+
+	// Certificates, export and backup.
+	c.call("GET", "/api/v0/ca/certificate", "", "", nil, 200)
+	c.call("GET", "/api/v0/signature-device/"+dev+"/certificate", "", "", nil, 200)
+	c.call("GET", "/api/v0/signature-device/missing/certificate", "", "", nil, 404)
+	c.call("POST", "/api/v0/signature-device/"+rsa+"/csr", "", "", nil, 201)
+	c.call("POST", "/api/v0/signature-device/missing/csr", "", "", nil, 404)
+	c.call("PUT", "/api/v0/signature-device/"+dev+"/certificate", "application/x-pem-file", "", []byte("-----BEGIN CERTIFICATE-----\n-----END CERTIFICATE-----\n"), 501)
+	c.call("GET", "/api/v0/signature-device/"+dev+"/export", "", "", nil, 200)
+	c.call("GET", "/api/v0/signature-device/missing/export", "", "", nil, 404)
+	backup := c.call("GET", "/api/v0/admin/backup", "", "", nil, 200)
+	c.call("POST", "/api/v0/admin/restore", "application/octet-stream", "", []byte("junk"), 400)
+	c.call("POST", "/api/v0/admin/restore", "application/octet-stream", "", backup, 409)
+
+	// Webhooks; nothing listens on port 1, so deliveries fail.
+	var hook struct {
+		Webhook struct{ ID string } `json:"webhook"`
+	}
+	decode(t, c.call("POST", "/api/v0/webhooks", j, "", []byte(`{"url":"http://127.0.0.1:1/hook","event_types":["transaction.signed"]}`), 201), &hook)
+	c.call("POST", "/api/v0/webhooks", j, "", []byte(`{"url":"ftp://x"}`), 400)
+	c.call("POST", "/api/v0/webhooks", j, "", []byte(`{"url":"http://127.0.0.1:1/hook","event_types":["device.deactivated"]}`), 400)
+	c.call("POST", "/api/v0/webhooks", j, "", []byte(`{"url":"http://127.0.0.1:1/all"}`), 201)
+	c.call("GET", "/api/v0/webhooks/list", "", "", nil, 200)
+	c.call("GET", "/api/v0/webhooks/"+hook.Webhook.ID, "", "", nil, 200)
+	c.call("GET", "/api/v0/webhooks/missing", "", "", nil, 404)
+	c.call("POST", "/api/v0/sign-transaction", j, "", []byte(`{"device_id":"`+dev+`","data":"aGk="}`), 200)
+	var deliveries struct {
+		Deliveries []struct{ ID string } `json:"deliveries"`
+	}
+	for deadline := time.Now().Add(5 * time.Second); len(deliveries.Deliveries) == 0; time.Sleep(20 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("no webhook delivery queued")
+		}
+		decode(t, c.call("GET", "/api/v0/webhooks/"+hook.Webhook.ID+"/deliveries", "", "", nil, 200), &deliveries)
+	}
+	c.call("GET", "/api/v0/webhooks/"+hook.Webhook.ID+"/deliveries?status=failed", "", "", nil, 200)
+	c.call("GET", "/api/v0/webhooks/"+hook.Webhook.ID+"/deliveries?status=nope", "", "", nil, 400)
+	c.call("GET", "/api/v0/webhooks/missing/deliveries", "", "", nil, 404)
+	c.call("POST", "/api/v0/webhooks/"+hook.Webhook.ID+"/deliveries/"+deliveries.Deliveries[0].ID+"/retry", "", "", nil, 400)
+	c.call("POST", "/api/v0/webhooks/"+hook.Webhook.ID+"/deliveries/missing/retry", "", "", nil, 404)
+	c.call("DELETE", "/api/v0/webhooks/"+hook.Webhook.ID, "", "", nil, 200)
+	c.call("DELETE", "/api/v0/webhooks/"+hook.Webhook.ID, "", "", nil, 404)
+
+	// API keys, issued with the configured key.
+	var issued struct {
+		APIKey struct{ ID string } `json:"api_key"`
+		Key    string              `json:"key"`
+	}
+	c.call("POST", "/api/v0/admin/api-keys", j, "", []byte(`{"name":"bad","permissions":["admin"],"device_ids":["`+dev+`"]}`), 400)
+	decode(t, c.call("POST", "/api/v0/admin/api-keys", j, "", []byte(`{"name":"ops","permissions":["admin","devices:read"]}`), 201), &issued)
+	c.token = ""
+	c.call("GET", "/api/v0/admin/api-keys/list", "", "", nil, 401)
+	c.call("GET", "/api/v0/health", "", "", nil, 200)
+	admin := issued.Key
+	c.token = admin
+	c.call("GET", "/api/v0/admin/api-keys/list", "", "", nil, 200)
+	c.call("GET", "/api/v0/admin/api-keys/"+issued.APIKey.ID, "", "", nil, 200)
+	c.call("GET", "/api/v0/admin/api-keys/missing", "", "", nil, 404)
+	decode(t, c.call("POST", "/api/v0/admin/api-keys", j, "", []byte(`{"name":"till","permissions":["devices:read"],"device_ids":["`+dev+`"]}`), 201), &issued)
+	c.token = issued.Key
+	c.call("GET", "/api/v0/signature-device/"+dev, "", "", nil, 200)
+	c.call("GET", "/api/v0/signature-device/other", "", "", nil, 403)
+	c.call("GET", "/api/v0/admin/api-keys/list", "", "", nil, 403)
+	c.token = admin
+	c.call("DELETE", "/api/v0/admin/api-keys/"+issued.APIKey.ID, "", "", nil, 200)
+	c.call("DELETE", "/api/v0/admin/api-keys/"+issued.APIKey.ID, "", "", nil, 404)
+	c.token = issued.Key
+	c.call("GET", "/api/v0/signature-device/"+dev, "", "", nil, 401)
+
+	line, err := bufio.NewReader(stream.Body).ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "id: ") {
+		t.Errorf("events: got %q, %v", line, err)
+	}
+	cancel()
+
+	if missing := c.uncovered(); len(missing) > 0 {
+		t.Errorf("operations not covered: %v", missing)
+	}
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+)
+
+// errRateLimited is returned for requests over the rate limit.
+var errRateLimited = errors.New("rate limit exceeded")
+
+// bucket is the token bucket of one client.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter limits the requests per client IP address with a token bucket
+// that holds up to burst tokens and refills at rate tokens per second.
+type rateLimiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+		swept:   time.Now(),
+	}
+}
+
+// allow takes a token from the bucket of client. If the bucket is empty it
+// returns false and the time until the next token.
+func (l *rateLimiter) allow(client string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[client] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// sweep drops the buckets that have refilled completely, at most once per
+// refill period. The caller must hold mu.
+func (l *rateLimiter) sweep(now time.Time) {
+	refill := time.Duration(l.burst / l.rate * float64(time.Second))
+	if now.Sub(l.swept) < refill {
+		return
+	}
+	l.swept = now
+	for client, b := range l.buckets {
+		if now.Sub(b.last) >= refill {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+// middleware answers requests over the limit with 429 Too Many Requests.
+func (l *rateLimiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ok, wait := l.allow(clientIP(r.RemoteAddr)); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			WriteErrorResponse(w, http.StatusTooManyRequests, errRateLimited)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// unaryInterceptor rejects gRPC calls over the limit.
+func (l *rateLimiter) unaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := l.allowCall(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// streamInterceptor is unaryInterceptor for streaming calls.
+func (l *rateLimiter) streamInterceptor(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := l.allowCall(stream.Context()); err != nil {
+		return err
+	}
+	return handler(srv, stream)
+}
+
+// allowCall takes a token for the peer of a gRPC call.
+func (l *rateLimiter) allowCall(ctx context.Context) error {
+	var client string
+	if p, ok := peer.FromContext(ctx); ok {
+		client = clientIP(p.Addr.String())
+	}
+	if ok, _ := l.allow(client); !ok {
+		return grpcError(errRateLimited, http.StatusTooManyRequests)
+	}
+	return nil
+}
+
+// clientIP returns the host part of a remote address.
+func clientIP(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
@@ -1,15 +1,24 @@
 package api
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"sync"
 
-	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/repository"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/auth"
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/service"
-	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/events"
+	signingv0 "github.com/fiskaly/coding-challenges/signing-service-challenge/proto/signing/v0"
 	"github.com/gorilla/mux"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 // Response is the generic API response container.
@@ -25,34 +34,272 @@ type ErrorResponse struct {
 // Server manages HTTP requests and dispatches them to the appropriate services.
 type Server struct {
 	listenAddress string
+	deviceSvc     service.DeviceService
+	webhookSvc    service.WebhookService
+	apiKeySvc     service.APIKeyService
+	bus           *events.Bus
+	logger        *log.Logger
+	accessLogger  *log.Logger
+	grpcAddress   string
+	tlsConfig     *tls.Config
+	authenticator *authenticator
+	rateLimiter   *rateLimiter
+
+	// closing is closed when Shutdown starts and ends event streams;
+	// stopWebhooks stops the webhook worker once requests are drained.
+	closing      chan struct{}
+	stopWebhooks chan struct{}
+
+	mu           sync.Mutex
+	shuttingDown bool
+	httpServer   *http.Server
+	grpcServer   *grpc.Server
+}
+
+// Option configures optional features of the Server.
+type Option func(*Server)
+
+// WithLogger sets the logger for errors of the server. It logs to stdout
+// by default.
+func WithLogger(logger *log.Logger) Option {
+	return func(s *Server) {
+		s.logger = logger
+	}
+}
+
+// WithAccessLog logs a line per HTTP request to logger.
+func WithAccessLog(logger *log.Logger) Option {
+	return func(s *Server) {
+		s.accessLogger = logger
+	}
+}
+
+// WithGRPCAddress serves the gRPC API on the given address next to the
+// REST API.
+func WithGRPCAddress(address string) Option {
+	return func(s *Server) {
+		s.grpcAddress = address
+	}
+}
+
+// WithTLS serves the REST and the gRPC API over TLS. The configuration
+// must provide a server certificate.
+func WithTLS(config *tls.Config) Option {
+	return func(s *Server) {
+		s.tlsConfig = config
+	}
 }
 
-// NewServer is a factory to instantiate a new Server.
-func NewServer(listenAddress string) *Server {
-	return &Server{
+// WithAPIKeys requires an API key, sent as bearer token, or a mapped client
+// certificate for all requests except health checks and the OpenAPI
+// document. Keys are given as their SHA-256 hashes and grant all
+// permissions, so they can issue scoped keys through the admin API. Without
+// configured keys or client certificates the admin API answers 403.
+func WithAPIKeys(hashes [][]byte) Option {
+	return func(s *Server) {
+		s.authenticator.apiKeys = hashes
+	}
+}
+
+// WithClientCertificates authenticates clients by the subject common name of
+// their certificate, verified by the TLS configuration, and maps them to
+// principals. Like WithAPIKeys, it requires authentication for all requests.
+func WithClientCertificates(clients map[string]*auth.Principal) Option {
+	return func(s *Server) {
+		s.authenticator.clients = clients
+	}
+}
+
+// WithRateLimit limits the requests per client IP address to rate per
+// second, allowing bursts of up to burst requests.
+func WithRateLimit(rate float64, burst int) Option {
+	return func(s *Server) {
+		s.rateLimiter = newRateLimiter(rate, burst)
+	}
+}
+
+// NewServer is a factory to instantiate a new Server serving the given
+// services. Events are streamed from bus; API keys issued by apiKeySvc
+// authenticate requests.
+func NewServer(listenAddress string, deviceSvc service.DeviceService, webhookSvc service.WebhookService, apiKeySvc service.APIKeyService, bus *events.Bus, opts ...Option) *Server {
+	s := &Server{
 		listenAddress: listenAddress,
-		// TODO: add services / further dependencies here ...
+		deviceSvc:     deviceSvc,
+		webhookSvc:    webhookSvc,
+		apiKeySvc:     apiKeySvc,
+		bus:           bus,
+		logger:        log.New(os.Stdout, "", log.LstdFlags),
+		closing:       make(chan struct{}),
+		stopWebhooks:  make(chan struct{}),
+		authenticator: &authenticator{keys: apiKeySvc},
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
-// Run registers all HandlerFuncs for the existing HTTP routes and starts the Server.
+// Run registers all HandlerFuncs for the existing HTTP routes and starts the
+// Server. After Shutdown it returns http.ErrServerClosed.
 func (s *Server) Run() error {
+	var interceptors []grpc.UnaryServerInterceptor
+	var streamInterceptors []grpc.StreamServerInterceptor
+	if s.rateLimiter != nil {
+		interceptors = append(interceptors, s.rateLimiter.unaryInterceptor)
+		streamInterceptors = append(streamInterceptors, s.rateLimiter.streamInterceptor)
+	}
+	interceptors = append(interceptors, s.authenticator.unaryInterceptor)
+	streamInterceptors = append(streamInterceptors, s.authenticator.streamInterceptor)
+	handler := s.Handler()
+
+	s.mu.Lock()
+	if s.shuttingDown {
+		s.mu.Unlock()
+		return http.ErrServerClosed
+	}
+	if s.grpcAddress != "" {
+		if err := s.startGRPC(s.deviceSvc, interceptors, streamInterceptors); err != nil {
+			s.mu.Unlock()
+			return err
+		}
+	}
+	server := &http.Server{
+		Addr:      s.listenAddress,
+		Handler:   handler,
+		TLSConfig: s.tlsConfig,
+		ErrorLog:  s.logger,
+	}
+	s.httpServer = server
+	s.mu.Unlock()
+
+	go s.webhookSvc.Run(s.stopWebhooks)
+
+	if s.tlsConfig != nil {
+		return server.ListenAndServeTLS("", "")
+	}
+	return server.ListenAndServe()
+}
+
+// Handler returns the HTTP API with all routes and middleware, without
+// listening or starting the webhook worker.
+func (s *Server) Handler() http.Handler {
 	mux := mux.NewRouter()
+	deviceSvc, webhookSvc, apiKeySvc, bus := s.deviceSvc, s.webhookSvc, s.apiKeySvc, s.bus
+
+	if s.accessLogger != nil {
+		mux.Use(accessLog(s.accessLogger))
+	}
+	if s.rateLimiter != nil {
+		mux.Use(s.rateLimiter.middleware)
+	}
+	mux.Use(s.authenticator.middleware)
+	// POST routes replay responses by idempotency key once they are
+	// authorized.
+	idempotent := newIdempotencyStore(idempotencyKeyTTL, idempotencyMaxKeys).middleware
 
-	log := log.New(os.Stdout, "[SIGNING CHALLENGE] ", log.LstdFlags)
-	db := persistence.NewDatabase()
-	repo := repository.NewRepository(db)
-	deviceSvc := service.NewDeviceService(log, repo)
 	mux.Handle("/api/v0/health", http.HandlerFunc(s.Health)).Methods(http.MethodGet)
+	mux.Handle("/api/v0/openapi.json", http.HandlerFunc(s.handleOpenAPI)).Methods(http.MethodGet)
+	mux.Handle("/api/v0/events", authorize(auth.PermissionDevicesRead, http.HandlerFunc(s.handleEvents(bus)))).Methods(http.MethodGet)
 
-	mux.Handle("/api/v0/signature-device", http.HandlerFunc(s.handleCreateSignatureDevice(deviceSvc))).Methods(http.MethodPost)
-	mux.Handle("/api/v0/signature-device/list", http.HandlerFunc(s.handleListSignatureDevices(deviceSvc))).Methods(http.MethodGet)
-	mux.Handle("/api/v0/signature-device/{id}", http.HandlerFunc(s.handleGetSignatureDevices(deviceSvc))).Methods(http.MethodGet)
-	mux.Handle("/api/v0/sign-transaction", http.HandlerFunc(s.handleSignTransaction(deviceSvc))).Methods(http.MethodPost)
-	mux.Handle("/api/v0/sign-transaction/list", http.HandlerFunc(s.handleListTransactions(deviceSvc))).Methods(http.MethodGet)
-	mux.Handle("/api/v0/sign-transaction/{id}", http.HandlerFunc(s.handleGetTransaction(deviceSvc))).Methods(http.MethodGet)
+	mux.Handle("/api/v0/signature-device", authorize(auth.PermissionDevicesWrite, idempotent(http.HandlerFunc(s.handleCreateSignatureDevice(deviceSvc))))).Methods(http.MethodPost)
+	mux.Handle("/api/v0/signature-device/list", authorize(auth.PermissionDevicesRead, http.HandlerFunc(s.handleListSignatureDevices(deviceSvc)))).Methods(http.MethodGet)
+	mux.Handle("/api/v0/signature-device/{id}", authorizeDevice(auth.PermissionDevicesRead, http.HandlerFunc(s.handleGetSignatureDevices(deviceSvc)))).Methods(http.MethodGet)
+	mux.Handle("/api/v0/signature-device/{id}/jwk", authorizeDevice(auth.PermissionDevicesRead, http.HandlerFunc(s.handleGetDeviceJWK(deviceSvc)))).Methods(http.MethodGet)
+	mux.Handle("/api/v0/signature-device/{id}/verify", authorizeDevice(auth.PermissionDevicesRead, idempotent(http.HandlerFunc(s.handleVerifySignature(deviceSvc))))).Methods(http.MethodPost)
+	mux.Handle("/api/v0/signature-device/{id}/verify-inclusion", authorizeDevice(auth.PermissionDevicesRead, idempotent(http.HandlerFunc(s.handleVerifyInclusion(deviceSvc))))).Methods(http.MethodPost)
+	mux.Handle("/api/v0/signature-device/{id}/sign-document", authorizeDevice(auth.PermissionSign, idempotent(http.HandlerFunc(s.handleSignDocument(deviceSvc))))).Methods(http.MethodPost)
+	mux.Handle("/api/v0/signature-device/{id}/export", authorizeDevice(auth.PermissionTransactionsRead, http.HandlerFunc(s.handleExportDevice(deviceSvc)))).Methods(http.MethodGet)
+	mux.Handle("/api/v0/signature-device/{id}/certificate", authorizeDevice(auth.PermissionDevicesRead, http.HandlerFunc(s.handleGetDeviceCertificate(deviceSvc)))).Methods(http.MethodGet)
+	mux.Handle("/api/v0/signature-device/{id}/certificate", authorizeDevice(auth.PermissionDevicesWrite, http.HandlerFunc(s.handleUploadDeviceCertificate(deviceSvc)))).Methods(http.MethodPut)
+	mux.Handle("/api/v0/signature-device/{id}/csr", authorizeDevice(auth.PermissionDevicesWrite, idempotent(http.HandlerFunc(s.handleCreateCertificateRequest(deviceSvc))))).Methods(http.MethodPost)
+	mux.Handle("/api/v0/ca/certificate", authorize(auth.PermissionDevicesRead, http.HandlerFunc(s.handleGetCACertificate(deviceSvc)))).Methods(http.MethodGet)
+	mux.Handle("/api/v0/admin/backup", authorize(auth.PermissionAdmin, http.HandlerFunc(s.handleBackup(deviceSvc)))).Methods(http.MethodGet)
+	mux.Handle("/api/v0/admin/restore", authorize(auth.PermissionAdmin, idempotent(http.HandlerFunc(s.handleRestore(deviceSvc))))).Methods(http.MethodPost)
+	mux.Handle("/api/v0/admin/api-keys", authorize(auth.PermissionAdmin, idempotent(http.HandlerFunc(s.handleCreateAPIKey(apiKeySvc))))).Methods(http.MethodPost)
+	mux.Handle("/api/v0/admin/api-keys/list", authorize(auth.PermissionAdmin, http.HandlerFunc(s.handleListAPIKeys(apiKeySvc)))).Methods(http.MethodGet)
+	mux.Handle("/api/v0/admin/api-keys/{id}", authorize(auth.PermissionAdmin, http.HandlerFunc(s.handleGetAPIKey(apiKeySvc)))).Methods(http.MethodGet)
+	mux.Handle("/api/v0/admin/api-keys/{id}", authorize(auth.PermissionAdmin, http.HandlerFunc(s.handleRevokeAPIKey(apiKeySvc)))).Methods(http.MethodDelete)
+	mux.Handle("/api/v0/webhooks", authorize(auth.PermissionAdmin, idempotent(http.HandlerFunc(s.handleCreateWebhook(webhookSvc))))).Methods(http.MethodPost)
+	mux.Handle("/api/v0/webhooks/list", authorize(auth.PermissionAdmin, http.HandlerFunc(s.handleListWebhooks(webhookSvc)))).Methods(http.MethodGet)
+	mux.Handle("/api/v0/webhooks/{id}", authorize(auth.PermissionAdmin, http.HandlerFunc(s.handleGetWebhook(webhookSvc)))).Methods(http.MethodGet)
+	mux.Handle("/api/v0/webhooks/{id}", authorize(auth.PermissionAdmin, http.HandlerFunc(s.handleDeleteWebhook(webhookSvc)))).Methods(http.MethodDelete)
+	mux.Handle("/api/v0/webhooks/{id}/deliveries", authorize(auth.PermissionAdmin, http.HandlerFunc(s.handleListWebhookDeliveries(webhookSvc)))).Methods(http.MethodGet)
+	mux.Handle("/api/v0/webhooks/{id}/deliveries/{delivery_id}/retry", authorize(auth.PermissionAdmin, idempotent(http.HandlerFunc(s.handleRetryWebhookDelivery(webhookSvc))))).Methods(http.MethodPost)
+	mux.Handle("/api/v0/sign-transaction", authorize(auth.PermissionSign, idempotent(http.HandlerFunc(s.handleSignTransaction(deviceSvc))))).Methods(http.MethodPost)
+	mux.Handle("/api/v0/sign-transaction/batched", authorize(auth.PermissionSign, idempotent(http.HandlerFunc(s.handleSignBatched(deviceSvc))))).Methods(http.MethodPost)
+	mux.Handle("/api/v0/sign-transaction/list", authorize(auth.PermissionTransactionsRead, http.HandlerFunc(s.handleListTransactions(deviceSvc)))).Methods(http.MethodGet)
+	mux.Handle("/api/v0/sign-transaction/{id}", authorize(auth.PermissionTransactionsRead, http.HandlerFunc(s.handleGetTransaction(deviceSvc)))).Methods(http.MethodGet)
+	return mux
+}
 
-	return http.ListenAndServe(s.listenAddress, mux)
+// startGRPC serves the gRPC API in the background. The caller must hold mu.
+func (s *Server) startGRPC(deviceSvc service.DeviceService, interceptors []grpc.UnaryServerInterceptor, streamInterceptors []grpc.StreamServerInterceptor) error {
+	listener, err := net.Listen("tcp", s.grpcAddress)
+	if err != nil {
+		return err
+	}
+	grpcOpts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(interceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+	}
+	if s.tlsConfig != nil {
+		grpcOpts = append(grpcOpts, grpc.Creds(credentials.NewTLS(s.tlsConfig)))
+	}
+	s.grpcServer = grpc.NewServer(grpcOpts...)
+	signingv0.RegisterDeviceServiceServer(s.grpcServer, &grpcDeviceServer{service: deviceSvc})
+	go func(grpcServer *grpc.Server) {
+		if err := grpcServer.Serve(listener); err != nil {
+			s.logger.Printf("gRPC server stopped: %v", err)
+		}
+	}(s.grpcServer)
+	return nil
+}
+
+// Shutdown stops the Server gracefully. New signing requests are refused
+// with 503 while the running ones complete, so no signature counter is
+// consumed without its transaction being stored. Then the listeners are
+// closed, the remaining HTTP and gRPC requests are drained and the webhook
+// worker is stopped. If ctx expires first, open connections are closed and
+// ctx's error is returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if s.shuttingDown {
+		s.mu.Unlock()
+		return nil
+	}
+	s.shuttingDown = true
+	httpServer, grpcServer := s.httpServer, s.grpcServer
+	s.mu.Unlock()
+
+	close(s.closing)
+	var errs []error
+	if err := s.deviceSvc.Drain(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("drain signing requests: %w", err))
+	}
+
+	if httpServer != nil {
+		if err := httpServer.Shutdown(ctx); err != nil {
+			httpServer.Close()
+			errs = append(errs, fmt.Errorf("shut down HTTP server: %w", err))
+		}
+	}
+
+	if grpcServer != nil {
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			grpcServer.Stop()
+			errs = append(errs, fmt.Errorf("shut down gRPC server: %w", ctx.Err()))
+		}
+	}
+
+	close(s.stopWebhooks)
+	return errors.Join(errs...)
 }
 
 // WriteInternalError writes a default internal error message as an HTTP response.
@@ -63,7 +310,9 @@ func WriteInternalError(w http.ResponseWriter) {
 
 // WriteErrorResponse takes an HTTP status code and a slice of errors
 // and writes those as an HTTP error response in a structured format.
+// Known service errors override the given status code.
 func WriteErrorResponse(w http.ResponseWriter, code int, err error) {
+	code = errorStatus(err, code)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
 
@@ -85,3 +334,19 @@ func WriteAPIResponse(w http.ResponseWriter, code int, data interface{}) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
+
+// Content types of PEM encoded responses.
+const (
+	ContentTypePEM                 = "application/x-pem-file"
+	ContentTypePEMCertificateChain = "application/pem-certificate-chain"
+)
+
+// ContentTypePKCS7Signature is the media type of detached CMS signatures.
+const ContentTypePKCS7Signature = "application/pkcs7-signature"
+
+// WritePEMResponse writes PEM encoded data as an HTTP response.
+func WritePEMResponse(w http.ResponseWriter, code int, contentType string, data []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(code)
+	w.Write(data)
+}
@@ -0,0 +1,132 @@
+package api_test
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/client"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/service"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/validation"
+)
+
+// TestShutdownDrainsSigning shuts the server down while clients keep
+// signing. Every request must either be signed and stored or be refused with
+// 503, and the signature chains must stay contiguous.
+func TestShutdownDrainsSigning(t *testing.T) {
+	server, ts := newTestServer(t)
+	c := client.NewClient(ts.URL, client.WithRetries(0))
+	ctx := context.Background()
+
+	devices := []*validation.CreateSignatureDeviceInput{
+		{ID: "device", Algorithm: "ECC"},
+		{ID: "batched", Algorithm: "ECC", BatchWindowMS: 20},
+	}
+	for _, input := range devices {
+		if _, err := c.CreateSignatureDevice(ctx, input); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// sign returns the signature of the transaction that holds the data.
+	sign := map[string]func() (string, error){
+		"device": func() (string, error) {
+			output, err := c.SignTransaction(ctx, &validation.SignTransactionInput{DeviceID: "device", Data: []byte("data")})
+			if err != nil {
+				return "", err
+			}
+			return output.Transaction, nil
+		},
+		"batched": func() (string, error) {
+			output, err := c.SignBatched(ctx, &validation.SignBatchedInput{DeviceID: "batched", Data: []byte("data")})
+			if err != nil {
+				return "", err
+			}
+			return output.Signature, nil
+		},
+	}
+
+	const workers = 8
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		signed  = map[string][]string{}
+		started = make(chan struct{}, 2*workers)
+		errs    = make(chan error, 2*workers)
+	)
+	for id, fn := range sign {
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func(id string, fn func() (string, error)) {
+				defer wg.Done()
+				for n := 0; ; n++ {
+					signature, err := fn()
+					if err == nil {
+						mu.Lock()
+						signed[id] = append(signed[id], signature)
+						mu.Unlock()
+						if n == 0 {
+							started <- struct{}{}
+						}
+						continue
+					}
+					var apiErr *client.Error
+					if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable || !errors.Is(err, service.ErrShuttingDown) {
+						errs <- err
+					}
+					return
+				}
+			}(id, fn)
+		}
+	}
+
+	for i := 0; i < 2*workers; i++ {
+		<-started
+	}
+	shutdownCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("signing during shutdown: %v", err)
+	}
+
+	if _, err := sign["device"](); !errors.Is(err, service.ErrShuttingDown) {
+		t.Errorf("signing after shutdown: got %v, want ErrShuttingDown", err)
+	}
+	for id := range sign {
+		device, err := c.GetSignatureDevice(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		transactions, err := c.ListTransaction(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(transactions.Transaction) != device.Device.SignatureCounter {
+			t.Errorf("%s: %d transactions stored, signature counter is %d", id, len(transactions.Transaction), device.Device.SignatureCounter)
+		}
+		stored := map[string]bool{}
+		for _, transaction := range transactions.Transaction {
+			stored[base64.StdEncoding.EncodeToString(transaction.Signature)] = true
+		}
+		for _, signature := range signed[id] {
+			if !stored[signature] {
+				t.Errorf("%s: signed request missing from the stored transactions", id)
+				break
+			}
+		}
+		if id == "device" && len(signed[id]) != device.Device.SignatureCounter {
+			t.Errorf("%s: signature counter is %d, %d requests were signed", id, device.Device.SignatureCounter, len(signed[id]))
+		}
+		if err := client.VerifyChain(device.Device.Entity(), transactions.Transaction); err != nil {
+			t.Errorf("%s: %v", id, err)
+		}
+	}
+}
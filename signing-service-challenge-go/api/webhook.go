@@ -0,0 +1,121 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/service"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/validation"
+	"github.com/gorilla/mux"
+)
+
+// handleCreateWebhook registers a webhook for device and transaction events.
+func (s *Server) handleCreateWebhook(service service.WebhookService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		input := &validation.CreateWebhookInput{}
+		body, err := io.ReadAll(r.Body)
+		if err != nil || len(body) == 0 {
+			WriteErrorResponse(w, http.StatusBadRequest, errors.New("request body is required"))
+			return
+		}
+		if err := json.Unmarshal(body, input); err != nil {
+			WriteErrorResponse(w, http.StatusBadRequest, err)
+			return
+		}
+
+		output, err := service.CreateWebhook(input)
+		if err != nil {
+			WriteErrorResponse(w, http.StatusBadRequest, err)
+			return
+		}
+
+		WriteAPIResponse(w, http.StatusCreated, output)
+	}
+}
+
+// handleListWebhooks handles the listing of webhooks.
+func (s *Server) handleListWebhooks(service service.WebhookService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		output, err := service.ListWebhook()
+		if err != nil {
+			WriteErrorResponse(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		WriteAPIResponse(w, http.StatusOK, output)
+	}
+}
+
+// handleGetWebhook handles the retrieval of a specific webhook.
+func (s *Server) handleGetWebhook(service service.WebhookService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		output, err := service.GetWebhook(&validation.GetWebhookInput{ID: vars["id"]})
+		if err != nil {
+			WriteErrorResponse(w, http.StatusNotFound, err)
+			return
+		}
+
+		WriteAPIResponse(w, http.StatusOK, output)
+	}
+}
+
+// handleDeleteWebhook removes a webhook and its delivery log.
+func (s *Server) handleDeleteWebhook(service service.WebhookService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		output, err := service.DeleteWebhook(&validation.DeleteWebhookInput{ID: vars["id"]})
+		if err != nil {
+			WriteErrorResponse(w, http.StatusNotFound, err)
+			return
+		}
+
+		WriteAPIResponse(w, http.StatusOK, output)
+	}
+}
+
+// handleListWebhookDeliveries returns the delivery log of a webhook. The
+// status query parameter restricts it to pending, succeeded or failed
+// deliveries.
+func (s *Server) handleListWebhookDeliveries(service service.WebhookService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		input := &validation.ListWebhookDeliveryInput{
+			WebhookID: vars["id"],
+			Status:    r.URL.Query().Get("status"),
+		}
+
+		output, err := service.ListWebhookDelivery(input)
+		if err != nil {
+			WriteErrorResponse(w, http.StatusBadRequest, err)
+			return
+		}
+
+		WriteAPIResponse(w, http.StatusOK, output)
+	}
+}
+
+// handleRetryWebhookDelivery queues a failed delivery again.
+func (s *Server) handleRetryWebhookDelivery(service service.WebhookService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		input := &validation.RetryWebhookDeliveryInput{
+			WebhookID:  vars["id"],
+			DeliveryID: vars["delivery_id"],
+		}
+
+		output, err := service.RetryWebhookDelivery(input)
+		if err != nil {
+			WriteErrorResponse(w, http.StatusBadRequest, err)
+			return
+		}
+
+		WriteAPIResponse(w, http.StatusOK, output)
+	}
+}
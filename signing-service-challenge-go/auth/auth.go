@@ -0,0 +1,112 @@
+// Package auth defines the permissions of API clients and the principal a
+// request is made by.
+package auth
+
+import "context"
+
+// Permission allows a group of API operations.
+type Permission string
+
+// Permissions of API clients.
+const (
+	PermissionDevicesWrite     Permission = "devices:write"
+	PermissionDevicesRead      Permission = "devices:read"
+	PermissionSign             Permission = "sign"
+	PermissionTransactionsRead Permission = "transactions:read"
+	PermissionAdmin            Permission = "admin"
+)
+
+// Permissions lists all permissions.
+var Permissions = []Permission{
+	PermissionDevicesWrite,
+	PermissionDevicesRead,
+	PermissionSign,
+	PermissionTransactionsRead,
+	PermissionAdmin,
+}
+
+// IsPermission reports whether name is a known permission.
+func IsPermission(name string) bool {
+	for _, permission := range Permissions {
+		if string(permission) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ParsePermissions converts permission names, which must be known.
+func ParsePermissions(names []string) []Permission {
+	permissions := make([]Permission, len(names))
+	for i, name := range names {
+		permissions[i] = Permission(name)
+	}
+	return permissions
+}
+
+// Principal is the authenticated client of a request.
+type Principal struct {
+	// Name identifies the client, e.g. the common name of its certificate.
+	Name string
+	// Tenant is the organisation the client belongs to, if known.
+	Tenant      string
+	Permissions []Permission
+	// DeviceIDs restricts the client to these signature devices. Empty
+	// allows all devices.
+	DeviceIDs []string
+}
+
+// Anonymous is the principal of requests to a service that does not
+// require authentication. It has all permissions except admin: the admin
+// API is only served to clients with a configured API key or client
+// certificate, or with keys those issued.
+var Anonymous = &Principal{
+	Name: "anonymous",
+	Permissions: []Permission{
+		PermissionDevicesWrite,
+		PermissionDevicesRead,
+		PermissionSign,
+		PermissionTransactionsRead,
+	},
+}
+
+// Has reports whether the principal has a permission.
+func (p *Principal) Has(permission Permission) bool {
+	for _, granted := range p.Permissions {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Restricted reports whether the principal is limited to some devices.
+func (p *Principal) Restricted() bool {
+	return len(p.DeviceIDs) > 0
+}
+
+// CanAccessDevice reports whether the principal may use a device.
+func (p *Principal) CanAccessDevice(id string) bool {
+	if !p.Restricted() {
+		return true
+	}
+	for _, allowed := range p.DeviceIDs {
+		if allowed == id {
+			return true
+		}
+	}
+	return false
+}
+
+type principalContextKey struct{}
+
+// NewContext returns a context carrying the principal.
+func NewContext(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+// FromContext returns the principal of a context, or nil.
+func FromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalContextKey{}).(*Principal)
+	return principal
+}
@@ -0,0 +1,141 @@
+// Package backup defines the encrypted snapshot of the complete signing
+// state: every device with its private key and its signature chain.
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/entity"
+)
+
+// SnapshotVersion is the version of the snapshot format written by Encrypt.
+const SnapshotVersion = 1
+
+// KeySize is the size of backup keys, AES-256.
+const KeySize = 32
+
+// magic starts every encrypted snapshot, followed by the format version.
+var magic = []byte("SSBK")
+
+var (
+	// ErrInvalidKey is returned for keys that are not KeySize bytes long.
+	ErrInvalidKey = fmt.Errorf("backup: key must be %d bytes", KeySize)
+	// ErrNotABackup is returned for data that is no encrypted snapshot.
+	ErrNotABackup = errors.New("backup: not a backup")
+	// ErrDecrypt is returned if a snapshot was encrypted with another key
+	// or has been modified.
+	ErrDecrypt = errors.New("backup: wrong key or corrupted backup")
+)
+
+// Snapshot is the signing state at one point in time.
+type Snapshot struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Devices   []Device  `json:"devices"`
+}
+
+// Device is a device, including its private key, with its transactions in
+// counter order.
+type Device struct {
+	Device       *entity.Device        `json:"device"`
+	Transactions []*entity.Transaction `json:"transactions"`
+}
+
+// LoadKey reads a backup key of KeySize random bytes from a file, e.g. one
+// created with "head -c 32 /dev/urandom".
+func LoadKey(filename string) ([]byte, error) {
+	key, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
+	return key, nil
+}
+
+// Encrypt compresses a snapshot and encrypts it with AES-256-GCM. The
+// result is the magic, the version byte, the nonce and the ciphertext;
+// magic and version are authenticated as well.
+func Encrypt(key []byte, snapshot *Snapshot) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var plaintext bytes.Buffer
+	compressor := gzip.NewWriter(&plaintext)
+	if err := json.NewEncoder(compressor).Encode(snapshot); err != nil {
+		return nil, err
+	}
+	if err := compressor.Close(); err != nil {
+		return nil, err
+	}
+
+	header := append(append([]byte{}, magic...), SnapshotVersion)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := append(header, nonce...)
+	return aead.Seal(out, nonce, plaintext.Bytes(), header), nil
+}
+
+// Decrypt decrypts and decodes a snapshot written by Encrypt.
+func Decrypt(key, data []byte) (*Snapshot, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	headerSize := len(magic) + 1
+	if len(data) < headerSize+aead.NonceSize() || !bytes.Equal(data[:len(magic)], magic) {
+		return nil, ErrNotABackup
+	}
+	if version := data[len(magic)]; version != SnapshotVersion {
+		return nil, fmt.Errorf("backup: unsupported version %d", version)
+	}
+	header, nonce, ciphertext := data[:headerSize], data[headerSize:headerSize+aead.NonceSize()], data[headerSize+aead.NonceSize():]
+
+	plaintext, err := aead.Open(nil, nonce, ciphertext, header)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	decompressor, err := gzip.NewReader(bytes.NewReader(plaintext))
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &Snapshot{}
+	if err := json.NewDecoder(decompressor).Decode(snapshot); err != nil {
+		return nil, fmt.Errorf("backup: decode snapshot: %w", err)
+	}
+	if _, err := io.Copy(io.Discard, decompressor); err != nil {
+		return nil, err
+	}
+	if snapshot.Version != SnapshotVersion {
+		return nil, fmt.Errorf("backup: unsupported snapshot version %d", snapshot.Version)
+	}
+	return snapshot, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,64 @@
+package backup
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/entity"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, KeySize)
+	snapshot := &Snapshot{
+		Version:   SnapshotVersion,
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+		Devices: []Device{{
+			Device:       &entity.Device{ID: "device", Algorithm: "ECC", SignatureCounter: 1, LastSignature: []byte("signature")},
+			Transactions: []*entity.Transaction{{ID: "transaction", DeviceID: "device", Signature: []byte("signature")}},
+		}},
+	}
+	data, err := Encrypt(key, snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decrypted, err := Decrypt(key, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decrypted.CreatedAt.Equal(snapshot.CreatedAt) || len(decrypted.Devices) != 1 {
+		t.Fatalf("decrypted %+v, want %+v", decrypted, snapshot)
+	}
+	device := decrypted.Devices[0]
+	if device.Device.ID != "device" || device.Device.SignatureCounter != 1 || len(device.Transactions) != 1 || !bytes.Equal(device.Transactions[0].Signature, []byte("signature")) {
+		t.Errorf("decrypted device %+v, want %+v", device, snapshot.Devices[0])
+	}
+
+	tampered := append([]byte(nil), data...)
+	tampered[len(tampered)-1] ^= 1
+	version := append([]byte(nil), data...)
+	version[len(magic)] = SnapshotVersion + 1
+	tests := []struct {
+		name string
+		key  []byte
+		data []byte
+		want error
+	}{
+		{"wrong key", bytes.Repeat([]byte{2}, KeySize), data, ErrDecrypt},
+		{"tampered", key, tampered, ErrDecrypt},
+		{"short key", key[:16], data, ErrInvalidKey},
+		{"no backup", key, []byte("junk"), ErrNotABackup},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decrypt(tt.key, tt.data); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+	if _, err := Decrypt(key, version); err == nil {
+		t.Error("decrypted a snapshot of an unsupported version")
+	}
+}
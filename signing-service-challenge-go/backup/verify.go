@@ -0,0 +1,97 @@
+package backup
+
+import (
+	"bytes"
+	gocrypto "crypto"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/export"
+)
+
+// ErrInvalidSnapshot is returned by Verify for snapshots that must not be
+// restored.
+var ErrInvalidSnapshot = errors.New("invalid backup")
+
+// Verify checks that a snapshot can be restored: device and transaction
+// IDs are unique, every private key belongs to the public key of its
+// device, and the counter and last signature of every device match its
+// transactions, whose signature chain must be intact.
+func Verify(snapshot *Snapshot) error {
+	devices := make(map[string]bool, len(snapshot.Devices))
+	transactions := map[string]bool{}
+
+	for _, d := range snapshot.Devices {
+		device := d.Device
+		if device == nil || device.ID == "" {
+			return fmt.Errorf("%w: device without ID", ErrInvalidSnapshot)
+		}
+		fail := func(format string, args ...any) error {
+			return fmt.Errorf("%w: device %s: %s", ErrInvalidSnapshot, device.ID, fmt.Sprintf(format, args...))
+		}
+
+		if devices[device.ID] {
+			return fail("duplicate device")
+		}
+		devices[device.ID] = true
+
+		if err := checkKeyPair(device.PrivateKey, device.PublicKey); err != nil {
+			return fail("private key: %v", err)
+		}
+
+		var lastSignature []byte
+		for i, transaction := range d.Transactions {
+			if transaction == nil || transaction.SignatureCounter != i {
+				return fail("transactions are not in counter order")
+			}
+			if transaction.DeviceID != device.ID {
+				return fail("transaction %s belongs to device %s", transaction.ID, transaction.DeviceID)
+			}
+			if transactions[transaction.ID] {
+				return fail("duplicate transaction %s", transaction.ID)
+			}
+			transactions[transaction.ID] = true
+			lastSignature = transaction.Signature
+		}
+		if !bytes.Equal(device.LastSignature, lastSignature) {
+			return fail("last signature does not match the last transaction")
+		}
+
+		// Verify checks counters and the signature chain.
+		report := export.Verify(export.NewBundle(device, d.Transactions))
+		if !report.Valid {
+			return fail("%s", strings.Join(reportErrors(report), "; "))
+		}
+	}
+	return nil
+}
+
+// checkKeyPair checks that a PEM private key belongs to a PEM public key.
+func checkKeyPair(privateKey, publicKey []byte) error {
+	keys, err := crypto.UnmarshalPrivateKey(privateKey)
+	if err != nil {
+		return err
+	}
+	public, err := crypto.UnmarshalPublicKey(publicKey)
+	if err != nil {
+		return err
+	}
+	if equal, ok := public.(interface{ Equal(gocrypto.PublicKey) bool }); !ok || !equal.Equal(keys.Public) {
+		return errors.New("does not match the public key")
+	}
+	return nil
+}
+
+// reportErrors lists the errors of a report, transaction errors prefixed
+// with the counter.
+func reportErrors(report *export.Report) []string {
+	errs := append([]string{}, report.Errors...)
+	for _, result := range report.Transactions {
+		for _, err := range result.Errors {
+			errs = append(errs, fmt.Sprintf("transaction %d: %s", result.SignatureCounter, err))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,131 @@
+package ca
+
+import (
+	gocrypto "crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+)
+
+// DefaultValidity is the lifetime of device certificates when none is configured.
+const DefaultValidity = 365 * 24 * time.Hour
+
+// clockSkew backdates certificates to tolerate verifiers with slightly late clocks.
+const clockSkew = 5 * time.Minute
+
+// Authority is a local certificate authority that issues an X.509
+// certificate for the public key of each signature device.
+type Authority struct {
+	certificate    *x509.Certificate
+	certificatePEM []byte
+	key            gocrypto.Signer
+	validity       time.Duration
+}
+
+// NewAuthority creates an Authority from a PEM encoded CA certificate and
+// its PEM encoded private key. Device certificates are valid for the given
+// duration, or DefaultValidity if it is zero.
+func NewAuthority(certificatePEM, privateKeyPEM []byte, validity time.Duration) (*Authority, error) {
+	certificate, err := ParseCertificate(certificatePEM)
+	if err != nil {
+		return nil, err
+	}
+	if !certificate.IsCA {
+		return nil, errors.New("ca: certificate is not a CA certificate")
+	}
+
+	keyPair, err := crypto.UnmarshalPrivateKey(privateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := keyPair.Private.(gocrypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("ca: unsupported key type %T", keyPair.Private)
+	}
+	if !publicKeysEqual(certificate.PublicKey, key.Public()) {
+		return nil, errors.New("ca: private key does not match certificate")
+	}
+
+	if validity <= 0 {
+		validity = DefaultValidity
+	}
+
+	return &Authority{
+		certificate:    certificate,
+		certificatePEM: EncodeCertificate(certificate),
+		key:            key,
+		validity:       validity,
+	}, nil
+}
+
+// LoadAuthority reads the CA certificate and private key from the given files.
+func LoadAuthority(certificateFile, privateKeyFile string, validity time.Duration) (*Authority, error) {
+	certificatePEM, err := os.ReadFile(certificateFile)
+	if err != nil {
+		return nil, err
+	}
+	privateKeyPEM, err := os.ReadFile(privateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	return NewAuthority(certificatePEM, privateKeyPEM, validity)
+}
+
+// Certificate returns the PEM encoded CA certificate.
+func (a *Authority) Certificate() []byte {
+	return a.certificatePEM
+}
+
+// Issue creates a certificate for a device's public key and returns it PEM
+// encoded, followed by the CA certificate. The subject carries the device ID
+// as common name and the label, if any, as organizational unit.
+func (a *Authority) Issue(publicKey any, deviceID, label string) ([]byte, error) {
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               deviceSubject(deviceID, label),
+		NotBefore:             now.Add(-clockSkew),
+		NotAfter:              now.Add(a.validity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, a.certificate, publicKey, a.key)
+	if err != nil {
+		return nil, err
+	}
+
+	certificate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	return append(certificate, a.certificatePEM...), nil
+}
+
+// ParseCertificate decodes the first PEM encoded certificate in data.
+func ParseCertificate(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, ErrNoCertificate
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// EncodeCertificate PEM encodes a certificate.
+func EncodeCertificate(certificate *x509.Certificate) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw})
+}
+
+func publicKeysEqual(a, b any) bool {
+	key, ok := a.(interface{ Equal(gocrypto.PublicKey) bool })
+	return ok && key.Equal(b)
+}
@@ -0,0 +1,33 @@
+package ca
+
+import (
+	gocrypto "crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+)
+
+// CreateRequest creates a PKCS #10 certificate signing request for a device,
+// signed with the device's private key, and returns it PEM encoded. The
+// subject matches the one used for certificates issued by an Authority.
+func CreateRequest(key gocrypto.Signer, deviceID, label string) ([]byte, error) {
+	template := &x509.CertificateRequest{
+		Subject: deviceSubject(deviceID, label),
+	}
+
+	der, err := x509.CreateCertificateRequest(rand.Reader, template, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}), nil
+}
+
+func deviceSubject(deviceID, label string) pkix.Name {
+	subject := pkix.Name{CommonName: deviceID}
+	if label != "" {
+		subject.OrganizationalUnit = []string{label}
+	}
+	return subject
+}
@@ -0,0 +1,91 @@
+package ca
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+)
+
+var (
+	// ErrPublicKeyMismatch is returned when a certificate does not certify the device's public key.
+	ErrPublicKeyMismatch = errors.New("ca: certificate does not match the device public key")
+	// ErrNoCertificate is returned when an uploaded chain does not contain any certificate.
+	ErrNoCertificate = errors.New("ca: no PEM encoded certificate found")
+)
+
+// TrustStore holds the trust anchors that certificates issued by an
+// external CA must chain to.
+type TrustStore struct {
+	roots *x509.CertPool
+}
+
+// NewTrustStore creates a TrustStore from a bundle of PEM encoded certificates.
+func NewTrustStore(bundlePEM []byte) (*TrustStore, error) {
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(bundlePEM) {
+		return nil, errors.New("ca: no trust anchors found")
+	}
+	return &TrustStore{roots: roots}, nil
+}
+
+// LoadTrustStore reads a bundle of PEM encoded trust anchors from a file.
+func LoadTrustStore(bundleFile string) (*TrustStore, error) {
+	bundlePEM, err := os.ReadFile(bundleFile)
+	if err != nil {
+		return nil, err
+	}
+	return NewTrustStore(bundlePEM)
+}
+
+// VerifyDeviceCertificate checks that the first certificate in chainPEM
+// certifies publicKey and chains to one of the trust anchors, using any
+// further certificates in chainPEM as intermediates. It returns the
+// verified chain PEM encoded, leaf first and without the trust anchor.
+func (t *TrustStore) VerifyDeviceCertificate(chainPEM []byte, publicKey any) ([]byte, error) {
+	var certificates []*x509.Certificate
+	for rest := chainPEM; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certificates = append(certificates, certificate)
+	}
+	if len(certificates) == 0 {
+		return nil, ErrNoCertificate
+	}
+
+	leaf := certificates[0]
+	if !publicKeysEqual(leaf.PublicKey, publicKey) {
+		return nil, ErrPublicKeyMismatch
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, certificate := range certificates[1:] {
+		intermediates.AddCert(certificate)
+	}
+
+	chains, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         t.roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var verified []byte
+	chain := chains[0]
+	for _, certificate := range chain[:len(chain)-1] {
+		verified = append(verified, EncodeCertificate(certificate)...)
+	}
+	return verified, nil
+}
@@ -0,0 +1,45 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/validation"
+)
+
+// CreateAPIKey issues an API key. The returned key is not returned again;
+// the service only keeps its hash.
+func (c *Client) CreateAPIKey(ctx context.Context, input *validation.CreateAPIKeyInput) (*validation.CreateAPIKeyOutput, error) {
+	output := &validation.CreateAPIKeyOutput{}
+	if err := c.sendJSON(ctx, http.MethodPost, "/api/v0/admin/api-keys", input, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// ListAPIKey lists all issued API keys.
+func (c *Client) ListAPIKey(ctx context.Context) (*validation.ListAPIKeyOutput, error) {
+	output := &validation.ListAPIKeyOutput{}
+	if err := c.getJSON(ctx, "/api/v0/admin/api-keys/list", nil, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// GetAPIKey returns an issued API key.
+func (c *Client) GetAPIKey(ctx context.Context, id string) (*validation.GetAPIKeyOutput, error) {
+	output := &validation.GetAPIKeyOutput{}
+	if err := c.getJSON(ctx, "/api/v0/admin/api-keys/"+escape(id), nil, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// RevokeAPIKey revokes an API key.
+func (c *Client) RevokeAPIKey(ctx context.Context, id string) (*validation.RevokeAPIKeyOutput, error) {
+	output := &validation.RevokeAPIKeyOutput{}
+	if err := c.sendJSON(ctx, http.MethodDelete, "/api/v0/admin/api-keys/"+escape(id), nil, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
@@ -0,0 +1,37 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/validation"
+)
+
+// Backup returns an encrypted snapshot of all devices, their keys and
+// transactions. Only the service can decrypt it.
+func (c *Client) Backup(ctx context.Context) ([]byte, error) {
+	resp, err := c.do(ctx, request{
+		method: http.MethodGet,
+		path:   "/api/v0/admin/backup",
+		accept: contentTypeOctetStream,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.body, nil
+}
+
+// Restore restores an encrypted backup into a service without devices.
+func (c *Client) Restore(ctx context.Context, backup []byte) (*validation.RestoreOutput, error) {
+	output := &validation.RestoreOutput{}
+	err := c.doJSON(ctx, request{
+		method:      http.MethodPost,
+		path:        "/api/v0/admin/restore",
+		contentType: contentTypeOctetStream,
+		body:        backup,
+	}, output)
+	if err != nil {
+		return nil, err
+	}
+	return output, nil
+}
@@ -0,0 +1,275 @@
+// Package client is a typed Go client for the signature service REST API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IdempotencyKeyHeader carries the key that lets the service replay the
+// response of a POST request instead of running it twice.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// Defaults of a Client.
+const (
+	DefaultRetries = 3
+	DefaultBackoff = 200 * time.Millisecond
+	DefaultTimeout = 30 * time.Second
+)
+
+// maxBackoff caps the delay between two attempts.
+const maxBackoff = 5 * time.Second
+
+// Client calls the signature service. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	timeout    time.Duration
+	retries    int
+	backoff    time.Duration
+	userAgent  string
+	apiKey     string
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used for requests, e.g. to configure
+// TLS or a proxy.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithTimeout limits how long a single attempt of a request may take.
+// Zero disables the limit.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithRetries sets how often a failed request is retried. Zero disables
+// retries.
+func WithRetries(retries int) Option {
+	return func(c *Client) {
+		c.retries = retries
+	}
+}
+
+// WithBackoff sets the delay before the first retry. It doubles with every
+// further attempt.
+func WithBackoff(backoff time.Duration) Option {
+	return func(c *Client) {
+		c.backoff = backoff
+	}
+}
+
+// WithUserAgent sets the User-Agent header of requests.
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+// WithAPIKey authenticates requests with an API key sent as bearer token.
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// NewClient returns a client for the service at baseURL, e.g.
+// "http://localhost:8080".
+func NewClient(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: http.DefaultClient,
+		timeout:    DefaultTimeout,
+		retries:    DefaultRetries,
+		backoff:    DefaultBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+type idempotencyKeyContextKey struct{}
+
+// WithIdempotencyKey returns a context that makes the next POST request use
+// key as idempotency key. By default every call uses a new random key, which
+// protects its own retries; a caller supplied key also protects retries of
+// the whole call, e.g. after a restart.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyContextKey{}, key)
+}
+
+// request describes one API call.
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	contentType string
+	accept      string
+	body        []byte
+}
+
+// response is the successful response of an API call.
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// setHeaders sets the headers common to all requests.
+func (c *Client) setHeaders(req *http.Request) {
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+}
+
+// do sends a request, retrying it on network errors, 429 and 5xx responses
+// other than 501. GET and PUT requests are idempotent by themselves, POST
+// requests are made idempotent with an Idempotency-Key header. DELETE
+// requests are not retried.
+func (c *Client) do(ctx context.Context, r request) (*response, error) {
+	retry := r.method != http.MethodDelete
+
+	var idempotencyKey string
+	if r.method == http.MethodPost {
+		idempotencyKey, _ = ctx.Value(idempotencyKeyContextKey{}).(string)
+		if idempotencyKey == "" {
+			idempotencyKey = uuid.New().String()
+		}
+	}
+
+	target := c.baseURL + r.path
+	if len(r.query) > 0 {
+		target += "?" + r.query.Encode()
+	}
+
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if c.timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, c.timeout)
+		}
+		req, err := http.NewRequestWithContext(attemptCtx, r.method, target, bytes.NewReader(r.body))
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		if r.contentType != "" {
+			req.Header.Set("Content-Type", r.contentType)
+		}
+		accept := r.accept
+		if accept == "" {
+			accept = "application/json"
+		}
+		req.Header.Set("Accept", accept)
+		if idempotencyKey != "" {
+			req.Header.Set(IdempotencyKeyHeader, idempotencyKey)
+		}
+		c.setHeaders(req)
+
+		resp, err := c.send(req)
+		cancel()
+		if err == nil && resp.status < 300 {
+			return resp, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if !retry || attempt >= c.retries || !retryable(err) {
+			return nil, err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// send performs a single attempt. Error responses are returned as *Error.
+func (c *Client) send(req *http.Request) (*response, error) {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, newError(resp.StatusCode, body)
+	}
+	return &response{status: resp.StatusCode, header: resp.Header, body: body}, nil
+}
+
+func retryable(err error) bool {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests ||
+			(apiErr.StatusCode >= 500 && apiErr.StatusCode != http.StatusNotImplemented)
+	}
+	return true
+}
+
+// getJSON sends a GET request and decodes the JSON response into out.
+func (c *Client) getJSON(ctx context.Context, path string, query url.Values, out any) error {
+	return c.doJSON(ctx, request{method: http.MethodGet, path: path, query: query}, out)
+}
+
+// sendJSON sends in as JSON body and decodes the JSON response into out.
+func (c *Client) sendJSON(ctx context.Context, method, path string, in, out any) error {
+	r := request{method: method, path: path}
+	if in != nil {
+		body, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		r.body = body
+		r.contentType = "application/json"
+	}
+	return c.doJSON(ctx, r, out)
+}
+
+func (c *Client) doJSON(ctx context.Context, r request, out any) error {
+	resp, err := c.do(ctx, r)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(resp.body, out); err != nil {
+		return fmt.Errorf("decode response of %s %s: %w", r.method, r.path, err)
+	}
+	return nil
+}
+
+// escape escapes an ID for use as path segment.
+func escape(id string) string {
+	return url.PathEscape(id)
+}
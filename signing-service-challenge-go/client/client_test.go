@@ -0,0 +1,334 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/api"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/auth"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/client"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/repository"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/service"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/validation"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/events"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+)
+
+// faults sits in front of the real API and lets a test fail requests before
+// or after the API has handled them. It records every request it sees.
+type faults struct {
+	next http.Handler
+
+	mu       sync.Mutex
+	requests []*http.Request
+	// reject answers the request with the returned status instead of
+	// passing it on if the status is not zero.
+	reject func(r *http.Request, attempt int) int
+	// drop passes the request on but closes the connection instead of
+	// sending the response, as if the network failed after the service
+	// handled the request.
+	drop func(r *http.Request, attempt int) bool
+}
+
+func (f *faults) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.requests = append(f.requests, r)
+	attempt := 0
+	for _, seen := range f.requests {
+		if seen.Method == r.Method && seen.URL.Path == r.URL.Path {
+			attempt++
+		}
+	}
+	reject, drop := f.reject, f.drop
+	f.mu.Unlock()
+
+	if reject != nil {
+		if status := reject(r, attempt); status != 0 {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+	}
+	if drop != nil && drop(r, attempt) {
+		f.next.ServeHTTP(httptest.NewRecorder(), r)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+		return
+	}
+	f.next.ServeHTTP(w, r)
+}
+
+// attempts returns the requests seen for a method and path.
+func (f *faults) attempts(method, path string) []*http.Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var requests []*http.Request
+	for _, r := range f.requests {
+		if r.Method == method && r.URL.Path == path {
+			requests = append(requests, r)
+		}
+	}
+	return requests
+}
+
+// newTestClient serves the real API with an in-memory database behind
+// faults and returns a client for it that retries quickly.
+func newTestClient(t *testing.T, opts ...api.Option) (*client.Client, *faults, *httptest.Server, service.APIKeyService) {
+	t.Helper()
+	logger := log.New(io.Discard, "", 0)
+	repo := repository.NewRepository(persistence.NewDatabase())
+	bus := events.NewBus(16)
+	apiKeySvc := service.NewAPIKeyService(repo)
+	server := api.NewServer("", service.NewDeviceService(logger, repo), service.NewWebhookService(logger, repo, bus), apiKeySvc, bus,
+		append([]api.Option{api.WithLogger(logger)}, opts...)...)
+
+	f := &faults{next: server.Handler()}
+	ts := httptest.NewServer(f)
+	t.Cleanup(ts.Close)
+	return client.NewClient(ts.URL, client.WithBackoff(time.Millisecond)), f, ts, apiKeySvc
+}
+
+func createDevice(t *testing.T, c *client.Client, id string) {
+	t.Helper()
+	if _, err := c.CreateSignatureDevice(context.Background(), &validation.CreateSignatureDeviceInput{ID: id, Algorithm: "ECC"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func signatureCounter(t *testing.T, c *client.Client, id string) int {
+	t.Helper()
+	output, err := c.GetSignatureDevice(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return output.Device.SignatureCounter
+}
+
+func TestClientRetriesServerErrors(t *testing.T) {
+	c, f, _, _ := newTestClient(t)
+	createDevice(t, c, "device")
+	f.reject = func(r *http.Request, attempt int) int {
+		if r.Method == http.MethodGet && attempt <= 2 {
+			return http.StatusServiceUnavailable
+		}
+		return 0
+	}
+
+	if _, err := c.GetSignatureDevice(context.Background(), "device"); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(f.attempts(http.MethodGet, "/api/v0/signature-device/device")); got != 3 {
+		t.Errorf("got %d attempts, want 3", got)
+	}
+}
+
+func TestClientGivesUpAfterRetries(t *testing.T) {
+	c, f, _, _ := newTestClient(t)
+	f.reject = func(*http.Request, int) int { return http.StatusBadGateway }
+
+	_, err := c.GetSignatureDevice(context.Background(), "device")
+	if !errors.Is(err, client.ErrServer) {
+		t.Fatalf("got %v, want ErrServer", err)
+	}
+	if got := len(f.attempts(http.MethodGet, "/api/v0/signature-device/device")); got != client.DefaultRetries+1 {
+		t.Errorf("got %d attempts, want %d", got, client.DefaultRetries+1)
+	}
+}
+
+func TestClientDoesNotRetry(t *testing.T) {
+	c, f, _, _ := newTestClient(t)
+	createDevice(t, c, "device")
+
+	// Client errors are final.
+	if _, err := c.GetSignatureDevice(context.Background(), "missing"); !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+	if got := len(f.attempts(http.MethodGet, "/api/v0/signature-device/missing")); got != 1 {
+		t.Errorf("got %d attempts of a 404, want 1", got)
+	}
+
+	// DELETE requests are never retried.
+	f.reject = func(r *http.Request, attempt int) int {
+		if r.Method == http.MethodDelete {
+			return http.StatusServiceUnavailable
+		}
+		return 0
+	}
+	if _, err := c.DeleteWebhook(context.Background(), "webhook"); !errors.Is(err, client.ErrServer) {
+		t.Fatalf("got %v, want ErrServer", err)
+	}
+	if got := len(f.attempts(http.MethodDelete, "/api/v0/webhooks/webhook")); got != 1 {
+		t.Errorf("got %d attempts of a DELETE, want 1", got)
+	}
+}
+
+// TestClientRetryIsIdempotent loses the response of a signing request that
+// the service has handled. The retry must carry the same idempotency key and
+// get the stored response, so the counter advances only once.
+func TestClientRetryIsIdempotent(t *testing.T) {
+	c, f, _, _ := newTestClient(t)
+	createDevice(t, c, "device")
+	f.drop = func(r *http.Request, attempt int) bool {
+		return r.URL.Path == "/api/v0/sign-transaction" && attempt == 1
+	}
+
+	output, err := c.SignTransaction(context.Background(), &validation.SignTransactionInput{DeviceID: "device", Data: []byte("data")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attempts := f.attempts(http.MethodPost, "/api/v0/sign-transaction")
+	if len(attempts) != 2 {
+		t.Fatalf("got %d attempts, want 2", len(attempts))
+	}
+	first, second := attempts[0].Header.Get(client.IdempotencyKeyHeader), attempts[1].Header.Get(client.IdempotencyKeyHeader)
+	if first == "" || first != second {
+		t.Errorf("idempotency keys %q and %q, want the same key", first, second)
+	}
+	if got := signatureCounter(t, c, "device"); got != 1 {
+		t.Errorf("signature counter is %d, want 1", got)
+	}
+	transactions, err := c.ListTransaction(context.Background(), "device")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transactions.Transaction) != 1 || transactions.Transaction[0].SecuredData() != output.SignedData {
+		t.Errorf("stored transactions %v do not match the response", transactions.Transaction)
+	}
+}
+
+func TestClientIdempotencyKey(t *testing.T) {
+	c, _, _, _ := newTestClient(t)
+	createDevice(t, c, "device")
+	ctx := client.WithIdempotencyKey(context.Background(), "order-1")
+	input := &validation.SignTransactionInput{DeviceID: "device", Data: []byte("data")}
+
+	first, err := c.SignTransaction(ctx, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := c.SignTransaction(ctx, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Transaction != second.Transaction {
+		t.Error("repeated call with the same key returned another signature")
+	}
+	if got := signatureCounter(t, c, "device"); got != 1 {
+		t.Errorf("signature counter is %d, want 1", got)
+	}
+
+	other := &validation.SignTransactionInput{DeviceID: "device", Data: []byte("other")}
+	if _, err := c.SignTransaction(ctx, other); !errors.Is(err, client.ErrUnprocessableEntity) {
+		t.Errorf("reusing the key for another request: got %v, want ErrUnprocessableEntity", err)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	c, _, _, _ := newTestClient(t)
+	createDevice(t, c, "device")
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		call func() error
+		want []error
+	}{
+		{
+			name: "missing device",
+			call: func() error { _, err := c.GetSignatureDevice(ctx, "missing"); return err },
+			want: []error{client.ErrNotFound, repository.ErrDeviceNotFound},
+		},
+		{
+			name: "existing device",
+			call: func() error {
+				_, err := c.CreateSignatureDevice(ctx, &validation.CreateSignatureDeviceInput{ID: "device", Algorithm: "ECC"})
+				return err
+			},
+			want: []error{client.ErrConflict, repository.ErrDeviceExists},
+		},
+		{
+			name: "invalid device",
+			call: func() error {
+				_, err := c.CreateSignatureDevice(ctx, &validation.CreateSignatureDeviceInput{ID: "other", Algorithm: "DSA"})
+				return err
+			},
+			want: []error{client.ErrBadRequest},
+		},
+		{
+			name: "missing transaction",
+			call: func() error { _, err := c.GetTransaction(ctx, "missing"); return err },
+			want: []error{client.ErrNotFound, repository.ErrTransactionNotFound},
+		},
+		{
+			name: "no trust anchors",
+			call: func() error {
+				_, err := c.UploadDeviceCertificate(ctx, &validation.UploadDeviceCertificateInput{ID: "device", Certificate: []byte("-----BEGIN CERTIFICATE-----\n-----END CERTIFICATE-----\n")})
+				return err
+			},
+			want: []error{client.ErrNotImplemented, service.ErrNoTrustAnchors},
+		},
+		{
+			name: "batching disabled",
+			call: func() error {
+				_, err := c.SignBatched(ctx, &validation.SignBatchedInput{DeviceID: "device", Data: []byte("data")})
+				return err
+			},
+			want: []error{client.ErrBadRequest, service.ErrBatchingDisabled},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			var apiErr *client.Error
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("got %T %v, want *client.Error", err, err)
+			}
+			for _, want := range tt.want {
+				if !errors.Is(err, want) {
+					t.Errorf("%v is not %v", err, want)
+				}
+			}
+			if errors.Is(err, client.ErrServer) {
+				t.Errorf("%v is a server error", err)
+			}
+		})
+	}
+}
+
+func TestClientAuthErrors(t *testing.T) {
+	c, _, ts, apiKeys := newTestClient(t)
+	createDevice(t, c, "device")
+	issued, err := apiKeys.CreateAPIKey(&validation.CreateAPIKeyInput{
+		Name:        "till",
+		Permissions: []string{string(auth.PermissionDevicesRead)},
+		DeviceIDs:   []string{"device"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if _, err := c.GetSignatureDevice(ctx, "device"); !errors.Is(err, client.ErrUnauthorized) {
+		t.Errorf("without key: got %v, want ErrUnauthorized", err)
+	}
+
+	restricted := client.NewClient(ts.URL, client.WithAPIKey(issued.Key))
+	if _, err := restricted.GetSignatureDevice(ctx, "device"); err != nil {
+		t.Errorf("allowed device: %v", err)
+	}
+	if _, err := restricted.GetSignatureDevice(ctx, "other"); !errors.Is(err, client.ErrForbidden) {
+		t.Errorf("other device: got %v, want ErrForbidden", err)
+	}
+	if _, err := restricted.ListAPIKey(ctx); !errors.Is(err, client.ErrForbidden) {
+		t.Errorf("admin call: got %v, want ErrForbidden", err)
+	}
+}
@@ -0,0 +1,242 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/validation"
+)
+
+// Content types of non-JSON bodies.
+const (
+	contentTypePEM         = "application/x-pem-file"
+	contentTypePEMChain    = "application/pem-certificate-chain"
+	contentTypeOctetStream = "application/octet-stream"
+	contentTypeTar         = "application/x-tar"
+)
+
+// Health is the response of the health endpoint.
+type Health struct {
+	Status  string `json:"status"`
+	Version string `json:"version"`
+}
+
+// Health returns the health of the service.
+func (c *Client) Health(ctx context.Context) (*Health, error) {
+	output := &Health{}
+	if err := c.getJSON(ctx, "/api/v0/health", nil, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// CreateSignatureDevice creates a signature device.
+func (c *Client) CreateSignatureDevice(ctx context.Context, input *validation.CreateSignatureDeviceInput) (*validation.CreateSignatureDeviceOutput, error) {
+	output := &validation.CreateSignatureDeviceOutput{}
+	if err := c.sendJSON(ctx, http.MethodPost, "/api/v0/signature-device", input, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// ListSignatureDevice lists the signature devices matching the non-empty
+// fields of input.
+func (c *Client) ListSignatureDevice(ctx context.Context, input *validation.ListSignatureDeviceInput) (*validation.ListSignatureDeviceOutput, error) {
+	query := url.Values{}
+	if input != nil {
+		setQuery(query, "id", input.ID)
+		setQuery(query, "label", input.Label)
+		setQuery(query, "algorithm", input.Algorithm)
+	}
+	output := &validation.ListSignatureDeviceOutput{}
+	if err := c.getJSON(ctx, "/api/v0/signature-device/list", query, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// GetSignatureDevice returns a signature device.
+func (c *Client) GetSignatureDevice(ctx context.Context, id string) (*validation.GetSignatureDeviceOutput, error) {
+	output := &validation.GetSignatureDeviceOutput{}
+	if err := c.getJSON(ctx, "/api/v0/signature-device/"+escape(id), nil, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// GetDeviceJWK returns the public key of a signature device as JWK.
+func (c *Client) GetDeviceJWK(ctx context.Context, id string) (*validation.GetDeviceJWKOutput, error) {
+	output := &validation.GetDeviceJWKOutput{}
+	if err := c.getJSON(ctx, "/api/v0/signature-device/"+escape(id)+"/jwk", nil, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// VerifySignature lets the service check a signature against the key of
+// the device input.DeviceID. See VerifyTransaction for offline checks.
+func (c *Client) VerifySignature(ctx context.Context, input *validation.VerifySignatureInput) (*validation.VerifySignatureOutput, error) {
+	output := &validation.VerifySignatureOutput{}
+	if err := c.sendJSON(ctx, http.MethodPost, "/api/v0/signature-device/"+escape(input.DeviceID)+"/verify", input, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// VerifyInclusion lets the service check a batched signature.
+func (c *Client) VerifyInclusion(ctx context.Context, input *validation.VerifyInclusionInput) (*validation.VerifyInclusionOutput, error) {
+	output := &validation.VerifyInclusionOutput{}
+	if err := c.sendJSON(ctx, http.MethodPost, "/api/v0/signature-device/"+escape(input.DeviceID)+"/verify-inclusion", input, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// SignTransaction signs transaction data with a device.
+func (c *Client) SignTransaction(ctx context.Context, input *validation.SignTransactionInput) (*validation.SignTransactionOutput, error) {
+	output := &validation.SignTransactionOutput{}
+	if err := c.sendJSON(ctx, http.MethodPost, "/api/v0/sign-transaction", input, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// SignBatched adds transaction data to the current batch of a device and
+// returns once the batch is signed.
+func (c *Client) SignBatched(ctx context.Context, input *validation.SignBatchedInput) (*validation.SignBatchedOutput, error) {
+	output := &validation.SignBatchedOutput{}
+	if err := c.sendJSON(ctx, http.MethodPost, "/api/v0/sign-transaction/batched", input, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// SignDocument signs content with a detached CMS signature.
+func (c *Client) SignDocument(ctx context.Context, deviceID string, content []byte) (*validation.SignDocumentOutput, error) {
+	output := &validation.SignDocumentOutput{}
+	err := c.doJSON(ctx, request{
+		method:      http.MethodPost,
+		path:        "/api/v0/signature-device/" + escape(deviceID) + "/sign-document",
+		contentType: contentTypeOctetStream,
+		body:        content,
+	}, output)
+	if err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// ListTransaction lists the transactions of a device, or of all devices if
+// deviceID is empty.
+func (c *Client) ListTransaction(ctx context.Context, deviceID string) (*validation.ListTransactionOutput, error) {
+	query := url.Values{}
+	setQuery(query, "device_id", deviceID)
+	output := &validation.ListTransactionOutput{}
+	if err := c.getJSON(ctx, "/api/v0/sign-transaction/list", query, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// GetTransaction returns a transaction.
+func (c *Client) GetTransaction(ctx context.Context, id string) (*validation.GetTransactionOutput, error) {
+	output := &validation.GetTransactionOutput{}
+	if err := c.getJSON(ctx, "/api/v0/sign-transaction/"+escape(id), nil, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// GetDeviceCertificate returns the PEM encoded certificate chain of a device.
+func (c *Client) GetDeviceCertificate(ctx context.Context, id string) (*validation.GetDeviceCertificateOutput, error) {
+	resp, err := c.do(ctx, request{
+		method: http.MethodGet,
+		path:   "/api/v0/signature-device/" + escape(id) + "/certificate",
+		accept: contentTypePEMChain,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &validation.GetDeviceCertificateOutput{Certificate: resp.body}, nil
+}
+
+// ExportDevice streams the TAR archive of a device and its transactions to
+// w. Archives can be large, so the request is neither retried nor limited
+// by the client timeout; use ctx to bound it. If the transfer breaks, an
+// error is returned and w holds a truncated archive.
+func (c *Client) ExportDevice(ctx context.Context, id string, w io.Writer) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/api/v0/signature-device/"+escape(id)+"/export", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", contentTypeTar)
+	c.setHeaders(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		return newError(resp.StatusCode, body)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// UploadDeviceCertificate stores a PEM encoded certificate, optionally
+// followed by intermediate certificates, issued for a device by an
+// external CA.
+func (c *Client) UploadDeviceCertificate(ctx context.Context, input *validation.UploadDeviceCertificateInput) (*validation.UploadDeviceCertificateOutput, error) {
+	output := &validation.UploadDeviceCertificateOutput{}
+	err := c.doJSON(ctx, request{
+		method:      http.MethodPut,
+		path:        "/api/v0/signature-device/" + escape(input.ID) + "/certificate",
+		contentType: contentTypePEM,
+		body:        input.Certificate,
+	}, output)
+	if err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// CreateCertificateRequest returns a PEM encoded PKCS #10 request for the
+// key of a device.
+func (c *Client) CreateCertificateRequest(ctx context.Context, id string) (*validation.CreateCertificateRequestOutput, error) {
+	resp, err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/v0/signature-device/" + escape(id) + "/csr",
+		accept: contentTypePEM,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &validation.CreateCertificateRequestOutput{CertificateRequest: resp.body}, nil
+}
+
+// GetCACertificate returns the PEM encoded certificate of the built-in CA.
+func (c *Client) GetCACertificate(ctx context.Context) (*validation.GetCACertificateOutput, error) {
+	resp, err := c.do(ctx, request{
+		method: http.MethodGet,
+		path:   "/api/v0/ca/certificate",
+		accept: contentTypePEMChain,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &validation.GetCACertificateOutput{Certificate: resp.body}, nil
+}
+
+func setQuery(query url.Values, key, value string) {
+	if value != "" {
+		query.Set(key, value)
+	}
+}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/repository"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/service"
+)
+
+// Error classes of API error responses, matched with errors.Is.
+var (
+	ErrBadRequest          = errors.New("bad request")
+	ErrUnauthorized        = errors.New("unauthorized")
+	ErrForbidden           = errors.New("forbidden")
+	ErrNotFound            = errors.New("not found")
+	ErrConflict            = errors.New("conflict")
+	ErrUnprocessableEntity = errors.New("unprocessable entity")
+	ErrNotImplemented      = errors.New("not implemented")
+	ErrServer              = errors.New("server error")
+)
+
+// serviceErrors are the errors of the service that are matched by message,
+// so errors.Is(err, repository.ErrDeviceNotFound) works on client errors.
+var serviceErrors = []error{
+	repository.ErrDeviceNotFound,
+	repository.ErrDeviceExists,
+	repository.ErrTransactionNotFound,
+	repository.ErrTransactionExists,
+	repository.ErrCounterOutOfSequence,
+	repository.ErrWebhookNotFound,
+	repository.ErrWebhookExists,
+	repository.ErrDeliveryNotFound,
+	repository.ErrDeliveryExists,
+	repository.ErrAPIKeyNotFound,
+	repository.ErrAPIKeyExists,
+	service.ErrCertificateNotFound,
+	service.ErrNoCertificateAuthority,
+	service.ErrNoTrustAnchors,
+	service.ErrInvalidCertificate,
+	service.ErrBatchingDisabled,
+	service.ErrDeliveryNotFailed,
+	service.ErrPrivateWebhookTarget,
+	service.ErrNoBackupKey,
+	service.ErrStoreNotEmpty,
+	service.ErrAlgorithmNotAllowed,
+	service.ErrShuttingDown,
+}
+
+// Error is an error response of the service.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func newError(statusCode int, body []byte) *Error {
+	var response struct {
+		Errors string `json:"errors"`
+	}
+	message := strings.TrimSpace(string(body))
+	if json.Unmarshal(body, &response) == nil && response.Errors != "" {
+		message = response.Errors
+	}
+	if message == "" {
+		message = http.StatusText(statusCode)
+	}
+	return &Error{StatusCode: statusCode, Message: message}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("signing service: %s (HTTP %d)", e.Message, e.StatusCode)
+}
+
+// Is reports whether the error belongs to the class of target, e.g.
+// ErrNotFound, or is the service error target.
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrUnprocessableEntity:
+		return e.StatusCode == http.StatusUnprocessableEntity
+	case ErrNotImplemented:
+		return e.StatusCode == http.StatusNotImplemented
+	case ErrServer:
+		return e.StatusCode >= 500 && e.StatusCode != http.StatusNotImplemented
+	}
+	for _, serviceErr := range serviceErrors {
+		if target == serviceErr {
+			message := serviceErr.Error()
+			return e.Message == message || strings.HasSuffix(e.Message, ": "+message)
+		}
+	}
+	return false
+}
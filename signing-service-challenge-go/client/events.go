@@ -0,0 +1,88 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/events"
+)
+
+// StreamEvents reads the event stream and calls fn for every event matching
+// filter, in order, until ctx is done, fn returns an error or the stream
+// ends. With resume set, the stream continues after the event lastID.
+//
+// Event.Data holds the decoded JSON of the event data, not one of the
+// payload types of package events.
+//
+// The stream is not retried; callers reconnect with the ID of the last
+// event they processed.
+func (c *Client) StreamEvents(ctx context.Context, filter events.Filter, lastID uint64, resume bool, fn func(events.Event) error) error {
+	query := url.Values{}
+	if len(filter.DeviceIDs) > 0 {
+		query.Set("device_id", strings.Join(filter.DeviceIDs, ","))
+	}
+	if len(filter.Types) > 0 {
+		query.Set("type", strings.Join(filter.Types, ","))
+	}
+	target := c.baseURL + "/api/v0/events"
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if resume {
+		req.Header.Set("Last-Event-ID", strconv.FormatUint(lastID, 10))
+	}
+	c.setHeaders(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return newError(resp.StatusCode, body)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if data.Len() == 0 {
+				continue
+			}
+			var event events.Event
+			if err := json.Unmarshal([]byte(data.String()), &event); err != nil {
+				return fmt.Errorf("decode event: %w", err)
+			}
+			data.Reset()
+			if err := fn(event); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return scanner.Err()
+}
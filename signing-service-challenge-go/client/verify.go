@@ -0,0 +1,60 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/entity"
+)
+
+// ErrBrokenChain is returned when the transactions of a device do not form
+// a gapless signature chain.
+var ErrBrokenChain = errors.New("broken signature chain")
+
+// VerifyTransaction checks the signature of a transaction against the public
+// key of its device without contacting the service. Only the ID, algorithm,
+// signature scheme and public key of the device are used.
+func VerifyTransaction(device *entity.Device, transaction *entity.Transaction) error {
+	if transaction.DeviceID != device.ID {
+		return fmt.Errorf("transaction %s belongs to device %s, not %s", transaction.ID, transaction.DeviceID, device.ID)
+	}
+	verifier, err := crypto.NewVerifier(device)
+	if err != nil {
+		return err
+	}
+	if err := verifier.Verify([]byte(transaction.SecuredData()), transaction.Signature); err != nil {
+		return fmt.Errorf("transaction %s: %w", transaction.ID, err)
+	}
+	return nil
+}
+
+// VerifyChain checks the signatures of all transactions of a device and that
+// they form its signature chain: counters start at zero without gaps, the
+// first transaction is chained to the device ID and every further one to
+// the signature of its predecessor. The transactions may be given in any
+// order.
+func VerifyChain(device *entity.Device, transactions []*entity.Transaction) error {
+	sorted := make([]*entity.Transaction, len(transactions))
+	copy(sorted, transactions)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].SignatureCounter < sorted[j].SignatureCounter
+	})
+
+	lastSignature := []byte(device.ID)
+	for i, transaction := range sorted {
+		if transaction.SignatureCounter != i {
+			return fmt.Errorf("%w: expected signature counter %d, got %d", ErrBrokenChain, i, transaction.SignatureCounter)
+		}
+		if !bytes.Equal(transaction.LastSignatureID, lastSignature) {
+			return fmt.Errorf("%w: transaction %s is not chained to its predecessor", ErrBrokenChain, transaction.ID)
+		}
+		if err := VerifyTransaction(device, transaction); err != nil {
+			return err
+		}
+		lastSignature = transaction.Signature
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/validation"
+)
+
+// CreateWebhook registers a webhook. The returned secret signs its
+// deliveries and is not returned again.
+func (c *Client) CreateWebhook(ctx context.Context, input *validation.CreateWebhookInput) (*validation.CreateWebhookOutput, error) {
+	output := &validation.CreateWebhookOutput{}
+	if err := c.sendJSON(ctx, http.MethodPost, "/api/v0/webhooks", input, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// ListWebhook lists all webhooks.
+func (c *Client) ListWebhook(ctx context.Context) (*validation.ListWebhookOutput, error) {
+	output := &validation.ListWebhookOutput{}
+	if err := c.getJSON(ctx, "/api/v0/webhooks/list", nil, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// GetWebhook returns a webhook.
+func (c *Client) GetWebhook(ctx context.Context, id string) (*validation.GetWebhookOutput, error) {
+	output := &validation.GetWebhookOutput{}
+	if err := c.getJSON(ctx, "/api/v0/webhooks/"+escape(id), nil, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// DeleteWebhook removes a webhook and its delivery log.
+func (c *Client) DeleteWebhook(ctx context.Context, id string) (*validation.DeleteWebhookOutput, error) {
+	output := &validation.DeleteWebhookOutput{}
+	if err := c.sendJSON(ctx, http.MethodDelete, "/api/v0/webhooks/"+escape(id), nil, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// ListWebhookDelivery returns the delivery log of a webhook, restricted to
+// one status if input.Status is set.
+func (c *Client) ListWebhookDelivery(ctx context.Context, input *validation.ListWebhookDeliveryInput) (*validation.ListWebhookDeliveryOutput, error) {
+	query := url.Values{}
+	setQuery(query, "status", input.Status)
+	output := &validation.ListWebhookDeliveryOutput{}
+	if err := c.getJSON(ctx, "/api/v0/webhooks/"+escape(input.WebhookID)+"/deliveries", query, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// RetryWebhookDelivery queues a failed delivery again.
+func (c *Client) RetryWebhookDelivery(ctx context.Context, input *validation.RetryWebhookDeliveryInput) (*validation.RetryWebhookDeliveryOutput, error) {
+	output := &validation.RetryWebhookDeliveryOutput{}
+	path := "/api/v0/webhooks/" + escape(input.WebhookID) + "/deliveries/" + escape(input.DeliveryID) + "/retry"
+	if err := c.sendJSON(ctx, http.MethodPost, path, nil, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strconv"
+)
+
+// backup writes an encrypted backup of the service to a file or stdout.
+func (a *app) backup(ctx context.Context, args []string) error {
+	flags := newFlagSet("backup", "")
+	file := flags.String("file", "", "write the backup to this file instead of stdout")
+	if err := parse(flags, args, 0, 0); err != nil {
+		return err
+	}
+
+	data, err := a.client.Backup(ctx)
+	if err != nil {
+		return err
+	}
+	if *file != "" {
+		return os.WriteFile(*file, data, 0o600)
+	}
+	_, err = a.out.w.Write(data)
+	return err
+}
+
+// restore restores an encrypted backup from a file or stdin into a service
+// without devices.
+func (a *app) restore(ctx context.Context, args []string) error {
+	flags := newFlagSet("restore", "[FILE]")
+	if err := parse(flags, args, 0, 1); err != nil {
+		return err
+	}
+
+	data, err := a.readInput(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	output, err := a.client.Restore(ctx, data)
+	if err != nil {
+		return err
+	}
+	return a.out.fields(output, []field{
+		{"DEVICES", strconv.Itoa(output.Devices)},
+		{"TRANSACTIONS", strconv.Itoa(output.Transactions)},
+	})
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strconv"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/validation"
+	"github.com/google/uuid"
+)
+
+func (a *app) device(ctx context.Context, args []string) error {
+	return subcommand(ctx, "device", args, map[string]func(context.Context, []string) error{
+		"create": a.createDevice,
+		"list":   a.listDevices,
+		"get":    a.getDevice,
+		"key":    a.deviceKey,
+	})
+}
+
+func (a *app) createDevice(ctx context.Context, args []string) error {
+	flags := newFlagSet("device create", "")
+	input := &validation.CreateSignatureDeviceInput{}
+	flags.StringVar(&input.ID, "id", "", "device ID (default: a new UUID)")
+	flags.StringVar(&input.Algorithm, "algorithm", "", "signature algorithm: ECC or RSA")
+	flags.StringVar(&input.Label, "label", "", "device label")
+	flags.BoolVar(&input.Deterministic, "deterministic", false, "use deterministic ECDSA nonces (RFC 6979)")
+	flags.StringVar(&input.SignatureScheme, "scheme", "", "RSA signature scheme: PSS or PKCS1v15")
+	flags.StringVar(&input.SignatureFormat, "format", "", "ECDSA signature encoding: DER or PLAIN")
+	batchWindow := flags.Duration("batch-window", 0, "enable batched signing with this collection window")
+	if err := parse(flags, args, 0, 0); err != nil {
+		return err
+	}
+	if input.ID == "" {
+		input.ID = uuid.New().String()
+	}
+	input.BatchWindowMS = int(batchWindow.Milliseconds())
+
+	if _, err := a.client.CreateSignatureDevice(ctx, input); err != nil {
+		return err
+	}
+	output, err := a.client.GetSignatureDevice(ctx, input.ID)
+	if err != nil {
+		return err
+	}
+	return a.printDevice(output.Device)
+}
+
+func (a *app) listDevices(ctx context.Context, args []string) error {
+	flags := newFlagSet("device list", "")
+	input := &validation.ListSignatureDeviceInput{}
+	flags.StringVar(&input.ID, "id", "", "only list the device with this ID")
+	flags.StringVar(&input.Label, "label", "", "only list devices with this label")
+	flags.StringVar(&input.Algorithm, "algorithm", "", "only list devices with this algorithm")
+	if err := parse(flags, args, 0, 0); err != nil {
+		return err
+	}
+
+	output, err := a.client.ListSignatureDevice(ctx, input)
+	if err != nil {
+		return err
+	}
+	sort.Slice(output.Device, func(i, j int) bool {
+		return output.Device[i].ID < output.Device[j].ID
+	})
+
+	rows := make([][]string, 0, len(output.Device))
+	for _, device := range output.Device {
+		rows = append(rows, []string{
+			device.ID,
+			device.Label,
+			device.Algorithm,
+			strconv.Itoa(device.SignatureCounter),
+			batchWindow(device),
+		})
+	}
+	return a.out.table(output, []string{"ID", "LABEL", "ALGORITHM", "COUNTER", "BATCH WINDOW"}, rows)
+}
+
+func (a *app) getDevice(ctx context.Context, args []string) error {
+	flags := newFlagSet("device get", "ID")
+	if err := parse(flags, args, 1, 1); err != nil {
+		return err
+	}
+
+	output, err := a.client.GetSignatureDevice(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	return a.printDevice(output.Device)
+}
+
+// deviceKey prints the public key of a device as PEM or, with -jwk, as JWK.
+func (a *app) deviceKey(ctx context.Context, args []string) error {
+	flags := newFlagSet("device key", "ID")
+	jwk := flags.Bool("jwk", false, "print the key as JSON Web Key")
+	if err := parse(flags, args, 1, 1); err != nil {
+		return err
+	}
+
+	if *jwk {
+		output, err := a.client.GetDeviceJWK(ctx, flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		return a.out.json(output.JWK)
+	}
+
+	output, err := a.client.GetSignatureDevice(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if a.out.format == formatJSON {
+		return a.out.json(map[string]string{"public_key": string(output.Device.PublicKey)})
+	}
+	_, err = a.out.w.Write(output.Device.PublicKey)
+	return err
+}
+
+func (a *app) printDevice(device *validation.Device) error {
+	return a.out.fields(device, []field{
+		{"ID", device.ID},
+		{"LABEL", device.Label},
+		{"ALGORITHM", device.Algorithm},
+		{"SIGNATURE SCHEME", device.SignatureScheme},
+		{"SIGNATURE FORMAT", device.SignatureFormat},
+		{"DETERMINISTIC", strconv.FormatBool(device.Deterministic)},
+		{"COUNTER", strconv.Itoa(device.SignatureCounter)},
+		{"BATCH WINDOW", batchWindow(device)},
+		{"CERTIFICATE", strconv.FormatBool(len(device.Certificate) > 0)},
+	})
+}
+
+func batchWindow(device *validation.Device) string {
+	if device.BatchWindow == 0 {
+		return ""
+	}
+	return device.BatchWindow.String()
+}
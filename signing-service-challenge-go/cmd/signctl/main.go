@@ -0,0 +1,251 @@
+// Command signctl manages signature devices and transactions of a signature
+// service from the command line.
+//
+// Usage:
+//
+//	signctl [-server URL] [-o table|json] [-timeout DURATION] COMMAND [ARGS]
+//
+// The server defaults to $SIGNCTL_SERVER or http://localhost:8080. The API
+// key is read from $SIGNCTL_API_KEY unless -api-key is given. Over HTTPS,
+// -cacert trusts a private CA and -cert and -key authenticate with a client
+// certificate instead of an API key.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/client"
+)
+
+// ServerEnv names the environment variable holding the default server URL.
+const ServerEnv = "SIGNCTL_SERVER"
+
+// APIKeyEnv names the environment variable holding the API key.
+const APIKeyEnv = "SIGNCTL_API_KEY"
+
+const defaultServer = "http://localhost:8080"
+
+const usage = `Usage: signctl [flags] COMMAND [ARGS]
+
+Commands:
+  health                           check that the service is up
+  device create -algorithm ECC|RSA create a signature device
+  device list                      list signature devices
+  device get ID                    show a signature device
+  device key [-jwk] ID             print the public key of a device
+  sign -device ID [FILE]           sign FILE, or stdin
+  tx list [-device ID]             list transactions
+  tx get ID                        show a transaction
+  tx export -device ID [-archive] [-file F]
+                                   export a device and its transactions
+  verify -device ID -signature S [FILE]
+                                   let the service verify a signature over FILE, or stdin
+  verify -transaction ID           verify a transaction offline
+  verify -chain -device ID         verify the signature chain of a device offline
+  backup [-file F]                 write an encrypted backup of all devices
+  restore [FILE]                   restore a backup from FILE, or stdin, into an empty service
+
+Run "signctl COMMAND -h" for the flags of a command.
+
+Flags:
+`
+
+// errUsage is returned for invalid command lines; the usage has been printed.
+var errUsage = errors.New("invalid usage")
+
+// app holds the global state shared by all commands.
+type app struct {
+	client *client.Client
+	out    *printer
+	stdin  io.Reader
+}
+
+func main() {
+	err := run(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		if !errors.Is(err, errUsage) && !errors.Is(err, errInvalid) {
+			fmt.Fprintln(os.Stderr, "signctl:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	server := os.Getenv(ServerEnv)
+	if server == "" {
+		server = defaultServer
+	}
+
+	flags := flag.NewFlagSet("signctl", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+	flags.StringVar(&server, "server", server, "base URL of the signature service")
+	format := flags.String("o", formatTable, "output format: table or json")
+	timeout := flags.Duration("timeout", client.DefaultTimeout, "timeout of a request")
+	retries := flags.Int("retries", client.DefaultRetries, "retries of a failed request")
+	apiKey := flags.String("api-key", os.Getenv(APIKeyEnv), "API key of the signature service (env "+APIKeyEnv+")")
+	caFile := flags.String("cacert", "", "PEM file of the CA that issued the server certificate")
+	certFile := flags.String("cert", "", "PEM file of the client certificate")
+	keyFile := flags.String("key", "", "PEM file of the client certificate's key")
+	if err := flags.Parse(args); err != nil {
+		return parseError(err)
+	}
+	if *format != formatTable && *format != formatJSON {
+		return fmt.Errorf("unknown output format: %s", *format)
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errUsage
+	}
+	httpClient, err := newHTTPClient(*caFile, *certFile, *keyFile)
+	if err != nil {
+		return err
+	}
+
+	a := &app{
+		client: client.NewClient(server,
+			client.WithHTTPClient(httpClient),
+			client.WithTimeout(*timeout),
+			client.WithRetries(*retries),
+			client.WithUserAgent("signctl"),
+			client.WithAPIKey(*apiKey),
+		),
+		out:   &printer{format: *format, w: os.Stdout},
+		stdin: os.Stdin,
+	}
+
+	ctx := context.Background()
+	command, args := flags.Arg(0), flags.Args()[1:]
+	switch command {
+	case "health":
+		return a.health(ctx)
+	case "device":
+		return a.device(ctx, args)
+	case "sign":
+		return a.sign(ctx, args)
+	case "tx":
+		return a.transaction(ctx, args)
+	case "verify":
+		return a.verify(ctx, args)
+	case "backup":
+		return a.backup(ctx, args)
+	case "restore":
+		return a.restore(ctx, args)
+	default:
+		flags.Usage()
+		return errUsage
+	}
+}
+
+func (a *app) health(ctx context.Context) error {
+	health, err := a.client.Health(ctx)
+	if err != nil {
+		return err
+	}
+	return a.out.fields(health, []field{
+		{"STATUS", health.Status},
+		{"VERSION", health.Version},
+	})
+}
+
+// subcommand dispatches args[0] to the matching handler.
+func subcommand(ctx context.Context, name string, args []string, handlers map[string]func(context.Context, []string) error) error {
+	if len(args) > 0 {
+		if handler, ok := handlers[args[0]]; ok {
+			return handler(ctx, args[1:])
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Usage: signctl %s SUBCOMMAND; run \"signctl -h\" for the list of commands\n", name)
+	return errUsage
+}
+
+// newFlagSet returns the flag set of a command.
+// newHTTPClient returns an HTTP client that trusts the CA in caFile, if
+// given, in addition to the system roots and presents the client
+// certificate in certFile and keyFile, if given.
+func newHTTPClient(caFile, certFile, keyFile string) (*http.Client, error) {
+	if caFile == "" && certFile == "" && keyFile == "" {
+		return http.DefaultClient, nil
+	}
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("-cert and -key must be given together")
+	}
+
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", caFile)
+		}
+		config.RootCAs = roots
+	}
+	if certFile != "" {
+		certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	return &http.Client{Transport: transport}, nil
+}
+
+func newFlagSet(name, arguments string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: signctl %s [flags] %s\n", name, arguments)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// parse parses the flags of a command and checks the number of positional
+// arguments.
+func parse(flags *flag.FlagSet, args []string, minArgs, maxArgs int) error {
+	if err := flags.Parse(args); err != nil {
+		return parseError(err)
+	}
+	if flags.NArg() < minArgs || flags.NArg() > maxArgs {
+		flags.Usage()
+		return errUsage
+	}
+	return nil
+}
+
+// parseError maps flag parse errors other than -h to errUsage.
+func parseError(err error) error {
+	if errors.Is(err, flag.ErrHelp) {
+		return err
+	}
+	return errUsage
+}
+
+// readInput reads the named file, or stdin if name is empty or "-".
+func (a *app) readInput(name string) ([]byte, error) {
+	if name == "" || name == "-" {
+		return io.ReadAll(a.stdin)
+	}
+	return os.ReadFile(name)
+}
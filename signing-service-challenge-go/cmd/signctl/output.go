@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+)
+
+// Output formats.
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+)
+
+// maxCellWidth truncates long values, e.g. signatures, in tables.
+const maxCellWidth = 48
+
+// printer writes command results as a table or as JSON.
+type printer struct {
+	format string
+	w      io.Writer
+}
+
+// field is one row of a single object printed as table.
+type field struct {
+	name  string
+	value string
+}
+
+// fields prints a single object: v as JSON, or fields as two column table.
+func (p *printer) fields(v any, fields []field) error {
+	if p.format == formatJSON {
+		return p.json(v)
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	for _, f := range fields {
+		fmt.Fprintf(tw, "%s\t%s\n", f.name, escape(f.value))
+	}
+	return tw.Flush()
+}
+
+// table prints a list: v as JSON, or header and rows as table.
+func (p *printer) table(v any, header []string, rows [][]string) error {
+	if p.format == formatJSON {
+		return p.json(v)
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = truncate(cell)
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
+
+func (p *printer) json(v any) error {
+	encoder := json.NewEncoder(p.w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// escape keeps values that contain line breaks or tabs on one table row.
+func escape(s string) string {
+	return strings.NewReplacer("\t", `\t`, "\r", `\r`, "\n", `\n`).Replace(s)
+}
+
+func truncate(s string) string {
+	s = escape(s)
+	if utf8.RuneCountInString(s) <= maxCellWidth {
+		return s
+	}
+	return string([]rune(s)[:maxCellWidth-3]) + "..."
+}
+
+// printable returns data as text if it is valid UTF-8, and a note otherwise.
+func printable(data []byte) string {
+	if utf8.Valid(data) {
+		return string(data)
+	}
+	return fmt.Sprintf("(%d bytes of binary data)", len(data))
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/client"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/entity"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/validation"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/export"
+)
+
+// sign signs the content of a file, or stdin, with a device.
+func (a *app) sign(ctx context.Context, args []string) error {
+	flags := newFlagSet("sign", "[FILE]")
+	input := &validation.SignTransactionInput{}
+	flags.StringVar(&input.DeviceID, "device", "", "ID of the signing device")
+	flags.StringVar(&input.SignatureFormat, "format", "", "ECDSA signature encoding: DER or PLAIN")
+	flags.StringVar(&input.Output, "output", "", "also return the signature as jws or cose")
+	idempotencyKey := flags.String("idempotency-key", "", "idempotency key, to safely repeat the command")
+	if err := parse(flags, args, 0, 1); err != nil {
+		return err
+	}
+	if input.DeviceID == "" {
+		flags.Usage()
+		return errUsage
+	}
+
+	data, err := a.readInput(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	input.Data = data
+
+	if *idempotencyKey != "" {
+		ctx = client.WithIdempotencyKey(ctx, *idempotencyKey)
+	}
+	output, err := a.client.SignTransaction(ctx, input)
+	if err != nil {
+		return err
+	}
+
+	fields := []field{
+		{"SIGNATURE", output.Transaction},
+		{"SIGNED DATA", output.SignedData},
+	}
+	if output.JWS != "" {
+		fields = append(fields, field{"JWS", output.JWS})
+	}
+	if len(output.COSE) > 0 {
+		fields = append(fields, field{"COSE", base64.StdEncoding.EncodeToString(output.COSE)})
+	}
+	if len(output.TimestampToken) > 0 {
+		fields = append(fields, field{"TIMESTAMP TOKEN", base64.StdEncoding.EncodeToString(output.TimestampToken)})
+	}
+	return a.out.fields(output, fields)
+}
+
+func (a *app) transaction(ctx context.Context, args []string) error {
+	return subcommand(ctx, "tx", args, map[string]func(context.Context, []string) error{
+		"list":   a.listTransactions,
+		"get":    a.getTransaction,
+		"export": a.exportTransactions,
+	})
+}
+
+func (a *app) listTransactions(ctx context.Context, args []string) error {
+	flags := newFlagSet("tx list", "")
+	deviceID := flags.String("device", "", "only list transactions of this device")
+	if err := parse(flags, args, 0, 0); err != nil {
+		return err
+	}
+
+	output, err := a.client.ListTransaction(ctx, *deviceID)
+	if err != nil {
+		return err
+	}
+	sortTransactions(output.Transaction)
+
+	rows := make([][]string, 0, len(output.Transaction))
+	for _, transaction := range output.Transaction {
+		rows = append(rows, []string{
+			transaction.ID,
+			transaction.DeviceID,
+			strconv.Itoa(transaction.SignatureCounter),
+			formatTime(transaction.SignedAt),
+			printable(transaction.Data),
+		})
+	}
+	return a.out.table(output, []string{"ID", "DEVICE", "COUNTER", "SIGNED AT", "DATA"}, rows)
+}
+
+func (a *app) getTransaction(ctx context.Context, args []string) error {
+	flags := newFlagSet("tx get", "ID")
+	if err := parse(flags, args, 1, 1); err != nil {
+		return err
+	}
+
+	output, err := a.client.GetTransaction(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	transaction := output.Transaction
+	return a.out.fields(output, []field{
+		{"ID", transaction.ID},
+		{"DEVICE", transaction.DeviceID},
+		{"COUNTER", strconv.Itoa(transaction.SignatureCounter)},
+		{"SIGNED AT", formatTime(transaction.SignedAt)},
+		{"DATA", printable(transaction.Data)},
+		{"SIGNED DATA", transaction.SecuredData()},
+		{"SIGNATURE", base64.StdEncoding.EncodeToString(transaction.Signature)},
+		{"TIMESTAMPED", strconv.FormatBool(len(transaction.TimestampToken) > 0)},
+	})
+}
+
+// exportTransactions writes a device and its transactions as export bundle,
+// or with -archive as the signed TAR archive of the service. Both are
+// input of verify-export. The bundle is always JSON.
+func (a *app) exportTransactions(ctx context.Context, args []string) error {
+	flags := newFlagSet("tx export", "")
+	deviceID := flags.String("device", "", "ID of the exported device")
+	file := flags.String("file", "", "write the bundle to this file instead of stdout")
+	archive := flags.Bool("archive", false, "download the signed TAR archive instead of a bundle")
+	if err := parse(flags, args, 0, 0); err != nil {
+		return err
+	}
+	if *deviceID == "" {
+		flags.Usage()
+		return errUsage
+	}
+
+	w := a.out.w
+	if *file != "" {
+		f, err := os.Create(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if *archive {
+		if err := a.client.ExportDevice(ctx, *deviceID, w); err != nil {
+			return err
+		}
+		if *file != "" {
+			return w.(*os.File).Close()
+		}
+		return nil
+	}
+
+	device, err := a.client.GetSignatureDevice(ctx, *deviceID)
+	if err != nil {
+		return err
+	}
+	transactions, err := a.client.ListTransaction(ctx, *deviceID)
+	if err != nil {
+		return err
+	}
+	bundle := export.NewBundle(device.Device.Entity(), transactions.Transaction)
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(bundle); err != nil {
+		return err
+	}
+	if *file != "" {
+		return w.(*os.File).Close()
+	}
+	return nil
+}
+
+func sortTransactions(transactions []*entity.Transaction) {
+	sort.Slice(transactions, func(i, j int) bool {
+		if transactions[i].DeviceID != transactions[j].DeviceID {
+			return transactions[i].DeviceID < transactions[j].DeviceID
+		}
+		return transactions[i].SignatureCounter < transactions[j].SignatureCounter
+	})
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/client"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/validation"
+)
+
+// verifyResult is printed by all verify modes.
+type verifyResult struct {
+	Valid  bool   `json:"valid"`
+	Error  string `json:"error,omitempty"`
+	Checks int    `json:"checked_transactions,omitempty"`
+}
+
+// verify checks a signature with the service, or a transaction or a whole
+// signature chain offline with the public key of the device. Invalid
+// signatures make the command fail.
+func (a *app) verify(ctx context.Context, args []string) error {
+	flags := newFlagSet("verify", "[FILE]")
+	deviceID := flags.String("device", "", "ID of the device")
+	signature := flags.String("signature", "", "base64 encoded signature over FILE, or stdin")
+	transactionID := flags.String("transaction", "", "verify this transaction offline")
+	chain := flags.Bool("chain", false, "verify all transactions of -device and their chaining offline")
+	if err := parse(flags, args, 0, 1); err != nil {
+		return err
+	}
+
+	var result *verifyResult
+	var err error
+	switch {
+	case *transactionID != "":
+		result, err = a.verifyTransaction(ctx, *transactionID)
+	case *chain && *deviceID != "":
+		result, err = a.verifyChain(ctx, *deviceID)
+	case *deviceID != "" && *signature != "":
+		result, err = a.verifySignature(ctx, *deviceID, *signature, flags.Arg(0))
+	default:
+		flags.Usage()
+		return errUsage
+	}
+	if err != nil {
+		return err
+	}
+
+	fields := []field{{"VALID", strconv.FormatBool(result.Valid)}}
+	if result.Checks > 0 {
+		fields = append(fields, field{"TRANSACTIONS", strconv.Itoa(result.Checks)})
+	}
+	if result.Error != "" {
+		fields = append(fields, field{"ERROR", result.Error})
+	}
+	if err := a.out.fields(result, fields); err != nil {
+		return err
+	}
+	if !result.Valid {
+		return errInvalid
+	}
+	return nil
+}
+
+// errInvalid makes the command exit with a failure after printing the result.
+var errInvalid = errors.New("verification failed")
+
+func (a *app) verifySignature(ctx context.Context, deviceID, signature, file string) (*verifyResult, error) {
+	decoded, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return nil, fmt.Errorf("decode signature: %w", err)
+	}
+	data, err := a.readInput(file)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := a.client.VerifySignature(ctx, &validation.VerifySignatureInput{
+		DeviceID:  deviceID,
+		Data:      data,
+		Signature: decoded,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &verifyResult{Valid: output.Valid}, nil
+}
+
+func (a *app) verifyTransaction(ctx context.Context, id string) (*verifyResult, error) {
+	transaction, err := a.client.GetTransaction(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	device, err := a.client.GetSignatureDevice(ctx, transaction.Transaction.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.VerifyTransaction(device.Device.Entity(), transaction.Transaction); err != nil {
+		return &verifyResult{Error: err.Error()}, nil
+	}
+	return &verifyResult{Valid: true}, nil
+}
+
+func (a *app) verifyChain(ctx context.Context, deviceID string) (*verifyResult, error) {
+	device, err := a.client.GetSignatureDevice(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	transactions, err := a.client.ListTransaction(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &verifyResult{Checks: len(transactions.Transaction)}
+	if err := client.VerifyChain(device.Device.Entity(), transactions.Transaction); err != nil {
+		result.Error = err.Error()
+		return result, nil
+	}
+	result.Valid = true
+	return result, nil
+}
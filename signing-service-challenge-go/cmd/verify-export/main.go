@@ -0,0 +1,169 @@
+// Command verify-export checks an exported device bundle or archive
+// offline: it recomputes the signed string of every transaction, verifies
+// every signature with the public key in the export and checks the chaining
+// of the signatures. For archives it also checks the signed manifest. It
+// needs neither the signature service nor its storage.
+//
+// Usage:
+//
+//	verify-export [-json] [-v] EXPORT
+//
+// EXPORT is a JSON bundle written by "signctl tx export", a TAR archive of
+// the export endpoint, or "-" for stdin. The exit code is 0 if the export
+// is valid, 1 if it is not and 2 if it could not be read.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/export"
+)
+
+// Exit codes.
+const (
+	exitValid   = 0
+	exitInvalid = 1
+	exitError   = 2
+)
+
+func main() {
+	flags := flag.NewFlagSet("verify-export", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: verify-export [flags] EXPORT")
+		flags.PrintDefaults()
+	}
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	verbose := flags.Bool("v", false, "print the recomputed signed data of every transaction")
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(exitValid)
+		}
+		os.Exit(exitError)
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(exitError)
+	}
+
+	report, err := verify(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "verify-export:", err)
+		os.Exit(exitError)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(report)
+	} else {
+		err = printReport(os.Stdout, report, *verbose)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "verify-export:", err)
+		os.Exit(exitError)
+	}
+
+	if !report.Valid {
+		os.Exit(exitInvalid)
+	}
+}
+
+// verify checks the named export, a JSON bundle or a TAR archive.
+func verify(name string) (*export.Report, error) {
+	var r io.Reader = os.Stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	// Bundles are JSON objects, archives start with the name of their
+	// first file.
+	buffered := bufio.NewReader(r)
+	for {
+		b, err := buffered.Peek(1)
+		if err != nil {
+			return nil, fmt.Errorf("read export: %w", err)
+		}
+		if b[0] == '{' {
+			bundle, err := readBundle(buffered)
+			if err != nil {
+				return nil, err
+			}
+			return export.Verify(bundle), nil
+		}
+		if !unicode.IsSpace(rune(b[0])) {
+			return export.VerifyArchive(buffered)
+		}
+		buffered.Discard(1)
+	}
+}
+
+func readBundle(r io.Reader) (*export.Bundle, error) {
+	bundle := &export.Bundle{}
+	if err := json.NewDecoder(r).Decode(bundle); err != nil {
+		return nil, fmt.Errorf("decode bundle: %w", err)
+	}
+	if bundle.Version != export.BundleVersion {
+		return nil, fmt.Errorf("unsupported bundle version %d", bundle.Version)
+	}
+	return bundle, nil
+}
+
+func printReport(w io.Writer, report *export.Report, verbose bool) error {
+	fmt.Fprintf(w, "Device:       %s (%s)\n", report.DeviceID, report.Algorithm)
+	fmt.Fprintf(w, "Transactions: %d\n", len(report.Transactions))
+	for _, e := range report.Errors {
+		fmt.Fprintf(w, "Error:        %s\n", e)
+	}
+	fmt.Fprintln(w)
+
+	failed := 0
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "COUNTER\tTRANSACTION\tRESULT\tTIMESTAMP")
+	for _, result := range report.Transactions {
+		status := "ok"
+		if !result.Valid {
+			failed++
+			status = "FAILED: " + strings.Join(result.Errors, "; ")
+		}
+		var ts string
+		if result.Timestamp != nil {
+			ts = result.Timestamp.UTC().Format("2006-01-02T15:04:05Z")
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", result.SignatureCounter, result.ID, status, ts)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintln(w)
+
+	if verbose {
+		fmt.Fprintln(w, "Signed data:")
+		for _, result := range report.Transactions {
+			fmt.Fprintf(w, "  %d: %q\n", result.SignatureCounter, result.SignedData)
+		}
+		fmt.Fprintln(w)
+	}
+
+	switch {
+	case report.Valid:
+		fmt.Fprintln(w, "Result: VALID")
+	case failed > 0:
+		fmt.Fprintf(w, "Result: INVALID (%d of %d transactions failed)\n", failed, len(report.Transactions))
+	default:
+		fmt.Fprintln(w, "Result: INVALID")
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/entity"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/repository"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/service"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/validation"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/export"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+)
+
+// newExport signs three transactions with a new device and returns its
+// archive and its JSON bundle.
+func newExport(t *testing.T) (archive, bundle []byte) {
+	t.Helper()
+	repo := repository.NewRepository(persistence.NewDatabase())
+	deviceSvc := service.NewDeviceService(log.New(io.Discard, "", 0), repo)
+	if _, err := deviceSvc.CreateSignatureDevice(&validation.CreateSignatureDeviceInput{ID: "device", Algorithm: "ECC"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range []string{"first", "second", "third"} {
+		if _, err := deviceSvc.SignTransaction(&validation.SignTransactionInput{DeviceID: "device", Data: []byte(data)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if _, err := deviceSvc.ExportDevice(&validation.ExportDeviceInput{ID: "device", Writer: &buf}); err != nil {
+		t.Fatal(err)
+	}
+
+	device, err := repo.GetSignatureDevice("device")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var transactions []*entity.Transaction
+	for counter := 0; counter < device.SignatureCounter; counter++ {
+		transaction, err := repo.GetTransactionByCounter("device", counter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		transactions = append(transactions, transaction)
+	}
+	bundle, err = json.Marshal(export.NewBundle(device, transactions))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes(), bundle
+}
+
+// modifyTransaction changes the data of the transaction with counter 1 in
+// a JSON list of transactions.
+func modifyTransaction(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var transactions []map[string]any
+	if err := json.Unmarshal(data, &transactions); err != nil {
+		t.Fatal(err)
+	}
+	transactions[1]["data"] = []byte("modified")
+	modified, err := json.Marshal(transactions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return modified
+}
+
+// rewriteArchive returns the archive with the content of one file modified.
+func rewriteArchive(t *testing.T, archive []byte, name string, modify func([]byte) []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	reader, writer := tar.NewReader(bytes.NewReader(archive)), tar.NewWriter(&buf)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header.Name == name {
+			content = modify(content)
+			header.Size = int64(len(content))
+		}
+		if err := writer.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writer.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestVerify(t *testing.T) {
+	archive, bundle := newExport(t)
+
+	var exported struct {
+		Transactions json.RawMessage `json:"transactions"`
+	}
+	if err := json.Unmarshal(bundle, &exported); err != nil {
+		t.Fatal(err)
+	}
+	var modifiedBundle map[string]json.RawMessage
+	if err := json.Unmarshal(bundle, &modifiedBundle); err != nil {
+		t.Fatal(err)
+	}
+	modifiedBundle["transactions"] = modifyTransaction(t, exported.Transactions)
+	tamperedBundle, err := json.Marshal(modifiedBundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		export []byte
+		// failed is the counter of the transaction that fails, or -1.
+		failed int
+		// errors is part of a problem of the export as a whole.
+		errors string
+	}{
+		{"archive", archive, -1, ""},
+		{"bundle", bundle, -1, ""},
+		{"bundle with leading space", append([]byte("\n "), bundle...), -1, ""},
+		{"modified bundle", tamperedBundle, 1, ""},
+		{"modified archive", rewriteArchive(t, archive, export.TransactionsJSONFile, func(content []byte) []byte {
+			return modifyTransaction(t, content)
+		}), 1, export.TransactionsJSONFile},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), "export")
+			if err := os.WriteFile(name, tt.export, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			report, err := verify(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(report.Transactions) != 3 {
+				t.Fatalf("report has %d transactions, want 3", len(report.Transactions))
+			}
+			if report.Valid != (tt.failed < 0 && tt.errors == "") {
+				t.Errorf("report is valid: %v, errors %v", report.Valid, report.Errors)
+			}
+			for _, result := range report.Transactions {
+				if result.Valid != (result.SignatureCounter != tt.failed) {
+					t.Errorf("transaction %d is valid: %v, errors %v", result.SignatureCounter, result.Valid, result.Errors)
+				}
+			}
+			if tt.errors != "" && !strings.Contains(strings.Join(report.Errors, "\n"), tt.errors) {
+				t.Errorf("errors %v do not report %q", report.Errors, tt.errors)
+			}
+
+			var out bytes.Buffer
+			if err := printReport(&out, report, false); err != nil {
+				t.Fatal(err)
+			}
+			want := "Result: VALID"
+			if tt.failed >= 0 {
+				want = "Result: INVALID (1 of 3 transactions failed)"
+			}
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("report does not say %q:\n%s", want, out.String())
+			}
+		})
+	}
+
+	if _, err := verify(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("verified a missing export")
+	}
+}
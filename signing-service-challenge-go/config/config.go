@@ -0,0 +1,362 @@
+// Package config loads the configuration of the signature service from a
+// YAML file, environment variables and command line flags.
+//
+// A configuration file could look like this:
+//
+//	listen_address: ":8443"
+//	tls:
+//	  cert_file: /etc/signing/tls.crt
+//	  key_file: /etc/signing/tls.key
+//	  client_ca_file: /etc/signing/registers-ca.crt
+//	  clients:
+//	    - common_name: register-0042
+//	      tenant: acme
+//	      permissions: [devices:read, sign]
+//	keys:
+//	  algorithms: [ECC]
+//	auth:
+//	  api_keys_sha256:
+//	    - 9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08
+//	rate_limit:
+//	  requests_per_second: 50
+//	log:
+//	  level: debug
+package config
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/auth"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/events"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tlsconfig"
+)
+
+// Storage drivers.
+const (
+	StorageMemory = "memory"
+)
+
+// Log levels. Errors are always logged, info adds startup messages and
+// debug adds a line per request.
+const (
+	LogLevelError = "error"
+	LogLevelInfo  = "info"
+	LogLevelDebug = "debug"
+)
+
+// Config is the configuration of the signature service.
+type Config struct {
+	ListenAddress string `yaml:"listen_address"`
+	GRPCAddress   string `yaml:"grpc_address"`
+	// ShutdownTimeout bounds how long running requests are drained after
+	// SIGINT or SIGTERM.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	TLS             TLS           `yaml:"tls"`
+	Storage         Storage       `yaml:"storage"`
+	Keys            KeyPolicy     `yaml:"keys"`
+	Auth            Auth          `yaml:"auth"`
+	RateLimit       RateLimit     `yaml:"rate_limit"`
+	Log             Log           `yaml:"log"`
+	CA              CA            `yaml:"ca"`
+	// TrustAnchorsFile is a PEM bundle of trust anchors that uploaded
+	// device certificates must chain to.
+	TrustAnchorsFile string   `yaml:"trust_anchors_file"`
+	TSA              TSA      `yaml:"tsa"`
+	Events           Events   `yaml:"events"`
+	Webhooks         Webhooks `yaml:"webhooks"`
+	// BackupKeyFile holds the 32 byte AES key backups are encrypted with.
+	// The backup endpoints are disabled without it.
+	BackupKeyFile string `yaml:"backup_key_file"`
+}
+
+// TLS enables HTTPS and gRPC over TLS if both files are set. The
+// certificate is reloaded when the files change.
+type TLS struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// MinVersion is the minimum TLS version, "1.2" or "1.3".
+	MinVersion string `yaml:"min_version"`
+	// CipherSuites restricts the TLS 1.2 cipher suites by IANA name.
+	CipherSuites []string `yaml:"cipher_suites"`
+	// ClientCAFile is a PEM bundle of the CAs that issue client
+	// certificates. Setting it enables mutual TLS.
+	ClientCAFile string `yaml:"client_ca_file"`
+	// ClientAuth is "optional", which lets clients without a certificate
+	// use API keys, or "require".
+	ClientAuth string `yaml:"client_auth"`
+	// Clients maps client certificates to tenants and permissions.
+	Clients []TLSClient `yaml:"clients"`
+}
+
+// Client certificate modes.
+const (
+	ClientAuthOptional = "optional"
+	ClientAuthRequire  = "require"
+)
+
+// TLSClient maps the client certificates with a subject common name to a
+// tenant and permissions.
+type TLSClient struct {
+	CommonName  string   `yaml:"common_name"`
+	Tenant      string   `yaml:"tenant"`
+	Permissions []string `yaml:"permissions"`
+}
+
+// Enabled reports whether TLS is configured.
+func (t TLS) Enabled() bool {
+	return t.CertFile != ""
+}
+
+// Storage selects the storage backend. DSN is specific to the driver.
+type Storage struct {
+	Driver string `yaml:"driver"`
+	DSN    string `yaml:"dsn"`
+}
+
+// KeyPolicy restricts the keys of new devices.
+type KeyPolicy struct {
+	// Algorithms lists the algorithms devices may be created with.
+	Algorithms []string `yaml:"algorithms"`
+	// RSABits is the size of new RSA keys.
+	RSABits int `yaml:"rsa_bits"`
+}
+
+// Auth requires API keys if any are configured. Keys are given as hex
+// encoded SHA-256 hashes, so the configuration holds no secrets. Configured
+// keys have all permissions; they bootstrap the service by issuing scoped
+// keys through the admin API.
+type Auth struct {
+	APIKeysSHA256 []string `yaml:"api_keys_sha256"`
+}
+
+// Enabled reports whether API keys are required.
+func (a Auth) Enabled() bool {
+	return len(a.APIKeysSHA256) > 0
+}
+
+// KeyHashes returns the decoded API key hashes.
+func (a Auth) KeyHashes() [][]byte {
+	hashes := make([][]byte, 0, len(a.APIKeysSHA256))
+	for _, key := range a.APIKeysSHA256 {
+		hash, _ := hex.DecodeString(key)
+		hashes = append(hashes, hash)
+	}
+	return hashes
+}
+
+// RateLimit limits the requests per client IP address with a token
+// bucket. Zero RequestsPerSecond disables the limit; Burst defaults to
+// RequestsPerSecond rounded up.
+type RateLimit struct {
+	RequestsPerSecond float64 `yaml:"requests_per_second"`
+	Burst             int     `yaml:"burst"`
+}
+
+// Enabled reports whether requests are limited.
+func (r RateLimit) Enabled() bool {
+	return r.RequestsPerSecond > 0
+}
+
+// Log configures logging.
+type Log struct {
+	Level  string `yaml:"level"`
+	Prefix string `yaml:"prefix"`
+}
+
+// CA configures the built-in certificate authority. Device certificates
+// are only issued if both files are set.
+type CA struct {
+	CertFile string        `yaml:"cert_file"`
+	KeyFile  string        `yaml:"key_file"`
+	Validity time.Duration `yaml:"validity"`
+}
+
+// TSA configures time-stamping of signatures. A URL selects an external
+// Time-Stamp Authority whose tokens are verified with the certificate;
+// otherwise a certificate and key run a local one that works offline.
+type TSA struct {
+	URL string `yaml:"url"`
+	// Timeout bounds how long signing waits for a token. Further
+	// transactions of the same device wait as well.
+	Timeout  time.Duration `yaml:"timeout"`
+	CertFile string        `yaml:"cert_file"`
+	KeyFile  string        `yaml:"key_file"`
+	// Policy is the TSA policy OID in dotted notation.
+	Policy string `yaml:"policy"`
+}
+
+// Events configures the event stream.
+type Events struct {
+	// ReplayBuffer is how many recent events are kept for clients
+	// resuming the event stream.
+	ReplayBuffer int `yaml:"replay_buffer"`
+}
+
+// Webhooks configures the delivery of events to webhooks.
+type Webhooks struct {
+	// AllowPrivateTargets lets webhooks target loopback, link-local and
+	// private addresses, e.g. receivers on the same host.
+	AllowPrivateTargets bool `yaml:"allow_private_targets"`
+}
+
+// Default returns the configuration used for settings that are not given.
+func Default() *Config {
+	return &Config{
+		ListenAddress:   ":8080",
+		ShutdownTimeout: 30 * time.Second,
+		TLS: TLS{
+			MinVersion: "1.2",
+			ClientAuth: ClientAuthOptional,
+		},
+		Storage: Storage{Driver: StorageMemory},
+		Keys: KeyPolicy{
+			Algorithms: []string{"ECC", "RSA"},
+			RSABits:    2048,
+		},
+		Log: Log{
+			Level:  LogLevelInfo,
+			Prefix: "[SIGNING CHALLENGE] ",
+		},
+		TSA:    TSA{Timeout: 10 * time.Second},
+		Events: Events{ReplayBuffer: events.DefaultReplayBuffer},
+	}
+}
+
+// Validate checks the configuration and fills in derived defaults. It
+// reports all problems at once.
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.ListenAddress == "" {
+		fail("listen_address is required")
+	}
+	if c.ShutdownTimeout <= 0 {
+		fail("shutdown_timeout must be positive")
+	}
+	c.validateTLS(fail)
+
+	switch c.Storage.Driver {
+	case StorageMemory:
+		if c.Storage.DSN != "" {
+			fail("storage.dsn is not supported by the %s driver", StorageMemory)
+		}
+	default:
+		fail("unknown storage.driver %q, supported: %s", c.Storage.Driver, StorageMemory)
+	}
+
+	if len(c.Keys.Algorithms) == 0 {
+		fail("keys.algorithms must not be empty")
+	}
+	for _, algorithm := range c.Keys.Algorithms {
+		if algorithm != "ECC" && algorithm != "RSA" {
+			fail("unknown algorithm %q in keys.algorithms, supported: ECC, RSA", algorithm)
+		}
+	}
+	switch c.Keys.RSABits {
+	case 2048, 3072, 4096:
+	default:
+		fail("keys.rsa_bits must be 2048, 3072 or 4096")
+	}
+
+	for _, key := range c.Auth.APIKeysSHA256 {
+		if hash, err := hex.DecodeString(key); err != nil || len(hash) != 32 {
+			fail("auth.api_keys_sha256 must hold hex encoded SHA-256 hashes, got %q", key)
+		}
+	}
+
+	if c.RateLimit.RequestsPerSecond < 0 || math.IsNaN(c.RateLimit.RequestsPerSecond) {
+		fail("rate_limit.requests_per_second must not be negative")
+	}
+	if c.RateLimit.Burst < 0 {
+		fail("rate_limit.burst must not be negative")
+	}
+	if c.RateLimit.Enabled() && c.RateLimit.Burst == 0 {
+		c.RateLimit.Burst = int(math.Ceil(c.RateLimit.RequestsPerSecond))
+	}
+
+	switch c.Log.Level {
+	case LogLevelError, LogLevelInfo, LogLevelDebug:
+	default:
+		fail("log.level must be %s, %s or %s", LogLevelError, LogLevelInfo, LogLevelDebug)
+	}
+
+	if (c.CA.CertFile == "") != (c.CA.KeyFile == "") {
+		fail("ca.cert_file and ca.key_file must be set together")
+	}
+	if c.CA.Validity < 0 {
+		fail("ca.validity must not be negative")
+	}
+	switch {
+	case c.TSA.URL != "" && c.TSA.CertFile == "":
+		fail("tsa.url requires tsa.cert_file to verify tokens")
+	case c.TSA.URL != "" && c.TSA.KeyFile != "":
+		fail("tsa.url and a local tsa.key_file are mutually exclusive")
+	case c.TSA.URL == "" && (c.TSA.CertFile == "") != (c.TSA.KeyFile == ""):
+		fail("tsa.cert_file and tsa.key_file must be set together")
+	}
+	if c.TSA.Timeout <= 0 {
+		fail("tsa.timeout must be positive")
+	}
+	if c.Events.ReplayBuffer <= 0 {
+		fail("events.replay_buffer must be positive")
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateTLS checks the TLS settings.
+func (c *Config) validateTLS(fail func(format string, args ...any)) {
+	t := c.TLS
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		fail("tls.cert_file and tls.key_file must be set together")
+	}
+	if !t.Enabled() && (t.ClientCAFile != "" || len(t.CipherSuites) > 0 || len(t.Clients) > 0) {
+		fail("tls.client_ca_file, tls.cipher_suites and tls.clients require tls.cert_file")
+	}
+	if _, err := tlsconfig.ParseVersion(t.MinVersion); err != nil {
+		fail("tls.min_version: %v", err)
+	}
+	if _, err := tlsconfig.ParseCipherSuites(t.CipherSuites); err != nil {
+		fail("tls.cipher_suites: %v", err)
+	}
+	if t.MinVersion == "1.3" && len(t.CipherSuites) > 0 {
+		fail("tls.cipher_suites only apply to TLS 1.2, but tls.min_version is 1.3")
+	}
+
+	switch t.ClientAuth {
+	case ClientAuthOptional:
+	case ClientAuthRequire:
+		if t.ClientCAFile == "" {
+			fail("tls.client_auth %s requires tls.client_ca_file", ClientAuthRequire)
+		}
+	default:
+		fail("tls.client_auth must be %s or %s", ClientAuthOptional, ClientAuthRequire)
+	}
+	if t.ClientCAFile != "" && len(t.Clients) == 0 {
+		fail("tls.clients must map the client certificates accepted with tls.client_ca_file")
+	}
+
+	names := make(map[string]bool)
+	for _, client := range t.Clients {
+		if client.CommonName == "" {
+			fail("tls.clients: common_name is required")
+			continue
+		}
+		if names[client.CommonName] {
+			fail("tls.clients: duplicate common_name %q", client.CommonName)
+		}
+		names[client.CommonName] = true
+		for _, permission := range client.Permissions {
+			if !auth.IsPermission(permission) {
+				fail("tls.clients: unknown permission %q for %q", permission, client.CommonName)
+			}
+		}
+	}
+}
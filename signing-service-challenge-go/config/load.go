@@ -0,0 +1,201 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv names the environment variable holding the path of the YAML
+// configuration file. The -config flag takes precedence.
+const FileEnv = "SIGNING_CONFIG_FILE"
+
+// setting is a configuration value that can be set from the environment
+// and the command line.
+type setting struct {
+	env   string
+	flag  string
+	usage string
+	set   func(c *Config, value string) error
+}
+
+// settings lists every value that can be set outside the YAML file. Lists
+// of structures, like the TLS client mappings, can only be set in the file.
+var settings = []setting{
+	{"SIGNING_LISTEN_ADDRESS", "listen", "listen address of the REST API", stringValue(func(c *Config) *string { return &c.ListenAddress })},
+	{"SIGNING_GRPC_ADDRESS", "grpc-listen", "listen address of the gRPC API, disabled if empty", stringValue(func(c *Config) *string { return &c.GRPCAddress })},
+	{"SIGNING_SHUTDOWN_TIMEOUT", "shutdown-timeout", "how long running requests are drained on shutdown", durationValue(func(c *Config) *time.Duration { return &c.ShutdownTimeout })},
+	{"SIGNING_TLS_CERT_FILE", "tls-cert", "PEM certificate chain for TLS", stringValue(func(c *Config) *string { return &c.TLS.CertFile })},
+	{"SIGNING_TLS_KEY_FILE", "tls-key", "PEM private key for TLS", stringValue(func(c *Config) *string { return &c.TLS.KeyFile })},
+	{"SIGNING_TLS_MIN_VERSION", "tls-min-version", "minimum TLS version: 1.2 or 1.3", stringValue(func(c *Config) *string { return &c.TLS.MinVersion })},
+	{"SIGNING_TLS_CIPHER_SUITES", "tls-cipher-suites", "comma separated TLS 1.2 cipher suites", listValue(func(c *Config) *[]string { return &c.TLS.CipherSuites })},
+	{"SIGNING_TLS_CLIENT_CA_FILE", "tls-client-ca", "PEM bundle of the CAs of client certificates", stringValue(func(c *Config) *string { return &c.TLS.ClientCAFile })},
+	{"SIGNING_TLS_CLIENT_AUTH", "tls-client-auth", "client certificates: optional or require", stringValue(func(c *Config) *string { return &c.TLS.ClientAuth })},
+	{"SIGNING_STORAGE_DRIVER", "storage", "storage driver", stringValue(func(c *Config) *string { return &c.Storage.Driver })},
+	{"SIGNING_STORAGE_DSN", "storage-dsn", "data source name of the storage driver", stringValue(func(c *Config) *string { return &c.Storage.DSN })},
+	{"SIGNING_KEY_ALGORITHMS", "key-algorithms", "comma separated algorithms devices may be created with", listValue(func(c *Config) *[]string { return &c.Keys.Algorithms })},
+	{"SIGNING_RSA_KEY_BITS", "rsa-key-bits", "size of new RSA keys", intValue(func(c *Config) *int { return &c.Keys.RSABits })},
+	{"SIGNING_API_KEYS_SHA256", "api-keys-sha256", "comma separated hex SHA-256 hashes of the accepted API keys", listValue(func(c *Config) *[]string { return &c.Auth.APIKeysSHA256 })},
+	{"SIGNING_RATE_LIMIT", "rate-limit", "requests per second and client IP, unlimited if 0", floatValue(func(c *Config) *float64 { return &c.RateLimit.RequestsPerSecond })},
+	{"SIGNING_RATE_LIMIT_BURST", "rate-limit-burst", "requests a client IP may send at once", intValue(func(c *Config) *int { return &c.RateLimit.Burst })},
+	{"SIGNING_LOG_LEVEL", "log-level", "log level: error, info or debug", stringValue(func(c *Config) *string { return &c.Log.Level })},
+	{"SIGNING_LOG_PREFIX", "log-prefix", "prefix of log lines", stringValue(func(c *Config) *string { return &c.Log.Prefix })},
+	{"SIGNING_CA_CERT_FILE", "ca-cert", "certificate of the built-in CA", stringValue(func(c *Config) *string { return &c.CA.CertFile })},
+	{"SIGNING_CA_KEY_FILE", "ca-key", "private key of the built-in CA", stringValue(func(c *Config) *string { return &c.CA.KeyFile })},
+	{"SIGNING_CA_VALIDITY", "ca-validity", "validity of issued device certificates", durationValue(func(c *Config) *time.Duration { return &c.CA.Validity })},
+	{"SIGNING_TRUST_ANCHORS_FILE", "trust-anchors", "PEM bundle of trust anchors for uploaded device certificates", stringValue(func(c *Config) *string { return &c.TrustAnchorsFile })},
+	{"SIGNING_TSA_URL", "tsa-url", "URL of an external time-stamp authority", stringValue(func(c *Config) *string { return &c.TSA.URL })},
+	{"SIGNING_TSA_TIMEOUT", "tsa-timeout", "how long signing waits for a time-stamp token", durationValue(func(c *Config) *time.Duration { return &c.TSA.Timeout })},
+	{"SIGNING_TSA_CERT_FILE", "tsa-cert", "certificate of the time-stamp authority", stringValue(func(c *Config) *string { return &c.TSA.CertFile })},
+	{"SIGNING_TSA_KEY_FILE", "tsa-key", "private key of the local time-stamp authority", stringValue(func(c *Config) *string { return &c.TSA.KeyFile })},
+	{"SIGNING_TSA_POLICY", "tsa-policy", "policy OID of the local time-stamp authority", stringValue(func(c *Config) *string { return &c.TSA.Policy })},
+	{"SIGNING_EVENT_REPLAY_BUFFER", "event-replay-buffer", "number of recent events kept for resuming clients", intValue(func(c *Config) *int { return &c.Events.ReplayBuffer })},
+	{"SIGNING_BACKUP_KEY_FILE", "backup-key", "file holding the 32 byte backup key", stringValue(func(c *Config) *string { return &c.BackupKeyFile })},
+	{"SIGNING_WEBHOOK_ALLOW_PRIVATE_TARGETS", "webhook-allow-private-targets", "allow webhooks to loopback, link-local and private addresses", boolValue(func(c *Config) *bool { return &c.Webhooks.AllowPrivateTargets })},
+}
+
+// Load returns the validated configuration. Settings are taken from, in
+// increasing order of precedence: the defaults, the YAML file named by
+// -config or $SIGNING_CONFIG_FILE, the environment and the command line
+// flags. flag.ErrHelp is returned if the usage was requested.
+func Load(name string, args []string, getenv func(string) string, output io.Writer) (*Config, error) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.Usage = func() {
+		fmt.Fprintf(output, "Usage: %s [flags]\n\nSettings are read from the defaults, the configuration file, the\nenvironment and these flags, each overriding the ones before.\n\nFlags:\n", name)
+		flags.PrintDefaults()
+	}
+
+	file := flags.String("config", getenv(FileEnv), "YAML configuration file (env "+FileEnv+")")
+	type flagValue struct {
+		setting setting
+		value   string
+	}
+	var flagValues []flagValue
+	for _, s := range settings {
+		s := s
+		flags.Func(s.flag, s.usage+" (env "+s.env+")", func(value string) error {
+			flagValues = append(flagValues, flagValue{s, value})
+			return nil
+		})
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if flags.NArg() > 0 {
+		flags.Usage()
+		return nil, fmt.Errorf("unexpected argument %q", flags.Arg(0))
+	}
+
+	c := Default()
+	if *file != "" {
+		if err := c.readFile(*file); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range settings {
+		if value := getenv(s.env); value != "" {
+			if err := s.set(c, value); err != nil {
+				return nil, fmt.Errorf("%s: %w", s.env, err)
+			}
+		}
+	}
+	for _, f := range flagValues {
+		if err := f.setting.set(c, f.value); err != nil {
+			return nil, fmt.Errorf("-%s: %w", f.setting.flag, err)
+		}
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return c, nil
+}
+
+// readFile overrides the configuration with the settings of a YAML file.
+// Unknown keys are rejected, so typos do not go unnoticed.
+func (c *Config) readFile(filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	return nil
+}
+
+func stringValue(field func(*Config) *string) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}
+}
+
+func listValue(field func(*Config) *[]string) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		*field(c) = list
+		return nil
+	}
+}
+
+func intValue(field func(*Config) *int) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+func floatValue(field func(*Config) *float64) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		*field(c) = f
+		return nil
+	}
+}
+
+func boolValue(field func(*Config) *bool) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		*field(c) = b
+		return nil
+	}
+}
+
+func durationValue(field func(*Config) *time.Duration) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", value)
+		}
+		*field(c) = d
+		return nil
+	}
+}
@@ -0,0 +1,512 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/entity"
+)
+
+// Object identifiers used in CMS SignedData (RFC 5652, RFC 5754, RFC 4055).
+var (
+	oidData                   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidAttributeContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidAttributeMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidAttributeSigningTime   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+	oidSHA256                 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384                 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512                 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+	oidRSAEncryption          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidSHA256WithRSA          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+	oidSHA384WithRSA          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 12}
+	oidSHA512WithRSA          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 13}
+	oidRSASSAPSS              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 10}
+	oidMGF1                   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 8}
+	oidECPublicKey            = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidECDSAWithSHA256        = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidECDSAWithSHA384        = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 3}
+	oidECDSAWithSHA512        = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 4}
+)
+
+type cmsContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue
+}
+
+type cmsSignedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo cmsEncapsulatedContentInfo
+	Certificates     asn1.RawValue   `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue   `asn1:"optional,tag:1"`
+	SignerInfos      []cmsSignerInfo `asn1:"set"`
+}
+
+type cmsEncapsulatedContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"optional"`
+}
+
+type cmsSignerInfo struct {
+	Version            int
+	SignerIdentifier   asn1.RawValue
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttributes   asn1.RawValue `asn1:"tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttributes asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type cmsIssuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type cmsAttribute struct {
+	Type   asn1.ObjectIdentifier
+	Values []asn1.RawValue `asn1:"set"`
+}
+
+// CMSAttribute is an additional signed attribute of a CMS signer. Value is
+// the DER encoding of the single attribute value.
+type CMSAttribute struct {
+	Type  asn1.ObjectIdentifier
+	Value []byte
+}
+
+// CMSOptions describes a CMS SignedData structure with a single signer.
+type CMSOptions struct {
+	// ContentType is the type of the signed content, id-data if nil.
+	ContentType asn1.ObjectIdentifier
+	// Content is encapsulated in the structure. Leave it nil for a
+	// detached signature.
+	Content []byte
+	// Digest is the hash of the content computed with DigestAlgorithm.
+	Digest             []byte
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	// Sign signs the DER encoded signed attributes.
+	Sign func(signedAttributes []byte) ([]byte, error)
+	// Certificate identifies the signer and is embedded. If it is nil the
+	// signer is identified by SubjectKeyID.
+	Certificate  *x509.Certificate
+	SubjectKeyID []byte
+	// SigningTime is added as signed attribute unless it is zero.
+	SigningTime time.Time
+	Attributes  []CMSAttribute
+}
+
+// BuildSignedData creates a CMS SignedData structure (RFC 5652) with a
+// single signer whose signed attributes carry the content type, the message
+// digest, the signing time and any further attributes. It returns the DER
+// encoded ContentInfo.
+func BuildSignedData(opts CMSOptions) ([]byte, error) {
+	contentType := opts.ContentType
+	if contentType == nil {
+		contentType = oidData
+	}
+
+	signedAttributes, err := cmsSignedAttributes(contentType, opts.Digest, opts.SigningTime, opts.Attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	// The signature is computed over the DER encoding of the attributes
+	// with the SET OF tag, not the implicit [0] tag they are stored with.
+	signature, err := opts.Sign(signedAttributes)
+	if err != nil {
+		return nil, err
+	}
+
+	signerInfo := cmsSignerInfo{
+		DigestAlgorithm:    opts.DigestAlgorithm,
+		SignedAttributes:   asn1.RawValue{FullBytes: append([]byte{0xa0}, signedAttributes[1:]...)},
+		SignatureAlgorithm: opts.SignatureAlgorithm,
+		Signature:          signature,
+	}
+	signedData := cmsSignedData{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{opts.DigestAlgorithm},
+		EncapContentInfo: cmsEncapsulatedContentInfo{ContentType: contentType},
+	}
+
+	if opts.Content != nil {
+		content, err := asn1.Marshal(opts.Content)
+		if err != nil {
+			return nil, err
+		}
+		signedData.EncapContentInfo.Content = asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: content}
+	}
+
+	if opts.Certificate != nil {
+		sid, err := asn1.Marshal(cmsIssuerAndSerialNumber{
+			Issuer:       asn1.RawValue{FullBytes: opts.Certificate.RawIssuer},
+			SerialNumber: opts.Certificate.SerialNumber,
+		})
+		if err != nil {
+			return nil, err
+		}
+		signerInfo.Version = 1
+		signerInfo.SignerIdentifier = asn1.RawValue{FullBytes: sid}
+		signedData.Certificates = asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: opts.Certificate.Raw}
+	} else {
+		signerInfo.Version = 3
+		signerInfo.SignerIdentifier = asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, Bytes: opts.SubjectKeyID}
+	}
+	// RFC 5652, section 5.1.
+	if signerInfo.Version == 3 || !contentType.Equal(oidData) {
+		signedData.Version = 3
+	}
+	signedData.SignerInfos = []cmsSignerInfo{signerInfo}
+
+	content, err := asn1.Marshal(signedData)
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(cmsContentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: content},
+	})
+}
+
+type pssParameters struct {
+	Hash         pkix.AlgorithmIdentifier `asn1:"explicit,tag:0"`
+	MGF          pkix.AlgorithmIdentifier `asn1:"explicit,tag:1"`
+	SaltLength   int                      `asn1:"explicit,tag:2"`
+	TrailerField int                      `asn1:"optional,explicit,tag:3,default:1"`
+}
+
+// CMSHash returns the digest algorithm that documents signed by a device
+// must be hashed with.
+func CMSHash(device *entity.Device) (crypto.Hash, error) {
+	switch device.Algorithm {
+	case "ECC":
+		return crypto.SHA384, nil
+	case "RSA":
+		return crypto.SHA256, nil
+	default:
+		return 0, fmt.Errorf("unsupported algorithm: %s", device.Algorithm)
+	}
+}
+
+// SignDetachedCMS creates a detached CMS SignedData structure (RFC 5652)
+// over content with the given digest, computed with CMSHash. The signed
+// attributes carry the content type, the message digest and the signing
+// time. If certificate is not nil, it identifies the signer and is embedded;
+// otherwise the signer is identified by the key identifier of the device
+// public key. It returns the DER encoded ContentInfo.
+func SignDetachedCMS(device *entity.Device, digest []byte, signingTime time.Time, certificate *x509.Certificate) ([]byte, error) {
+	hash, err := CMSHash(device)
+	if err != nil {
+		return nil, err
+	}
+	if len(digest) != hash.Size() {
+		return nil, fmt.Errorf("crypto: digest must be %d bytes", hash.Size())
+	}
+
+	digestAlgorithm, signatureAlgorithm, err := cmsAlgorithms(device)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := getDERSigner(device)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := CMSOptions{
+		Digest:             digest,
+		DigestAlgorithm:    digestAlgorithm,
+		SignatureAlgorithm: signatureAlgorithm,
+		Sign:               signer.Sign,
+		Certificate:        certificate,
+		SigningTime:        signingTime,
+	}
+	if certificate == nil {
+		opts.SubjectKeyID, err = subjectKeyID(device)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return BuildSignedData(opts)
+}
+
+// cmsSignedAttributes returns the DER encoded SET OF signed attributes,
+// sorted as required for DER.
+func cmsSignedAttributes(contentType asn1.ObjectIdentifier, digest []byte, signingTime time.Time, extra []CMSAttribute) ([]byte, error) {
+	encodedContentType, err := asn1.Marshal(contentType)
+	if err != nil {
+		return nil, err
+	}
+	messageDigest, err := asn1.Marshal(digest)
+	if err != nil {
+		return nil, err
+	}
+
+	attributes := append([]CMSAttribute{
+		{Type: oidAttributeContentType, Value: encodedContentType},
+		{Type: oidAttributeMessageDigest, Value: messageDigest},
+	}, extra...)
+
+	if !signingTime.IsZero() {
+		encodedTime, err := asn1.Marshal(signingTime.UTC())
+		if err != nil {
+			return nil, err
+		}
+		attributes = append(attributes, CMSAttribute{Type: oidAttributeSigningTime, Value: encodedTime})
+	}
+
+	var encoded [][]byte
+	for _, attribute := range attributes {
+		e, err := asn1.Marshal(cmsAttribute{
+			Type:   attribute.Type,
+			Values: []asn1.RawValue{{FullBytes: attribute.Value}},
+		})
+		if err != nil {
+			return nil, err
+		}
+		encoded = append(encoded, e)
+	}
+	sort.Slice(encoded, func(i, j int) bool {
+		return bytes.Compare(encoded[i], encoded[j]) < 0
+	})
+
+	return asn1.Marshal(asn1.RawValue{
+		Class:      asn1.ClassUniversal,
+		Tag:        asn1.TagSet,
+		IsCompound: true,
+		Bytes:      bytes.Join(encoded, nil),
+	})
+}
+
+// cmsAlgorithms returns the digest and signature algorithm identifiers
+// matching the signatures a device produces.
+func cmsAlgorithms(device *entity.Device) (pkix.AlgorithmIdentifier, pkix.AlgorithmIdentifier, error) {
+	var none pkix.AlgorithmIdentifier
+	sha256 := pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue}
+
+	switch device.Algorithm {
+	case "ECC":
+		return pkix.AlgorithmIdentifier{Algorithm: oidSHA384, Parameters: asn1.NullRawValue},
+			pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA384}, nil
+	case "RSA":
+		switch RSAScheme(device) {
+		case RSASchemePKCS1v15:
+			return sha256, pkix.AlgorithmIdentifier{Algorithm: oidSHA256WithRSA, Parameters: asn1.NullRawValue}, nil
+		case RSASchemePSS:
+			mgfHash, err := asn1.Marshal(sha256)
+			if err != nil {
+				return none, none, err
+			}
+			params, err := asn1.Marshal(pssParameters{
+				Hash:         sha256,
+				MGF:          pkix.AlgorithmIdentifier{Algorithm: oidMGF1, Parameters: asn1.RawValue{FullBytes: mgfHash}},
+				SaltLength:   crypto.SHA256.Size(),
+				TrailerField: 1,
+			})
+			if err != nil {
+				return none, none, err
+			}
+			return sha256, pkix.AlgorithmIdentifier{Algorithm: oidRSASSAPSS, Parameters: asn1.RawValue{FullBytes: params}}, nil
+		}
+		return none, none, fmt.Errorf("unsupported RSA signature scheme: %s", device.SignatureScheme)
+	default:
+		return none, none, fmt.Errorf("unsupported algorithm: %s", device.Algorithm)
+	}
+}
+
+// getDERSigner returns a signer for the device that produces the signature
+// encodings CMS expects.
+func getDERSigner(device *entity.Device) (Signer, error) {
+	switch device.Algorithm {
+	case "ECC":
+		return &ECCSigner{Device: device, Format: ECDSAFormatDER}, nil
+	case "RSA":
+		return &RSASigner{Device: device}, nil
+	default:
+		return nil, fmt.Errorf("unsupported algorithm: %s", device.Algorithm)
+	}
+}
+
+// subjectKeyID computes the key identifier of the device public key as the
+// SHA-1 hash of the subjectPublicKey bit string (RFC 5280, section 4.2.1.2).
+func subjectKeyID(device *entity.Device) ([]byte, error) {
+	publicKey, err := UnmarshalPublicKey(device.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var spki struct {
+		Algorithm        pkix.AlgorithmIdentifier
+		SubjectPublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(der, &spki); err != nil {
+		return nil, err
+	}
+
+	sum := sha1.Sum(spki.SubjectPublicKey.Bytes)
+	return sum[:], nil
+}
+
+// VerifySignedData verifies a CMS SignedData structure (RFC 5652) with
+// encapsulated content and a single signer whose certificate is given. The
+// content type and message digest attributes must match the content and the
+// signed attributes must be signed with the key of the certificate. It
+// returns the content type and the content. The certificate itself is not
+// validated.
+func VerifySignedData(raw []byte, certificate *x509.Certificate) (asn1.ObjectIdentifier, []byte, error) {
+	var contentInfo cmsContentInfo
+	if rest, err := asn1.Unmarshal(raw, &contentInfo); err != nil {
+		return nil, nil, err
+	} else if len(rest) > 0 {
+		return nil, nil, errors.New("crypto: trailing data after SignedData")
+	}
+	if !contentInfo.ContentType.Equal(oidSignedData) {
+		return nil, nil, errors.New("crypto: not a SignedData structure")
+	}
+
+	var signedData cmsSignedData
+	if _, err := asn1.Unmarshal(contentInfo.Content.Bytes, &signedData); err != nil {
+		return nil, nil, err
+	}
+	if len(signedData.SignerInfos) != 1 {
+		return nil, nil, fmt.Errorf("crypto: SignedData has %d signers, expected one", len(signedData.SignerInfos))
+	}
+	var content []byte
+	if _, err := asn1.Unmarshal(signedData.EncapContentInfo.Content.Bytes, &content); err != nil {
+		return nil, nil, errors.New("crypto: SignedData has no encapsulated content")
+	}
+	contentType := signedData.EncapContentInfo.ContentType
+
+	signer := signedData.SignerInfos[0]
+	hash, err := cmsDigestHash(signer.DigestAlgorithm.Algorithm)
+	if err != nil {
+		return nil, nil, err
+	}
+	algorithm, err := cmsSignatureAlgorithm(signer.SignatureAlgorithm.Algorithm, hash)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The signature covers the attributes with the SET OF tag, not the
+	// implicit [0] tag they are stored with.
+	if len(signer.SignedAttributes.FullBytes) == 0 {
+		return nil, nil, errors.New("crypto: SignedData has no signed attributes")
+	}
+	signedAttributes := append([]byte{0x31}, signer.SignedAttributes.FullBytes[1:]...)
+	var attributes []cmsAttribute
+	if _, err := asn1.UnmarshalWithParams(signedAttributes, &attributes, "set"); err != nil {
+		return nil, nil, err
+	}
+
+	var attributeContentType asn1.ObjectIdentifier
+	if err := cmsAttributeValue(attributes, oidAttributeContentType, &attributeContentType); err != nil {
+		return nil, nil, err
+	}
+	if !attributeContentType.Equal(contentType) {
+		return nil, nil, errors.New("crypto: content type attribute does not match the content")
+	}
+	var messageDigest []byte
+	if err := cmsAttributeValue(attributes, oidAttributeMessageDigest, &messageDigest); err != nil {
+		return nil, nil, err
+	}
+	digester := hash.New()
+	digester.Write(content)
+	if !bytes.Equal(messageDigest, digester.Sum(nil)) {
+		return nil, nil, errors.New("crypto: message digest attribute does not match the content")
+	}
+
+	if err := certificate.CheckSignature(algorithm, signedAttributes, signer.Signature); err != nil {
+		return nil, nil, fmt.Errorf("crypto: SignedData signature: %w", err)
+	}
+	return contentType, content, nil
+}
+
+// cmsAttributeValue decodes the single value of the signed attribute with
+// the given type, which must occur exactly once.
+func cmsAttributeValue(attributes []cmsAttribute, oid asn1.ObjectIdentifier, value any) error {
+	var found *cmsAttribute
+	for i := range attributes {
+		if attributes[i].Type.Equal(oid) {
+			if found != nil {
+				return fmt.Errorf("crypto: attribute %s occurs more than once", oid)
+			}
+			found = &attributes[i]
+		}
+	}
+	if found == nil || len(found.Values) != 1 {
+		return fmt.Errorf("crypto: attribute %s must have a single value", oid)
+	}
+	if rest, err := asn1.Unmarshal(found.Values[0].FullBytes, value); err != nil {
+		return err
+	} else if len(rest) > 0 {
+		return fmt.Errorf("crypto: trailing data in attribute %s", oid)
+	}
+	return nil
+}
+
+// cmsDigestHash returns the hash function of a CMS digest algorithm.
+func cmsDigestHash(oid asn1.ObjectIdentifier) (crypto.Hash, error) {
+	switch {
+	case oid.Equal(oidSHA256):
+		return crypto.SHA256, nil
+	case oid.Equal(oidSHA384):
+		return crypto.SHA384, nil
+	case oid.Equal(oidSHA512):
+		return crypto.SHA512, nil
+	default:
+		return 0, fmt.Errorf("crypto: unsupported CMS digest algorithm %s", oid)
+	}
+}
+
+// cmsSignatureAlgorithm maps a CMS signature algorithm to its x509
+// equivalent. Signers may name just the key type, rsaEncryption or
+// id-ecPublicKey, in which case the digest algorithm selects the hash.
+func cmsSignatureAlgorithm(oid asn1.ObjectIdentifier, hash crypto.Hash) (x509.SignatureAlgorithm, error) {
+	byHash := func(sha256, sha384, sha512 x509.SignatureAlgorithm) x509.SignatureAlgorithm {
+		switch hash {
+		case crypto.SHA384:
+			return sha384
+		case crypto.SHA512:
+			return sha512
+		default:
+			return sha256
+		}
+	}
+	switch {
+	case oid.Equal(oidECDSAWithSHA256):
+		return x509.ECDSAWithSHA256, nil
+	case oid.Equal(oidECDSAWithSHA384):
+		return x509.ECDSAWithSHA384, nil
+	case oid.Equal(oidECDSAWithSHA512):
+		return x509.ECDSAWithSHA512, nil
+	case oid.Equal(oidSHA256WithRSA):
+		return x509.SHA256WithRSA, nil
+	case oid.Equal(oidSHA384WithRSA):
+		return x509.SHA384WithRSA, nil
+	case oid.Equal(oidSHA512WithRSA):
+		return x509.SHA512WithRSA, nil
+	case oid.Equal(oidRSAEncryption):
+		return byHash(x509.SHA256WithRSA, x509.SHA384WithRSA, x509.SHA512WithRSA), nil
+	case oid.Equal(oidECPublicKey):
+		return byHash(x509.ECDSAWithSHA256, x509.ECDSAWithSHA384, x509.ECDSAWithSHA512), nil
+	default:
+		return 0, fmt.Errorf("crypto: unsupported CMS signature algorithm %s", oid)
+	}
+}
@@ -0,0 +1,159 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/entity"
+)
+
+// newTestCMSDevice returns a device with a self-signed certificate.
+func newTestCMSDevice(t *testing.T, generator KeyPairGenerator, marshaler KeyPairMarshaler, algorithm, scheme string) (*entity.Device, *x509.Certificate) {
+	t.Helper()
+	keyPair, err := generator.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, private, err := marshaler.Marshal(*keyPair)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: "device"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, keyPair.Public, keyPair.Private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	device := &entity.Device{ID: "device", Algorithm: algorithm, PublicKey: public, PrivateKey: private, SignatureScheme: scheme}
+	return device, certificate
+}
+
+func TestSignDetachedCMS(t *testing.T) {
+	content := bytes.Repeat([]byte("document "), 100)
+	signingTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		device    func() (*entity.Device, *x509.Certificate)
+		algorithm x509.SignatureAlgorithm
+	}{
+		{"ECC", func() (*entity.Device, *x509.Certificate) {
+			return newTestCMSDevice(t, &ECCGenerator{}, NewECCMarshaler(), "ECC", ECDSASchemeSHA384)
+		}, x509.ECDSAWithSHA384},
+		{"RSA PSS", func() (*entity.Device, *x509.Certificate) {
+			return newTestCMSDevice(t, &RSAGenerator{}, NewRSAMarshaler(), "RSA", RSASchemePSS)
+		}, x509.SHA256WithRSAPSS},
+		{"RSA PKCS1v15", func() (*entity.Device, *x509.Certificate) {
+			return newTestCMSDevice(t, &RSAGenerator{}, NewRSAMarshaler(), "RSA", RSASchemePKCS1v15)
+		}, x509.SHA256WithRSA},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			device, certificate := tt.device()
+			hash, err := CMSHash(device)
+			if err != nil {
+				t.Fatal(err)
+			}
+			digester := hash.New()
+			digester.Write(content)
+			digest := digester.Sum(nil)
+
+			for _, embed := range []bool{true, false} {
+				var signerCertificate *x509.Certificate
+				if embed {
+					signerCertificate = certificate
+				}
+				raw, err := SignDetachedCMS(device, digest, signingTime, signerCertificate)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var contentInfo cmsContentInfo
+				if rest, err := asn1.Unmarshal(raw, &contentInfo); err != nil || len(rest) > 0 || !contentInfo.ContentType.Equal(oidSignedData) {
+					t.Fatalf("ContentInfo: %v, %d trailing bytes", err, len(rest))
+				}
+				var signedData cmsSignedData
+				if _, err := asn1.Unmarshal(contentInfo.Content.Bytes, &signedData); err != nil {
+					t.Fatal(err)
+				}
+				if !signedData.EncapContentInfo.ContentType.Equal(oidData) || len(signedData.EncapContentInfo.Content.FullBytes) != 0 {
+					t.Error("signature is not detached from id-data content")
+				}
+				if len(signedData.SignerInfos) != 1 {
+					t.Fatalf("SignedData has %d signers", len(signedData.SignerInfos))
+				}
+				signer := signedData.SignerInfos[0]
+
+				// The signer is identified by the certificate or its key.
+				if embed {
+					var sid cmsIssuerAndSerialNumber
+					if _, err := asn1.Unmarshal(signer.SignerIdentifier.FullBytes, &sid); err != nil {
+						t.Fatal(err)
+					}
+					if signedData.Version != 1 || signer.Version != 1 || !bytes.Equal(sid.Issuer.FullBytes, certificate.RawIssuer) || sid.SerialNumber.Cmp(certificate.SerialNumber) != 0 || !bytes.Equal(signedData.Certificates.Bytes, certificate.Raw) {
+						t.Error("signer is not identified by the embedded certificate")
+					}
+				} else {
+					var spki struct {
+						Algorithm        pkix.AlgorithmIdentifier
+						SubjectPublicKey asn1.BitString
+					}
+					if _, err := asn1.Unmarshal(certificate.RawSubjectPublicKeyInfo, &spki); err != nil {
+						t.Fatal(err)
+					}
+					keyID := sha1.Sum(spki.SubjectPublicKey.Bytes)
+					if signedData.Version != 3 || signer.Version != 3 || signer.SignerIdentifier.Tag != 0 || !bytes.Equal(signer.SignerIdentifier.Bytes, keyID[:]) || len(signedData.Certificates.FullBytes) != 0 {
+						t.Error("signer is not identified by the subject key identifier")
+					}
+				}
+
+				signedAttributes := append([]byte{0x31}, signer.SignedAttributes.FullBytes[1:]...)
+				var attributes []cmsAttribute
+				if _, err := asn1.UnmarshalWithParams(signedAttributes, &attributes, "set"); err != nil {
+					t.Fatal(err)
+				}
+				var contentType asn1.ObjectIdentifier
+				if err := cmsAttributeValue(attributes, oidAttributeContentType, &contentType); err != nil || !contentType.Equal(oidData) {
+					t.Errorf("content type attribute %v: %v", contentType, err)
+				}
+				var messageDigest []byte
+				if err := cmsAttributeValue(attributes, oidAttributeMessageDigest, &messageDigest); err != nil || !bytes.Equal(messageDigest, digest) {
+					t.Errorf("message digest attribute does not match the content: %v", err)
+				}
+				var attributeTime time.Time
+				if err := cmsAttributeValue(attributes, oidAttributeSigningTime, &attributeTime); err != nil || !attributeTime.Equal(signingTime) {
+					t.Errorf("signing time attribute %v: %v", attributeTime, err)
+				}
+
+				if err := certificate.CheckSignature(tt.algorithm, signedAttributes, signer.Signature); err != nil {
+					t.Errorf("signature over the signed attributes: %v", err)
+				}
+				changed := append([]byte(nil), signedAttributes...)
+				changed[len(changed)-1] ^= 1
+				if err := certificate.CheckSignature(tt.algorithm, changed, signer.Signature); err == nil {
+					t.Error("signature verified for changed attributes")
+				}
+			}
+		})
+	}
+
+	device, _ := newTestCMSDevice(t, &ECCGenerator{}, NewECCMarshaler(), "ECC", ECDSASchemeSHA384)
+	if _, err := SignDetachedCMS(device, make([]byte, 32), signingTime, nil); err == nil {
+		t.Error("signed a digest of the wrong size")
+	}
+}
@@ -0,0 +1,80 @@
+package crypto
+
+import (
+	"fmt"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/entity"
+	"github.com/fxamacker/cbor/v2"
+)
+
+// COSE header labels (RFC 9052, section 3.1).
+const (
+	coseHeaderAlgorithm = 1
+	coseHeaderKeyID     = 4
+)
+
+// coseSign1Tag is the CBOR tag of a COSE_Sign1 structure.
+const coseSign1Tag = 18
+
+// coseEncoding encodes COSE structures deterministically (RFC 8949, section 4.2.1).
+var coseEncoding, _ = cbor.CoreDetEncOptions().EncMode()
+
+// COSEHeader is the protected header of a signed transaction in COSE_Sign1
+// (RFC 9052). DeviceID and Counter are carried under the text labels
+// "device_id" and "counter".
+type COSEHeader struct {
+	Algorithm int64
+	KeyID     []byte
+	DeviceID  string
+	Counter   int
+}
+
+// COSEAlgorithm returns the COSE algorithm identifier (RFC 9053, RFC 8230)
+// matching the signatures a device produces.
+func COSEAlgorithm(device *entity.Device) (int64, error) {
+	alg, err := JWSAlgorithm(device)
+	if err != nil {
+		return 0, err
+	}
+
+	switch alg {
+	case "ES384":
+		return -35, nil
+	case "PS256":
+		return -37, nil
+	case "RS256":
+		return -257, nil
+	default:
+		return 0, fmt.Errorf("no COSE algorithm for %s", alg)
+	}
+}
+
+// SignCOSE signs the payload with the signer and returns the tagged
+// COSE_Sign1 structure. ECDSA signers must produce ECDSAFormatPlain
+// signatures as required by RFC 9053.
+func SignCOSE(signer Signer, header COSEHeader, payload []byte) ([]byte, error) {
+	protected, err := coseEncoding.Marshal(map[any]any{
+		coseHeaderAlgorithm: header.Algorithm,
+		coseHeaderKeyID:     header.KeyID,
+		"device_id":         header.DeviceID,
+		"counter":           header.Counter,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	toBeSigned, err := coseEncoding.Marshal([]any{"Signature1", protected, []byte{}, payload})
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := signer.Sign(toBeSigned)
+	if err != nil {
+		return nil, err
+	}
+
+	return coseEncoding.Marshal(cbor.Tag{
+		Number:  coseSign1Tag,
+		Content: []any{protected, map[any]any{}, payload, signature},
+	})
+}
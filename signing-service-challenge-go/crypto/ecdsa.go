@@ -4,6 +4,7 @@ import (
 	"crypto/ecdsa"
 	"crypto/x509"
 	"encoding/pem"
+	"fmt"
 )
 
 // ECCKeyPair is a DTO that holds ECC private and public keys.
@@ -13,16 +14,27 @@ type ECCKeyPair struct {
 }
 
 // ECCMarshaler can encode and decode an ECC key pair.
-type ECCMarshaler struct{}
+type ECCMarshaler struct {
+	encoding Encoding
+}
 
-// NewECCMarshaler creates a new ECCMarshaler.
+// NewECCMarshaler creates a new ECCMarshaler that writes the legacy encoding.
 func NewECCMarshaler() ECCMarshaler {
 	return ECCMarshaler{}
 }
 
+// NewECCMarshalerWithEncoding creates a new ECCMarshaler that writes the given encoding.
+func NewECCMarshalerWithEncoding(encoding Encoding) ECCMarshaler {
+	return ECCMarshaler{encoding: encoding}
+}
+
 // Marshal takes an ECCKeyPair and encodes it to be written on disk.
 // It returns the public and the private key as a byte slice.
 func (m ECCMarshaler) Marshal(keyPair KeyPair) ([]byte, []byte, error) {
+	if m.encoding == StandardEncoding {
+		return marshalStandard(keyPair)
+	}
+
 	privateKeyBytes, err := x509.MarshalECPrivateKey(keyPair.Private.(*ecdsa.PrivateKey))
 	if err != nil {
 		return nil, nil, err
@@ -34,12 +46,12 @@ func (m ECCMarshaler) Marshal(keyPair KeyPair) ([]byte, []byte, error) {
 	}
 
 	encodedPrivate := pem.EncodeToMemory(&pem.Block{
-		Type:  "PRIVATE_KEY",
+		Type:  pemTypeLegacyECPrivateKey,
 		Bytes: privateKeyBytes,
 	})
 
 	encodedPublic := pem.EncodeToMemory(&pem.Block{
-		Type:  "PUBLIC_KEY",
+		Type:  pemTypeLegacyECPublicKey,
 		Bytes: publicKeyBytes,
 	})
 
@@ -47,13 +59,33 @@ func (m ECCMarshaler) Marshal(keyPair KeyPair) ([]byte, []byte, error) {
 }
 
 // Unmarshal assembles an ECCKeyPair from an encoded private key.
+// SEC 1 keys labelled "EC PRIVATE KEY" or "PRIVATE_KEY" and PKCS #8 keys
+// labelled "PRIVATE KEY" are accepted.
 func (m ECCMarshaler) Unmarshal(privateKeyBytes []byte) (*KeyPair, error) {
-	block, _ := pem.Decode(privateKeyBytes)
-	privateKey, err := x509.ParseECPrivateKey(block.Bytes)
+	block, err := decodePEMBlock(privateKeyBytes, pemTypeECPrivateKey, pemTypeLegacyECPrivateKey, pemTypePrivateKey)
 	if err != nil {
 		return nil, err
 	}
 
+	var privateKey *ecdsa.PrivateKey
+	switch block.Type {
+	case pemTypePrivateKey:
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, &KeyDecodeError{Type: block.Type, Err: err}
+		}
+		ecKey, ok := key.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, &KeyDecodeError{Type: block.Type, Err: fmt.Errorf("expected ECDSA key, got %T", key)}
+		}
+		privateKey = ecKey
+	default:
+		privateKey, err = x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, &KeyDecodeError{Type: block.Type, Err: err}
+		}
+	}
+
 	return &KeyPair{
 		Private: privateKey,
 		Public:  &privateKey.PublicKey,
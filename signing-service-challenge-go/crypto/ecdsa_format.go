@@ -0,0 +1,65 @@
+package crypto
+
+import (
+	"crypto/elliptic"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+)
+
+// ECDSA signature encodings.
+const (
+	// ECDSAFormatDER is the ASN.1 DER SEQUENCE of r and s (RFC 3279).
+	ECDSAFormatDER = "DER"
+	// ECDSAFormatPlain is the fixed-length concatenation r||s, each value
+	// left-padded to the byte size of the curve order, as used by BSI TR-03111
+	// (KassenSichV) and JWS.
+	ECDSAFormatPlain = "PLAIN"
+)
+
+// ErrMalformedSignature is returned when an ECDSA signature cannot be decoded.
+var ErrMalformedSignature = errors.New("crypto: malformed ECDSA signature")
+
+// ecdsaSignature is the ASN.1 structure of an ECDSA signature.
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// ECDSASignatureToPlain converts an ASN.1 DER encoded ECDSA signature into
+// the r||s encoding for the given curve.
+func ECDSASignatureToPlain(der []byte, curve elliptic.Curve) ([]byte, error) {
+	var sig ecdsaSignature
+	rest, err := asn1.Unmarshal(der, &sig)
+	if err != nil || len(rest) != 0 || sig.R == nil || sig.S == nil {
+		return nil, ErrMalformedSignature
+	}
+
+	size := curveOrderSize(curve)
+	if sig.R.Sign() <= 0 || sig.S.Sign() <= 0 || sig.R.BitLen() > size*8 || sig.S.BitLen() > size*8 {
+		return nil, ErrMalformedSignature
+	}
+
+	plain := make([]byte, 2*size)
+	sig.R.FillBytes(plain[:size])
+	sig.S.FillBytes(plain[size:])
+	return plain, nil
+}
+
+// ECDSASignatureToDER converts an r||s encoded ECDSA signature for the given
+// curve into ASN.1 DER.
+func ECDSASignatureToDER(plain []byte, curve elliptic.Curve) ([]byte, error) {
+	size := curveOrderSize(curve)
+	if len(plain) != 2*size {
+		return nil, ErrMalformedSignature
+	}
+
+	return asn1.Marshal(ecdsaSignature{
+		R: new(big.Int).SetBytes(plain[:size]),
+		S: new(big.Int).SetBytes(plain[size:]),
+	})
+}
+
+// curveOrderSize returns the byte length of each half of an r||s signature.
+func curveOrderSize(curve elliptic.Curve) int {
+	return (curve.Params().N.BitLen() + 7) / 8
+}
@@ -0,0 +1,119 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"errors"
+	"testing"
+)
+
+// derSignature encodes r and s, given as big-endian bytes, as a DER
+// SEQUENCE of two INTEGERs without using encoding/asn1.
+func derSignature(r, s []byte) []byte {
+	integer := func(v []byte) []byte {
+		for len(v) > 1 && v[0] == 0 {
+			v = v[1:]
+		}
+		if v[0]&0x80 != 0 {
+			v = append([]byte{0}, v...)
+		}
+		return append(derHeader(0x02, len(v)), v...)
+	}
+	body := append(integer(r), integer(s)...)
+	return append(derHeader(0x30, len(body)), body...)
+}
+
+func derHeader(tag byte, length int) []byte {
+	if length < 0x80 {
+		return []byte{tag, byte(length)}
+	}
+	return []byte{tag, 0x81, byte(length)}
+}
+
+// fill returns size bytes: zeros leading zero bytes followed by b repeated.
+func fill(size, zeros int, b byte) []byte {
+	v := make([]byte, size)
+	for i := zeros; i < size; i++ {
+		v[i] = b
+	}
+	return v
+}
+
+func TestECDSASignatureFormats(t *testing.T) {
+	curves := []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521()}
+	for _, curve := range curves {
+		size := curveOrderSize(curve)
+		// The top byte of a P-521 value is at most 0x01.
+		top := byte(0xff)
+		if curve == elliptic.P521() {
+			top = 0x01
+		}
+		full := func(b byte) []byte {
+			v := fill(size, 0, b)
+			v[0] = top
+			return v
+		}
+
+		tests := []struct {
+			name string
+			r, s []byte
+		}{
+			{"full", full(0x7f), full(0x55)},
+			{"high bit", full(0xff), full(0x80)},
+			{"leading zero in r", fill(size, 1, 0x9a), full(0x33)},
+			{"leading zero in s", full(0x33), fill(size, 1, 0x9a)},
+			{"leading zeros in both", fill(size, 2, 0x01), fill(size, 3, 0xc4)},
+			{"r is one", fill(size, size-1, 0x01), full(0x42)},
+		}
+		for _, tt := range tests {
+			t.Run(curve.Params().Name+"/"+tt.name, func(t *testing.T) {
+				plain := append(append([]byte{}, tt.r...), tt.s...)
+				der := derSignature(tt.r, tt.s)
+
+				gotPlain, err := ECDSASignatureToPlain(der, curve)
+				if err != nil {
+					t.Fatalf("DER to r||s: %v", err)
+				}
+				if !bytes.Equal(gotPlain, plain) {
+					t.Errorf("DER to r||s:\n got %x\nwant %x", gotPlain, plain)
+				}
+
+				gotDER, err := ECDSASignatureToDER(plain, curve)
+				if err != nil {
+					t.Fatalf("r||s to DER: %v", err)
+				}
+				if !bytes.Equal(gotDER, der) {
+					t.Errorf("r||s to DER:\n got %x\nwant %x", gotDER, der)
+				}
+			})
+		}
+	}
+}
+
+func TestECDSASignatureFormatsMalformed(t *testing.T) {
+	curve := elliptic.P256()
+	size := curveOrderSize(curve)
+	one := fill(size, size-1, 0x01)
+
+	for name, der := range map[string][]byte{
+		"empty":           nil,
+		"trailing data":   append(derSignature(one, one), 0x00),
+		"zero r":          derSignature(make([]byte, size), one),
+		"r too long":      derSignature(fill(size+1, 0, 0x01), one),
+		"not a signature": []byte{0x02, 0x01, 0x01},
+	} {
+		if _, err := ECDSASignatureToPlain(der, curve); !errors.Is(err, ErrMalformedSignature) {
+			t.Errorf("DER %s: got %v, want ErrMalformedSignature", name, err)
+		}
+	}
+
+	for name, plain := range map[string][]byte{
+		"empty":     nil,
+		"too short": make([]byte, 2*size-1),
+		"too long":  make([]byte, 2*size+1),
+	} {
+		if _, err := ECDSASignatureToDER(plain, curve); !errors.Is(err, ErrMalformedSignature) {
+			t.Errorf("r||s %s: got %v, want ErrMalformedSignature", name, err)
+		}
+	}
+}
@@ -7,13 +7,24 @@ import (
 	"crypto/rsa"
 )
 
+// DefaultRSABits is the RSA key size used if RSAGenerator.Bits is zero.
+// 2048 bits is the smallest size that is still widely accepted by
+// verifiers, and large enough for PSS with a salt as long as the hash.
+const DefaultRSABits = 2048
+
 // RSAGenerator generates a RSA key pair.
-type RSAGenerator struct{}
+type RSAGenerator struct {
+	// Bits is the key size, DefaultRSABits if zero.
+	Bits int
+}
 
 // Generate generates a new KeyPair.
 func (g *RSAGenerator) Generate() (*KeyPair, error) {
-	// Security has been ignored for the sake of simplicity.
-	key, err := rsa.GenerateKey(rand.Reader, 512)
+	bits := g.Bits
+	if bits == 0 {
+		bits = DefaultRSABits
+	}
+	key, err := rsa.GenerateKey(rand.Reader, bits)
 	if err != nil {
 		return nil, err
 	}
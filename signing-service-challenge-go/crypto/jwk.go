@@ -0,0 +1,80 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/entity"
+)
+
+// JWK is the JSON Web Key (RFC 7517) representation of a device public key.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid,omitempty"`
+	Use       string `json:"use,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+// JWSAlgorithm returns the JWS algorithm name (RFC 7518) matching the
+// signatures a device produces.
+func JWSAlgorithm(device *entity.Device) (string, error) {
+	switch device.Algorithm {
+	case "ECC":
+		return "ES384", nil
+	case "RSA":
+		switch RSAScheme(device) {
+		case RSASchemePSS:
+			return "PS256", nil
+		case RSASchemePKCS1v15:
+			return "RS256", nil
+		}
+		return "", fmt.Errorf("unsupported RSA signature scheme: %s", device.SignatureScheme)
+	default:
+		return "", fmt.Errorf("unsupported algorithm: %s", device.Algorithm)
+	}
+}
+
+// NewDeviceJWK returns the public key of a device as a JWK, using the device
+// ID as key ID.
+func NewDeviceJWK(device *entity.Device) (*JWK, error) {
+	publicKey, err := UnmarshalPublicKey(device.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	alg, err := JWSAlgorithm(device)
+	if err != nil {
+		return nil, err
+	}
+
+	jwk := &JWK{
+		KeyID:     device.ID,
+		Use:       "sig",
+		Algorithm: alg,
+	}
+
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		jwk.KeyType = "EC"
+		jwk.Curve = key.Curve.Params().Name
+		jwk.X = base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, size)))
+		jwk.Y = base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, size)))
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(key.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+	default:
+		return nil, fmt.Errorf("crypto: unsupported key type %T", publicKey)
+	}
+
+	return jwk, nil
+}
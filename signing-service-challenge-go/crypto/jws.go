@@ -0,0 +1,35 @@
+package crypto
+
+import (
+	"encoding/base64"
+	"encoding/json"
+)
+
+// JWSHeader is the protected header of a signed transaction in JWS compact
+// serialization (RFC 7515). DeviceID and Counter are private header
+// parameters that bind the JWS to a position in the device's signature chain.
+type JWSHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	DeviceID  string `json:"device_id"`
+	Counter   int    `json:"counter"`
+}
+
+// SignJWS signs the payload with the signer and returns the JWS compact
+// serialization. ECDSA signers must produce ECDSAFormatPlain signatures as
+// required by RFC 7518.
+func SignJWS(signer Signer, header JWSHeader, payload []byte) (string, error) {
+	encodedHeader, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(encodedHeader) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	signature, err := signer.Sign([]byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
@@ -0,0 +1,131 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+)
+
+// Domain separation prefixes of leaf and interior node hashes (RFC 9162,
+// section 2.1.1).
+const (
+	merkleLeafPrefix = 0x00
+	merkleNodePrefix = 0x01
+)
+
+// ErrInvalidInclusionProof is returned when an inclusion proof does not
+// lead from a leaf to the expected root.
+var ErrInvalidInclusionProof = errors.New("invalid inclusion proof")
+
+// MerkleTree is a SHA-256 Merkle tree as described in RFC 9162, section 2.1.
+type MerkleTree struct {
+	// levels[0] holds the leaf hashes, the last level holds the root.
+	levels [][][]byte
+}
+
+// NewMerkleTree builds the tree over the given leaves. At least one leaf is
+// required.
+func NewMerkleTree(leaves [][]byte) (*MerkleTree, error) {
+	if len(leaves) == 0 {
+		return nil, errors.New("merkle tree requires at least one leaf")
+	}
+
+	level := make([][]byte, len(leaves))
+	for i, leaf := range leaves {
+		level[i] = MerkleLeafHash(leaf)
+	}
+
+	levels := [][][]byte{level}
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				// An unpaired last node is promoted unchanged, which yields
+				// the left-balanced tree of RFC 9162.
+				next = append(next, level[i])
+				continue
+			}
+			next = append(next, merkleNodeHash(level[i], level[i+1]))
+		}
+		levels = append(levels, next)
+		level = next
+	}
+
+	return &MerkleTree{levels: levels}, nil
+}
+
+// Size returns the number of leaves.
+func (t *MerkleTree) Size() int {
+	return len(t.levels[0])
+}
+
+// Root returns the root hash of the tree.
+func (t *MerkleTree) Root() []byte {
+	return t.levels[len(t.levels)-1][0]
+}
+
+// InclusionProof returns the audit path of the leaf at index, ordered from
+// the leaf to the root.
+func (t *MerkleTree) InclusionProof(index int) ([][]byte, error) {
+	if index < 0 || index >= t.Size() {
+		return nil, errors.New("leaf index out of range")
+	}
+
+	var proof [][]byte
+	for _, level := range t.levels[:len(t.levels)-1] {
+		sibling := index ^ 1
+		if sibling < len(level) {
+			proof = append(proof, level[sibling])
+		}
+		index /= 2
+	}
+	return proof, nil
+}
+
+// VerifyMerkleInclusion checks that leaf is the leaf at index of a tree with
+// the given size and root, following RFC 9162, section 2.1.3.2.
+func VerifyMerkleInclusion(leaf []byte, index, size uint64, proof [][]byte, root []byte) error {
+	if index >= size {
+		return ErrInvalidInclusionProof
+	}
+
+	fn, sn := index, size-1
+	r := MerkleLeafHash(leaf)
+	for _, p := range proof {
+		if sn == 0 {
+			return ErrInvalidInclusionProof
+		}
+		if fn&1 == 1 || fn == sn {
+			r = merkleNodeHash(p, r)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = merkleNodeHash(r, p)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 || !bytes.Equal(r, root) {
+		return ErrInvalidInclusionProof
+	}
+	return nil
+}
+
+// MerkleLeafHash returns the hash of a leaf.
+func MerkleLeafHash(leaf []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{merkleLeafPrefix})
+	h.Write(leaf)
+	return h.Sum(nil)
+}
+
+func merkleNodeHash(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{merkleNodePrefix})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
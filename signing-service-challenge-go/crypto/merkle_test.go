@@ -0,0 +1,139 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// largestPowerOfTwoBelow returns the largest power of two smaller than n,
+// which must be greater than one.
+func largestPowerOfTwoBelow(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
+
+// referenceRoot is MTH of RFC 9162, section 2.1.1.
+func referenceRoot(leaves [][]byte) []byte {
+	if len(leaves) == 1 {
+		return MerkleLeafHash(leaves[0])
+	}
+	k := largestPowerOfTwoBelow(len(leaves))
+	return merkleNodeHash(referenceRoot(leaves[:k]), referenceRoot(leaves[k:]))
+}
+
+// referencePath is PATH of RFC 9162, section 2.1.3.1.
+func referencePath(m int, leaves [][]byte) [][]byte {
+	if len(leaves) == 1 {
+		return nil
+	}
+	k := largestPowerOfTwoBelow(len(leaves))
+	if m < k {
+		return append(referencePath(m, leaves[:k]), referenceRoot(leaves[k:]))
+	}
+	return append(referencePath(m-k, leaves[k:]), referenceRoot(leaves[:k]))
+}
+
+// pathLength is the length of PATH(m, D[n]).
+func pathLength(m, n int) int {
+	if n == 1 {
+		return 0
+	}
+	k := largestPowerOfTwoBelow(n)
+	if m < k {
+		return 1 + pathLength(m, k)
+	}
+	return 1 + pathLength(m-k, n-k)
+}
+
+// inclusionCase is an input of VerifyMerkleInclusion.
+type inclusionCase struct {
+	name  string
+	leaf  []byte
+	index uint64
+	size  uint64
+	proof [][]byte
+	root  []byte
+	valid bool
+}
+
+func TestMerkleTree(t *testing.T) {
+	for size := 1; size <= 9; size++ {
+		leaves := make([][]byte, size)
+		for i := range leaves {
+			leaves[i] = []byte(fmt.Sprintf("leaf %d", i))
+		}
+		tree, err := NewMerkleTree(leaves)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tree.Size() != size {
+			t.Errorf("size %d: tree has %d leaves", size, tree.Size())
+		}
+		root := referenceRoot(leaves)
+		if !bytes.Equal(tree.Root(), root) {
+			t.Errorf("size %d: root differs from RFC 9162", size)
+		}
+
+		for index := 0; index < size; index++ {
+			name := fmt.Sprintf("size %d index %d", size, index)
+			proof, err := tree.InclusionProof(index)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := referencePath(index, leaves); len(proof) != len(want) || (len(proof) > 0 && !bytes.Equal(bytes.Join(proof, nil), bytes.Join(want, nil))) {
+				t.Errorf("%s: proof differs from RFC 9162", name)
+			}
+
+			tests := []inclusionCase{
+				{"valid", leaves[index], uint64(index), uint64(size), proof, root, true},
+				{"other leaf", []byte("other"), uint64(index), uint64(size), proof, root, false},
+				{"other root", leaves[index], uint64(index), uint64(size), proof, MerkleLeafHash([]byte("other")), false},
+				{"index out of range", leaves[index], uint64(size), uint64(size), proof, root, false},
+				{"extra proof node", leaves[index], uint64(index), uint64(size), append(append([][]byte{}, proof...), root), root, false},
+			}
+			if size > 1 {
+				tests = append(tests,
+					inclusionCase{"wrong index", leaves[index], uint64((index + 1) % size), uint64(size), proof, root, false},
+					inclusionCase{"missing proof node", leaves[index], uint64(index), uint64(size), proof[:len(proof)-1], root, false},
+				)
+			}
+			// A proof also holds for sizes with the same path shape, as the
+			// root commits to the tree; sizes with another path length fail.
+			for _, other := range []int{size - 1, size + 1, 2 * size} {
+				if other > index && pathLength(index, other) != len(proof) {
+					tests = append(tests, inclusionCase{fmt.Sprintf("wrong size %d", other), leaves[index], uint64(index), uint64(other), proof, root, false})
+				}
+			}
+			for i := range proof {
+				tampered := make([][]byte, len(proof))
+				copy(tampered, proof)
+				tampered[i] = append([]byte(nil), proof[i]...)
+				tampered[i][0] ^= 1
+				tests = append(tests, inclusionCase{fmt.Sprintf("tampered proof node %d", i), leaves[index], uint64(index), uint64(size), tampered, root, false})
+			}
+
+			for _, tt := range tests {
+				err := VerifyMerkleInclusion(tt.leaf, tt.index, tt.size, tt.proof, tt.root)
+				if tt.valid && err != nil {
+					t.Errorf("%s, %s: %v", name, tt.name, err)
+				}
+				if !tt.valid && !errors.Is(err, ErrInvalidInclusionProof) {
+					t.Errorf("%s, %s: got %v, want ErrInvalidInclusionProof", name, tt.name, err)
+				}
+			}
+		}
+
+		if _, err := tree.InclusionProof(size); err == nil {
+			t.Errorf("size %d: proof for an index out of range", size)
+		}
+	}
+
+	if _, err := NewMerkleTree(nil); err == nil {
+		t.Error("tree without leaves")
+	}
+}
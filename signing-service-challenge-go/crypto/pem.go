@@ -0,0 +1,185 @@
+package crypto
+
+import (
+	gocrypto "crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// PEM block labels accepted by the marshalers. The underscore variants were
+// written by earlier versions of this package and are kept so that keys which
+// are already stored can still be read.
+const (
+	pemTypeECPrivateKey        = "EC PRIVATE KEY"
+	pemTypeRSAPrivateKey       = "RSA PRIVATE KEY"
+	pemTypePrivateKey          = "PRIVATE KEY"
+	pemTypePublicKey           = "PUBLIC KEY"
+	pemTypeLegacyECPrivateKey  = "PRIVATE_KEY"
+	pemTypeLegacyECPublicKey   = "PUBLIC_KEY"
+	pemTypeLegacyRSAPrivateKey = "RSA_PRIVATE_KEY"
+	pemTypeLegacyRSAPublicKey  = "RSA_PUBLIC_KEY"
+)
+
+// Encoding selects the format a marshaler writes. Every marshaler reads
+// both encodings regardless of the one it writes.
+type Encoding int
+
+const (
+	// LegacyEncoding writes SEC 1 (ECC) or PKCS #1 (RSA) keys under the
+	// non-standard labels used by earlier versions of this service.
+	LegacyEncoding Encoding = iota
+	// StandardEncoding writes PKCS #8 private keys and SubjectPublicKeyInfo
+	// public keys under the RFC 7468 labels "PRIVATE KEY" and "PUBLIC KEY".
+	StandardEncoding
+)
+
+// ErrNoPEMBlock is returned when the input does not contain a PEM block.
+var ErrNoPEMBlock = errors.New("crypto: no PEM block found")
+
+// UnexpectedPEMTypeError is returned when a PEM block carries a label
+// the marshaler does not know how to decode.
+type UnexpectedPEMTypeError struct {
+	Type     string
+	Expected []string
+}
+
+func (e *UnexpectedPEMTypeError) Error() string {
+	return fmt.Sprintf("crypto: unexpected PEM block type %q, expected one of %q", e.Type, e.Expected)
+}
+
+// KeyDecodeError is returned when the contents of a PEM block cannot be
+// parsed into a key of the expected kind.
+type KeyDecodeError struct {
+	Type string
+	Err  error
+}
+
+func (e *KeyDecodeError) Error() string {
+	return fmt.Sprintf("crypto: could not decode %q block: %v", e.Type, e.Err)
+}
+
+func (e *KeyDecodeError) Unwrap() error {
+	return e.Err
+}
+
+// decodePEMBlock decodes the first PEM block in data and checks that its
+// label is one of the accepted types.
+func decodePEMBlock(data []byte, accepted ...string) (*pem.Block, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrNoPEMBlock
+	}
+
+	for _, t := range accepted {
+		if block.Type == t {
+			return block, nil
+		}
+	}
+
+	return nil, &UnexpectedPEMTypeError{Type: block.Type, Expected: accepted}
+}
+
+// marshalStandard encodes a key pair as PKCS #8 and SubjectPublicKeyInfo.
+// It returns the public and the private key as a byte slice.
+func marshalStandard(keyPair KeyPair) ([]byte, []byte, error) {
+	privateKeyBytes, err := x509.MarshalPKCS8PrivateKey(keyPair.Private)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	publicKeyBytes, err := x509.MarshalPKIXPublicKey(keyPair.Public)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	encodedPrivate := pem.EncodeToMemory(&pem.Block{
+		Type:  pemTypePrivateKey,
+		Bytes: privateKeyBytes,
+	})
+
+	encodedPublic := pem.EncodeToMemory(&pem.Block{
+		Type:  pemTypePublicKey,
+		Bytes: publicKeyBytes,
+	})
+
+	return encodedPublic, encodedPrivate, nil
+}
+
+// IsLegacyEncoded reports whether an encoded private key uses one of the
+// non-standard labels written by LegacyEncoding.
+func IsLegacyEncoded(privateKeyBytes []byte) bool {
+	block, _ := pem.Decode(privateKeyBytes)
+	if block == nil {
+		return false
+	}
+	return block.Type == pemTypeLegacyECPrivateKey || block.Type == pemTypeLegacyRSAPrivateKey
+}
+
+// Reencode decodes a stored private key with the given marshaler and encodes
+// it again in the marshaler's output encoding. It is used to migrate keys
+// written with LegacyEncoding. It returns the public and the private key as
+// a byte slice.
+func Reencode(marshaler KeyPairMarshaler, privateKeyBytes []byte) ([]byte, []byte, error) {
+	keyPair, err := marshaler.Unmarshal(privateKeyBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return marshaler.Marshal(*keyPair)
+}
+
+// UnmarshalPrivateKey decodes a PEM encoded private key of any supported
+// algorithm, choosing the parser from the block label.
+func UnmarshalPrivateKey(privateKeyBytes []byte) (*KeyPair, error) {
+	block, err := decodePEMBlock(privateKeyBytes,
+		pemTypePrivateKey,
+		pemTypeECPrivateKey, pemTypeLegacyECPrivateKey,
+		pemTypeRSAPrivateKey, pemTypeLegacyRSAPrivateKey,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	switch block.Type {
+	case pemTypeECPrivateKey, pemTypeLegacyECPrivateKey:
+		return NewECCMarshaler().Unmarshal(privateKeyBytes)
+	case pemTypeRSAPrivateKey, pemTypeLegacyRSAPrivateKey:
+		return NewRSAMarshaler().Unmarshal(privateKeyBytes)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, &KeyDecodeError{Type: block.Type, Err: err}
+	}
+	signer, ok := key.(interface{ Public() gocrypto.PublicKey })
+	if !ok {
+		return nil, &KeyDecodeError{Type: block.Type, Err: fmt.Errorf("unsupported key type %T", key)}
+	}
+
+	return &KeyPair{
+		Private: key,
+		Public:  signer.Public(),
+	}, nil
+}
+
+// UnmarshalPublicKey decodes a PEM encoded public key written by any of the
+// marshalers.
+func UnmarshalPublicKey(publicKeyBytes []byte) (any, error) {
+	block, err := decodePEMBlock(publicKeyBytes, pemTypePublicKey, pemTypeLegacyECPublicKey, pemTypeLegacyRSAPublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var key any
+	if block.Type == pemTypeLegacyRSAPublicKey {
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	} else {
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, &KeyDecodeError{Type: block.Type, Err: err}
+	}
+
+	return key, nil
+}
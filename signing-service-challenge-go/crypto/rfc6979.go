@@ -0,0 +1,146 @@
+package crypto
+
+import (
+	gocrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"filippo.io/bigmod"
+	"filippo.io/nistec"
+)
+
+// signECDSADeterministic signs a digest with ECDSA, deriving the nonce k from
+// the private key and the digest as described in RFC 6979, section 3.2.
+// Signing the same digest with the same key always yields the same signature.
+//
+// The private key and k are secret, so they are only handled by constant-time
+// code: scalars modulo the group order with filippo.io/bigmod and k·G with
+// filippo.io/nistec. k⁻¹ is computed as k^(n-2) mod n rather than with the
+// variable-time big.Int.ModInverse. The only branches are the rejections of
+// candidate nonces that RFC 6979 requires, which happen with negligible
+// probability.
+func signECDSADeterministic(key *ecdsa.PrivateKey, hash gocrypto.Hash, digest []byte) (*big.Int, *big.Int, error) {
+	params := key.Curve.Params()
+	n := bigmod.NewModulusFromBig(params.N)
+	size := n.Size()
+
+	d, err := bigmod.NewNat().SetBytes(key.D.FillBytes(make([]byte, size)), n)
+	if err != nil {
+		return nil, nil, errors.New("crypto: invalid ECDSA private key")
+	}
+	e, err := bigmod.NewNat().SetOverflowingBytes(bits2int(digest, n), n)
+	if err != nil {
+		return nil, nil, err
+	}
+	nMinus2 := new(big.Int).Sub(params.N, big.NewInt(2)).Bytes()
+
+	mac := func(k []byte, parts ...[]byte) []byte {
+		h := hmac.New(hash.New, k)
+		for _, p := range parts {
+			h.Write(p)
+		}
+		return h.Sum(nil)
+	}
+
+	// int2octets(x) and bits2octets(h1), RFC 6979, section 2.3.
+	x := d.Bytes(n)
+	h1 := e.Bytes(n)
+
+	v := make([]byte, hash.Size())
+	for i := range v {
+		v[i] = 0x01
+	}
+	k := make([]byte, hash.Size())
+
+	k = mac(k, v, []byte{0x00}, x, h1)
+	v = mac(k, v)
+	k = mac(k, v, []byte{0x01}, x, h1)
+	v = mac(k, v)
+
+	for {
+		var t []byte
+		for len(t) < size {
+			v = mac(k, v)
+			t = append(t, v...)
+		}
+
+		// SetBytes rejects candidates that are not below n.
+		nonce, err := bigmod.NewNat().SetBytes(bits2int(t, n), n)
+		if err == nil && nonce.IsZero() == 0 {
+			rx, err := scalarBaseMultX(key.Curve, nonce.Bytes(n))
+			if err != nil {
+				return nil, nil, err
+			}
+			r, err := bigmod.NewNat().SetOverflowingBytes(rx, n)
+			if err != nil {
+				return nil, nil, err
+			}
+			if r.IsZero() == 0 {
+				// s = k⁻¹(e + r·d) mod n
+				kInv := bigmod.NewNat().Exp(nonce, nMinus2, n)
+				s, _ := bigmod.NewNat().SetOverflowingBytes(rx, n)
+				s.Mul(d, n)
+				s.Add(e, n)
+				s.Mul(kInv, n)
+				if s.IsZero() == 0 {
+					return new(big.Int).SetBytes(r.Bytes(n)), new(big.Int).SetBytes(s.Bytes(n)), nil
+				}
+			}
+		}
+
+		k = mac(k, v, []byte{0x00})
+		v = mac(k, v)
+	}
+}
+
+// bits2int returns the leftmost bits of b, as many as n has, as big-endian
+// bytes (RFC 6979, section 2.3.2). It shifts whole slices, so its timing
+// does not depend on the value of b.
+func bits2int(b []byte, n *bigmod.Modulus) []byte {
+	size := n.Size()
+	if len(b) < size {
+		return b
+	}
+	out := make([]byte, size)
+	copy(out, b)
+	if excess := size*8 - n.BitLen(); excess > 0 {
+		for i := size - 1; i >= 0; i-- {
+			out[i] >>= excess
+			if i > 0 {
+				out[i] |= out[i-1] << (8 - excess)
+			}
+		}
+	}
+	return out
+}
+
+// scalarBaseMultX returns the x-coordinate of k·G in constant time. k must
+// be as long as the order of the curve.
+func scalarBaseMultX(curve elliptic.Curve, k []byte) ([]byte, error) {
+	switch curve {
+	case elliptic.P256():
+		p, err := nistec.NewP256Point().ScalarBaseMult(k)
+		if err != nil {
+			return nil, err
+		}
+		return p.BytesX()
+	case elliptic.P384():
+		p, err := nistec.NewP384Point().ScalarBaseMult(k)
+		if err != nil {
+			return nil, err
+		}
+		return p.BytesX()
+	case elliptic.P521():
+		p, err := nistec.NewP521Point().ScalarBaseMult(k)
+		if err != nil {
+			return nil, err
+		}
+		return p.BytesX()
+	default:
+		return nil, fmt.Errorf("crypto: deterministic ECDSA is not supported for curve %s", curve.Params().Name)
+	}
+}
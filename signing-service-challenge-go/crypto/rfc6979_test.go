@@ -0,0 +1,139 @@
+package crypto
+
+import (
+	gocrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"math/big"
+	"testing"
+)
+
+func hexInt(t *testing.T, s string) *big.Int {
+	t.Helper()
+	v, ok := new(big.Int).SetString(s, 16)
+	if !ok {
+		t.Fatalf("invalid hex %q", s)
+	}
+	return v
+}
+
+func testKey(t *testing.T, curve elliptic.Curve, d string) *ecdsa.PrivateKey {
+	t.Helper()
+	key := &ecdsa.PrivateKey{D: hexInt(t, d)}
+	key.Curve = curve
+	key.X, key.Y = curve.ScalarBaseMult(key.D.Bytes())
+	return key
+}
+
+func digest(hash gocrypto.Hash, message string) []byte {
+	switch hash {
+	case gocrypto.SHA256:
+		sum := sha256.Sum256([]byte(message))
+		return sum[:]
+	default:
+		sum := sha512.Sum384([]byte(message))
+		return sum[:]
+	}
+}
+
+// TestSignECDSADeterministic checks the known answers of RFC 6979, sections
+// A.2.5 (P-256) and A.2.6 (P-384).
+func TestSignECDSADeterministic(t *testing.T) {
+	p256 := "C9AFA9D845BA75166B5C215767B1D6934E50C3DB36E89B127B8A622B120F6721"
+	p384 := "6B9D3DAD2E1B8C1C05B19875B6659F4DE23C3B667BF297BA9AA47740787137D896D5724E4C70A825F872C9EA60D2EDF5"
+
+	tests := []struct {
+		name    string
+		curve   elliptic.Curve
+		key     string
+		hash    gocrypto.Hash
+		message string
+		r, s    string
+	}{
+		{
+			name: "P-256/SHA-256/sample", curve: elliptic.P256(), key: p256, hash: gocrypto.SHA256, message: "sample",
+			r: "EFD48B2AACB6A8FD1140DD9CD45E81D69D2C877B56AAF991C34D0EA84EAF3716",
+			s: "F7CB1C942D657C41D436C7A1B6E29F65F3E900DBB9AFF4064DC4AB2F843ACDA8",
+		},
+		{
+			name: "P-256/SHA-384/sample", curve: elliptic.P256(), key: p256, hash: gocrypto.SHA384, message: "sample",
+			r: "0EAFEA039B20E9B42309FB1D89E213057CBF973DC0CFC8F129EDDDC800EF7719",
+			s: "4861F0491E6998B9455193E34E7B0D284DDD7149A74B95B9261F13ABDE940954",
+		},
+		{
+			name: "P-256/SHA-256/test", curve: elliptic.P256(), key: p256, hash: gocrypto.SHA256, message: "test",
+			r: "F1ABB023518351CD71D881567B1EA663ED3EFCF6C5132B354F28D3B0B7D38367",
+			s: "019F4113742A2B14BD25926B49C649155F267E60D3814B4C0CC84250E46F0083",
+		},
+		{
+			name: "P-256/SHA-384/test", curve: elliptic.P256(), key: p256, hash: gocrypto.SHA384, message: "test",
+			r: "83910E8B48BB0C74244EBDF7F07A1C5413D61472BD941EF3920E623FBCCEBEB6",
+			s: "8DDBEC54CF8CD5874883841D712142A56A8D0F218F5003CB0296B6B509619F2C",
+		},
+		{
+			name: "P-384/SHA-256/sample", curve: elliptic.P384(), key: p384, hash: gocrypto.SHA256, message: "sample",
+			r: "21B13D1E013C7FA1392D03C5F99AF8B30C570C6F98D4EA8E354B63A21D3DAA33BDE1E888E63355D92FA2B3C36D8FB2CD",
+			s: "F3AA443FB107745BF4BD77CB3891674632068A10CA67E3D45DB2266FA7D1FEEBEFDC63ECCD1AC42EC0CB8668A4FA0AB0",
+		},
+		{
+			name: "P-384/SHA-384/sample", curve: elliptic.P384(), key: p384, hash: gocrypto.SHA384, message: "sample",
+			r: "94EDBB92A5ECB8AAD4736E56C691916B3F88140666CE9FA73D64C4EA95AD133C81A648152E44ACF96E36DD1E80FABE46",
+			s: "99EF4AEB15F178CEA1FE40DB2603138F130E740A19624526203B6351D0A3A94FA329C145786E679E7B82C71A38628AC8",
+		},
+		{
+			name: "P-384/SHA-256/test", curve: elliptic.P384(), key: p384, hash: gocrypto.SHA256, message: "test",
+			r: "6D6DEFAC9AB64DABAFE36C6BF510352A4CC27001263638E5B16D9BB51D451559F918EEDAF2293BE5B475CC8F0188636B",
+			s: "2D46F3BECBCC523D5F1A1256BF0C9B024D879BA9E838144C8BA6BAEB4B53B47D51AB373F9845C0514EEFB14024787265",
+		},
+		{
+			name: "P-384/SHA-384/test", curve: elliptic.P384(), key: p384, hash: gocrypto.SHA384, message: "test",
+			r: "8203B63D3C853E8D77227FB377BCF7B7B772E97892A80F36AB775D509D7A5FEB0542A7F0812998DA8F1DD3CA3CF023DB",
+			s: "DDD0760448D42D8A43AF45AF836FCE4DE8BE06B485E9B61B827C2F13173923E06A739F040649A667BF3B828246BAA5A5",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := testKey(t, tt.curve, tt.key)
+			r, s, err := signECDSADeterministic(key, tt.hash, digest(tt.hash, tt.message))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := hexInt(t, tt.r); r.Cmp(want) != 0 {
+				t.Errorf("r = %X, want %X", r, want)
+			}
+			if want := hexInt(t, tt.s); s.Cmp(want) != 0 {
+				t.Errorf("s = %X, want %X", s, want)
+			}
+		})
+	}
+}
+
+// TestSignECDSADeterministicP521 checks signatures on P-521, whose order
+// is not a whole number of bytes, against the standard verifier.
+func TestSignECDSADeterministicP521(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, hash := range []gocrypto.Hash{gocrypto.SHA256, gocrypto.SHA384} {
+		sum := digest(hash, "sample")
+		r, s, err := signECDSADeterministic(key, hash, sum)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ecdsa.Verify(&key.PublicKey, sum, r, s) {
+			t.Errorf("%v: signature does not verify", hash)
+		}
+		r2, s2, err := signECDSADeterministic(key, hash, sum)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Cmp(r2) != 0 || s.Cmp(s2) != 0 {
+			t.Errorf("%v: signatures differ", hash)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
+	"fmt"
 )
 
 // RSAKeyPair is a DTO that holds RSA private and public keys.
@@ -13,26 +14,37 @@ type RSAKeyPair struct {
 }
 
 // RSAMarshaler can encode and decode an RSA key pair.
-type RSAMarshaler struct{}
+type RSAMarshaler struct {
+	encoding Encoding
+}
 
-// NewRSAMarshaler creates a new RSAMarshaler.
+// NewRSAMarshaler creates a new RSAMarshaler that writes the legacy encoding.
 func NewRSAMarshaler() RSAMarshaler {
 	return RSAMarshaler{}
 }
 
+// NewRSAMarshalerWithEncoding creates a new RSAMarshaler that writes the given encoding.
+func NewRSAMarshalerWithEncoding(encoding Encoding) RSAMarshaler {
+	return RSAMarshaler{encoding: encoding}
+}
+
 // Marshal takes an RSAKeyPair and encodes it to be written on disk.
 // It returns the public and the private key as a byte slice.
 func (m RSAMarshaler) Marshal(keyPair KeyPair) ([]byte, []byte, error) {
+	if m.encoding == StandardEncoding {
+		return marshalStandard(keyPair)
+	}
+
 	privateKeyBytes := x509.MarshalPKCS1PrivateKey(keyPair.Private.(*rsa.PrivateKey))
 	publicKeyBytes := x509.MarshalPKCS1PublicKey(keyPair.Public.(*rsa.PublicKey))
 
 	encodedPrivate := pem.EncodeToMemory(&pem.Block{
-		Type:  "RSA_PRIVATE_KEY",
+		Type:  pemTypeLegacyRSAPrivateKey,
 		Bytes: privateKeyBytes,
 	})
 
 	encodePublic := pem.EncodeToMemory(&pem.Block{
-		Type:  "RSA_PUBLIC_KEY",
+		Type:  pemTypeLegacyRSAPublicKey,
 		Bytes: publicKeyBytes,
 	})
 
@@ -40,13 +52,33 @@ func (m RSAMarshaler) Marshal(keyPair KeyPair) ([]byte, []byte, error) {
 }
 
 // Unmarshal takes an encoded RSA private key and transforms it into a rsa.PrivateKey.
+// PKCS #1 keys labelled "RSA PRIVATE KEY" or "RSA_PRIVATE_KEY" and PKCS #8
+// keys labelled "PRIVATE KEY" are accepted.
 func (m RSAMarshaler) Unmarshal(privateKeyBytes []byte) (*KeyPair, error) {
-	block, _ := pem.Decode(privateKeyBytes)
-	privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	block, err := decodePEMBlock(privateKeyBytes, pemTypeRSAPrivateKey, pemTypeLegacyRSAPrivateKey, pemTypePrivateKey)
 	if err != nil {
 		return nil, err
 	}
 
+	var privateKey *rsa.PrivateKey
+	switch block.Type {
+	case pemTypePrivateKey:
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, &KeyDecodeError{Type: block.Type, Err: err}
+		}
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, &KeyDecodeError{Type: block.Type, Err: fmt.Errorf("expected RSA key, got %T", key)}
+		}
+		privateKey = rsaKey
+	default:
+		privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, &KeyDecodeError{Type: block.Type, Err: err}
+		}
+	}
+
 	return &KeyPair{
 		Private: privateKey,
 		Public:  &privateKey.PublicKey,
@@ -5,6 +5,10 @@ import (
 	"crypto/ecdsa"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/asn1"
+	"fmt"
 
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/entity"
 )
@@ -14,6 +18,23 @@ type Signer interface {
 	Sign(dataToBeSigned []byte) ([]byte, error)
 }
 
+// RSA signature schemes a device can be created with.
+const (
+	// RSASchemePSS is RSASSA-PSS with SHA-256 and a salt as long as the hash.
+	RSASchemePSS = "PSS"
+	// RSASchemePKCS1v15 is RSASSA-PKCS1-v1_5 with SHA-256.
+	RSASchemePKCS1v15 = "PKCS1v15"
+)
+
+// pssOptions fixes the salt length to the hash length, as required by
+// most PSS verifiers and by JWS PS256.
+var pssOptions = &rsa.PSSOptions{
+	SaltLength: rsa.PSSSaltLengthEqualsHash,
+	Hash:       crypto.SHA256,
+}
+
+// RSASigner signs the SHA-256 digest of the data using the device's
+// signature scheme, PSS if none is set.
 type RSASigner struct {
 	Device *entity.Device
 }
@@ -24,21 +45,55 @@ func (r *RSASigner) Sign(dataToBeSigned []byte) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	signature, err := rsa.SignPSS(
-		rand.Reader,
-		keyPair.Private.(*rsa.PrivateKey),
-		crypto.SHA256,
-		dataToBeSigned,
-		nil,
-	)
+	privateKey := keyPair.Private.(*rsa.PrivateKey)
+	digest := sha256.Sum256(dataToBeSigned)
+
+	var signature []byte
+	switch RSAScheme(r.Device) {
+	case RSASchemePKCS1v15:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, digest[:])
+	case RSASchemePSS:
+		signature, err = rsa.SignPSS(rand.Reader, privateKey, crypto.SHA256, digest[:], pssOptions)
+	default:
+		return nil, fmt.Errorf("unsupported RSA signature scheme: %s", r.Device.SignatureScheme)
+	}
 	if err != nil {
 		return nil, err
 	}
 	return signature, nil
 }
 
+// RSAScheme returns the signature scheme of an RSA device. Devices created
+// before schemes were selectable use PSS.
+func RSAScheme(device *entity.Device) string {
+	if device.SignatureScheme == "" {
+		return RSASchemePSS
+	}
+	return device.SignatureScheme
+}
+
+// ECDSASchemeSHA384 is ECDSA over the SHA-384 digest of the data, the only
+// signature scheme of ECC devices.
+const ECDSASchemeSHA384 = "SHA384"
+
+// ECDSAScheme returns the signature scheme of an ECC device. Devices created
+// before schemes were recorded for ECC use ECDSASchemeSHA384 as well.
+func ECDSAScheme(device *entity.Device) string {
+	if device.SignatureScheme == "" {
+		return ECDSASchemeSHA384
+	}
+	return device.SignatureScheme
+}
+
+// ECCSigner signs the SHA-384 digest of data with ECDSA. If the device is
+// deterministic, the nonce is derived as described in RFC 6979 so that
+// signing the same data twice yields the same signature.
+//
+// Signatures are encoded as Format, falling back to the device's signature
+// format and then to ECDSAFormatDER.
 type ECCSigner struct {
 	Device *entity.Device
+	Format string
 }
 
 func (e *ECCSigner) Sign(dataToBeSigned []byte) ([]byte, error) {
@@ -47,13 +102,54 @@ func (e *ECCSigner) Sign(dataToBeSigned []byte) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	signature, err := ecdsa.SignASN1(
-		rand.Reader,
-		keyPair.Private.(*ecdsa.PrivateKey),
-		dataToBeSigned,
-	)
-	if err != nil {
-		return nil, err
+	privateKey := keyPair.Private.(*ecdsa.PrivateKey)
+
+	if scheme := ECDSAScheme(e.Device); scheme != ECDSASchemeSHA384 {
+		return nil, fmt.Errorf("unsupported ECDSA signature scheme: %s", scheme)
+	}
+	sum := sha512.Sum384(dataToBeSigned)
+	digest := sum[:]
+
+	var signature []byte
+	if e.Device.Deterministic {
+		r, s, err := signECDSADeterministic(privateKey, crypto.SHA384, digest)
+		if err != nil {
+			return nil, err
+		}
+		signature, err = asn1.Marshal(ecdsaSignature{R: r, S: s})
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		signature, err = ecdsa.SignASN1(
+			rand.Reader,
+			privateKey,
+			digest,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch ECDSAFormat(e.Device, e.Format) {
+	case ECDSAFormatDER:
+		return signature, nil
+	case ECDSAFormatPlain:
+		return ECDSASignatureToPlain(signature, privateKey.Curve)
+	default:
+		return nil, fmt.Errorf("unsupported ECDSA signature format: %s", ECDSAFormat(e.Device, e.Format))
+	}
+}
+
+// ECDSAFormat returns the signature encoding to use for an ECC device: the
+// requested format if given, else the device's, else ECDSAFormatDER.
+func ECDSAFormat(device *entity.Device, requested string) string {
+	switch {
+	case requested != "":
+		return requested
+	case device.SignatureFormat != "":
+		return device.SignatureFormat
+	default:
+		return ECDSAFormatDER
 	}
-	return signature, nil
 }
@@ -0,0 +1,91 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha512"
+	"errors"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/entity"
+)
+
+func newTestECCDevice(t *testing.T, scheme string, deterministic bool) *entity.Device {
+	t.Helper()
+	keyPair, err := (&ECCGenerator{}).Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, private, err := NewECCMarshalerWithEncoding(StandardEncoding).Marshal(*keyPair)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &entity.Device{
+		ID:              "device",
+		Algorithm:       "ECC",
+		PublicKey:       public,
+		PrivateKey:      private,
+		SignatureScheme: scheme,
+		Deterministic:   deterministic,
+	}
+}
+
+// TestECCSignerSchemes checks that ECC devices sign the SHA-384 digest of
+// the data, also devices without a recorded scheme, and that other schemes
+// are refused.
+func TestECCSignerSchemes(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 10)
+
+	for _, scheme := range []string{"", ECDSASchemeSHA384} {
+		device := newTestECCDevice(t, scheme, false)
+		signature, err := (&ECCSigner{Device: device}).Sign(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := (&ECCVerifier{Device: device}).Verify(data, signature); err != nil {
+			t.Errorf("scheme %q: %v", scheme, err)
+		}
+
+		keyPair, err := NewECCMarshaler().Unmarshal(device.PrivateKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		digest := sha512.Sum384(data)
+		if !ecdsa.VerifyASN1(keyPair.Public.(*ecdsa.PublicKey), digest[:], signature) {
+			t.Errorf("scheme %q: signature does not cover the SHA-384 digest", scheme)
+		}
+		// Data beyond the curve size is covered as well.
+		changed := append([]byte(nil), data...)
+		changed[len(changed)-1] ^= 1
+		if err := (&ECCVerifier{Device: device}).Verify(changed, signature); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("scheme %q: signature verified for changed data: %v", scheme, err)
+		}
+	}
+
+	device := newTestECCDevice(t, "RAW", false)
+	if _, err := (&ECCSigner{Device: device}).Sign(data); err == nil {
+		t.Error("signing with the raw scheme succeeded")
+	}
+	if err := (&ECCVerifier{Device: device}).Verify(data, make([]byte, 96)); err == nil || errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("verifying with the raw scheme: got %v, want an unsupported scheme error", err)
+	}
+}
+
+func TestECCSignerDeterministic(t *testing.T) {
+	device := newTestECCDevice(t, ECDSASchemeSHA384, true)
+	signer := &ECCSigner{Device: device}
+	first, err := signer.Sign([]byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := signer.Sign([]byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first, second) {
+		t.Error("deterministic signatures differ")
+	}
+	if err := (&ECCVerifier{Device: device}).Verify([]byte("data"), first); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,94 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/entity"
+)
+
+// ErrInvalidSignature is returned when a signature does not verify.
+var ErrInvalidSignature = errors.New("crypto: invalid signature")
+
+// Verifier defines a contract for checking signatures created by a Signer.
+type Verifier interface {
+	Verify(data, signature []byte) error
+}
+
+// RSAVerifier checks signatures created by an RSASigner.
+type RSAVerifier struct {
+	Device *entity.Device
+}
+
+func (r *RSAVerifier) Verify(data, signature []byte) error {
+	publicKey, err := UnmarshalPublicKey(r.Device.PublicKey)
+	if err != nil {
+		return err
+	}
+	rsaKey, ok := publicKey.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("crypto: expected RSA key, got %T", publicKey)
+	}
+	digest := sha256.Sum256(data)
+
+	switch RSAScheme(r.Device) {
+	case RSASchemePKCS1v15:
+		err = rsa.VerifyPKCS1v15(rsaKey, crypto.SHA256, digest[:], signature)
+	case RSASchemePSS:
+		err = rsa.VerifyPSS(rsaKey, crypto.SHA256, digest[:], signature, pssOptions)
+	default:
+		return fmt.Errorf("unsupported RSA signature scheme: %s", r.Device.SignatureScheme)
+	}
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// ECCVerifier checks signatures created by an ECCSigner. Both the DER and
+// the r||s encoding are accepted.
+type ECCVerifier struct {
+	Device *entity.Device
+}
+
+func (e *ECCVerifier) Verify(data, signature []byte) error {
+	publicKey, err := UnmarshalPublicKey(e.Device.PublicKey)
+	if err != nil {
+		return err
+	}
+	ecKey, ok := publicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("crypto: expected ECDSA key, got %T", publicKey)
+	}
+
+	if scheme := ECDSAScheme(e.Device); scheme != ECDSASchemeSHA384 {
+		return fmt.Errorf("unsupported ECDSA signature scheme: %s", scheme)
+	}
+	sum := sha512.Sum384(data)
+	digest := sum[:]
+
+	if ecdsa.VerifyASN1(ecKey, digest, signature) {
+		return nil
+	}
+	if der, err := ECDSASignatureToDER(signature, ecKey.Curve); err == nil && ecdsa.VerifyASN1(ecKey, digest, der) {
+		return nil
+	}
+	return ErrInvalidSignature
+}
+
+// NewVerifier returns the Verifier matching the algorithm of a device.
+func NewVerifier(device *entity.Device) (Verifier, error) {
+	switch device.Algorithm {
+	case "ECC":
+		return &ECCVerifier{Device: device}, nil
+	case "RSA":
+		return &RSAVerifier{Device: device}, nil
+	default:
+		return nil, fmt.Errorf("unsupported algorithm: %s", device.Algorithm)
+	}
+}
@@ -0,0 +1,70 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidWebhookSignature is returned when a webhook signature header
+// does not match the payload.
+var ErrInvalidWebhookSignature = errors.New("invalid webhook signature")
+
+// SignWebhookPayload returns the signature header of a webhook delivery,
+// "t=<unix time>,v1=<hex HMAC-SHA256>". The HMAC is computed with the
+// webhook secret over "<unix time>.<payload>", so the timestamp cannot be
+// replaced without invalidating the signature.
+func SignWebhookPayload(secret string, timestamp time.Time, payload []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	return "t=" + t + ",v1=" + hex.EncodeToString(webhookMAC(secret, t, payload))
+}
+
+// VerifyWebhookSignature checks a signature header created by
+// SignWebhookPayload. Signatures older than tolerance are rejected unless
+// tolerance is zero.
+func VerifyWebhookSignature(secret, header string, payload []byte, tolerance time.Duration) error {
+	var t string
+	var signatures [][]byte
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			t = value
+		case "v1":
+			if signature, err := hex.DecodeString(value); err == nil {
+				signatures = append(signatures, signature)
+			}
+		}
+	}
+
+	unix, err := strconv.ParseInt(t, 10, 64)
+	if err != nil {
+		return ErrInvalidWebhookSignature
+	}
+	if tolerance > 0 && time.Since(time.Unix(unix, 0)) > tolerance {
+		return ErrInvalidWebhookSignature
+	}
+
+	expected := webhookMAC(secret, t, payload)
+	for _, signature := range signatures {
+		if hmac.Equal(signature, expected) {
+			return nil
+		}
+	}
+	return ErrInvalidWebhookSignature
+}
+
+func webhookMAC(secret, timestamp string, payload []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
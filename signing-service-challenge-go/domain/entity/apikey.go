@@ -0,0 +1,18 @@
+package entity
+
+import "time"
+
+// APIKey is an issued API key. Only the SHA-256 hash of the key is stored;
+// the key itself is returned once, when it is issued. Empty DeviceIDs allow
+// all devices.
+type APIKey struct {
+	ID   string
+	Name string
+	// Prefix holds the first characters of the key, so that clients can
+	// tell their keys apart.
+	Prefix      string
+	Hash        []byte `json:"-"`
+	Permissions []string
+	DeviceIDs   []string
+	CreatedAt   time.Time
+}
@@ -1,17 +1,41 @@
 package entity
 
+import (
+	"encoding/base64"
+	"fmt"
+	"time"
+)
+
 type Transaction struct {
 	ID               string
 	DeviceID         string
 	SignatureCounter int
 	Data             []byte
 	LastSignatureID  []byte
+	Signature        []byte
+	SignedAt         time.Time
+	TimestampToken   []byte
+}
+
+// SecuredData returns the string that was signed for the transaction:
+// <signature_counter>_<data>_<last_signature_base64_encoded>.
+func (t *Transaction) SecuredData() string {
+	return fmt.Sprintf("%d_%s_%s", t.SignatureCounter, t.Data, base64.StdEncoding.EncodeToString(t.LastSignatureID))
 }
 
 type Device struct {
 	ID               string
 	Label            string
 	Algorithm        string
+	PublicKey        []byte
 	PrivateKey       []byte
+	Certificate      []byte
+	Deterministic    bool
+	SignatureScheme  string
+	SignatureFormat  string
 	SignatureCounter int
+	LastSignature    []byte
+	// BatchWindow is how long items are collected before their Merkle
+	// root is signed. Zero disables batched signing.
+	BatchWindow time.Duration
 }
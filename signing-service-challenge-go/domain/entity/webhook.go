@@ -0,0 +1,37 @@
+package entity
+
+import "time"
+
+// Webhook is a URL that receives device and transaction events. Empty
+// EventTypes or DeviceIDs match all events.
+type Webhook struct {
+	ID         string
+	URL        string
+	Secret     string `json:"-"`
+	EventTypes []string
+	DeviceIDs  []string
+	CreatedAt  time.Time
+}
+
+// Delivery states of a WebhookDelivery.
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookDelivery is a queued or completed delivery of one event to a webhook.
+type WebhookDelivery struct {
+	ID             string
+	WebhookID      string
+	EventID        uint64
+	EventType      string
+	Payload        []byte
+	Status         string
+	Attempts       int
+	NextAttemptAt  time.Time
+	LastAttemptAt  time.Time
+	ResponseStatus int
+	LastError      string
+	CreatedAt      time.Time
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"sort"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/entity"
+)
+
+func (r *repository) CreateAPIKey(key *entity.APIKey) (*entity.APIKey, error) {
+	r.repo.APIKeyRWLock.Lock()
+	defer r.repo.APIKeyRWLock.Unlock()
+
+	if _, exists := r.repo.APIKey[key.ID]; exists {
+		return nil, ErrAPIKeyExists
+	}
+	if _, exists := r.repo.APIKeyHash[string(key.Hash)]; exists {
+		return nil, ErrAPIKeyExists
+	}
+
+	r.repo.APIKey[key.ID] = key
+	r.repo.APIKeyHash[string(key.Hash)] = key.ID
+	return key, nil
+}
+
+func (r *repository) GetAPIKey(id string) (*entity.APIKey, error) {
+	r.repo.APIKeyRWLock.RLock()
+	defer r.repo.APIKeyRWLock.RUnlock()
+
+	key, exists := r.repo.APIKey[id]
+	if !exists {
+		return nil, ErrAPIKeyNotFound
+	}
+
+	return key, nil
+}
+
+// GetAPIKeyByHash returns the API key with the given SHA-256 hash.
+func (r *repository) GetAPIKeyByHash(hash []byte) (*entity.APIKey, error) {
+	r.repo.APIKeyRWLock.RLock()
+	defer r.repo.APIKeyRWLock.RUnlock()
+
+	id, exists := r.repo.APIKeyHash[string(hash)]
+	if !exists {
+		return nil, ErrAPIKeyNotFound
+	}
+
+	return r.repo.APIKey[id], nil
+}
+
+func (r *repository) ListAPIKeys() ([]*entity.APIKey, error) {
+	r.repo.APIKeyRWLock.RLock()
+	defer r.repo.APIKeyRWLock.RUnlock()
+
+	keys := make([]*entity.APIKey, 0, len(r.repo.APIKey))
+	for _, key := range r.repo.APIKey {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+
+	return keys, nil
+}
+
+func (r *repository) CountAPIKeys() (int, error) {
+	r.repo.APIKeyRWLock.RLock()
+	defer r.repo.APIKeyRWLock.RUnlock()
+
+	return len(r.repo.APIKey), nil
+}
+
+// DeleteAPIKey removes an API key, which revokes it.
+func (r *repository) DeleteAPIKey(id string) error {
+	r.repo.APIKeyRWLock.Lock()
+	defer r.repo.APIKeyRWLock.Unlock()
+
+	key, exists := r.repo.APIKey[id]
+	if !exists {
+		return ErrAPIKeyNotFound
+	}
+
+	delete(r.repo.APIKey, id)
+	delete(r.repo.APIKeyHash, string(key.Hash))
+	return nil
+}
@@ -1,8 +1,6 @@
 package repository
 
 import (
-	"errors"
-
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/entity"
 )
 
@@ -13,7 +11,7 @@ func (r *repository) CreateSignatureDevice(device *entity.Device) (*entity.Devic
 
 	// Check if the device with the same ID already exists
 	if _, exists := r.repo.Device[device.ID]; exists {
-		return nil, errors.New("Device with the same ID already exists")
+		return nil, ErrDeviceExists
 	}
 
 	r.repo.Device[device.ID] = device
@@ -45,23 +43,38 @@ func (r *repository) GetSignatureDevice(id string) (*entity.Device, error) {
 	// Retrieve the device by ID
 	device, exists := r.repo.Device[id]
 	if !exists {
-		return nil, errors.New("Device not found")
+		return nil, ErrDeviceNotFound
 	}
 
 	return device, nil
 }
 
+func (r *repository) UpdateSignatureDevice(device *entity.Device) (*entity.Device, error) {
+	r.repo.DeviceRWLock.Lock()
+	defer r.repo.DeviceRWLock.Unlock()
+
+	if _, exists := r.repo.Device[device.ID]; !exists {
+		return nil, ErrDeviceNotFound
+	}
+
+	r.repo.Device[device.ID] = device
+	return device, nil
+}
+
 func (r *repository) SignTransaction(transaction *entity.Transaction) (*entity.Transaction, error) {
 	r.repo.SignatureRWLock.Lock()
 	defer r.repo.SignatureRWLock.Unlock()
 
 	// Check if the signature with the same ID already exists
 	if _, exists := r.repo.Transaction[transaction.ID]; exists {
-		return nil, errors.New("Transaction with the same ID already exists")
+		return nil, ErrTransactionExists
 	}
 
+	if err := r.advanceDevice(transaction); err != nil {
+		return nil, err
+	}
 	r.repo.Transaction[transaction.ID] = transaction
-	r.incrementCounter(transaction.DeviceID)
+	r.repo.DeviceTransaction[transaction.DeviceID] = append(r.repo.DeviceTransaction[transaction.DeviceID], transaction.ID)
 	return transaction, nil
 }
 
@@ -88,18 +101,112 @@ func (r *repository) GetTransaction(id string) (*entity.Transaction, error) {
 	// Retrieve the signature by ID
 	signature, exists := r.repo.Transaction[id]
 	if !exists {
-		return nil, errors.New("Transaction not found")
+		return nil, ErrTransactionNotFound
 	}
 
 	return signature, nil
 }
 
-func (r *repository) incrementCounter(id string) error {
+// GetTransactionByCounter returns the transaction of a device with the given
+// signature counter.
+func (r *repository) GetTransactionByCounter(deviceID string, counter int) (*entity.Transaction, error) {
+	r.repo.SignatureRWLock.RLock()
+	defer r.repo.SignatureRWLock.RUnlock()
+
+	ids := r.repo.DeviceTransaction[deviceID]
+	if counter < 0 || counter >= len(ids) {
+		return nil, ErrTransactionNotFound
+	}
+
+	return r.repo.Transaction[ids[counter]], nil
+}
+
+// ListTransactionsByCounter returns up to limit transactions of the device
+// in counter order, starting at counter.
+func (r *repository) ListTransactionsByCounter(deviceID string, counter, limit int) ([]*entity.Transaction, error) {
+	r.repo.SignatureRWLock.RLock()
+	defer r.repo.SignatureRWLock.RUnlock()
+
+	ids := r.repo.DeviceTransaction[deviceID]
+	if counter < 0 || counter >= len(ids) {
+		return nil, nil
+	}
+	if limit > len(ids)-counter {
+		limit = len(ids) - counter
+	}
+
+	signatures := make([]*entity.Transaction, 0, limit)
+	for _, id := range ids[counter : counter+limit] {
+		signatures = append(signatures, r.repo.Transaction[id])
+	}
+	return signatures, nil
+}
+
+// advanceDevice increments the signature counter of the transaction's device
+// and records the transaction's signature as the device's last signature.
+func (r *repository) advanceDevice(transaction *entity.Transaction) error {
+	r.repo.DeviceRWLock.Lock()
+	defer r.repo.DeviceRWLock.Unlock()
+
 	// Retrieve the device by ID
-	_, exists := r.repo.Device[id]
+	device, exists := r.repo.Device[transaction.DeviceID]
 	if !exists {
-		return errors.New("Device not found")
+		return ErrDeviceNotFound
+	}
+	if device.SignatureCounter != transaction.SignatureCounter {
+		return ErrCounterOutOfSequence
+	}
+	device.SignatureCounter += 1
+	device.LastSignature = transaction.Signature
+	return nil
+}
+
+// RestoreDevices stores devices together with their transactions, which
+// must be in counter order and advance the devices' counters as
+// SignTransaction does. The store must not hold any device or transaction;
+// nothing is stored unless every device and transaction can be.
+func (r *repository) RestoreDevices(devices []*entity.Device, transactions []*entity.Transaction) error {
+	r.repo.SignatureRWLock.Lock()
+	defer r.repo.SignatureRWLock.Unlock()
+	r.repo.DeviceRWLock.Lock()
+	defer r.repo.DeviceRWLock.Unlock()
+
+	if len(r.repo.Device) > 0 || len(r.repo.Transaction) > 0 {
+		return ErrStoreNotEmpty
+	}
+
+	counters := make(map[string]int, len(devices))
+	for _, device := range devices {
+		if _, exists := counters[device.ID]; exists {
+			return ErrDeviceExists
+		}
+		counters[device.ID] = device.SignatureCounter
+	}
+	ids := make(map[string]bool, len(transactions))
+	for _, transaction := range transactions {
+		counter, exists := counters[transaction.DeviceID]
+		if !exists {
+			return ErrDeviceNotFound
+		}
+		if ids[transaction.ID] {
+			return ErrTransactionExists
+		}
+		if transaction.SignatureCounter != counter {
+			return ErrCounterOutOfSequence
+		}
+		counters[transaction.DeviceID]++
+		ids[transaction.ID] = true
+	}
+
+	for _, device := range devices {
+		r.repo.Device[device.ID] = device
+	}
+	for _, transaction := range transactions {
+		device := r.repo.Device[transaction.DeviceID]
+		device.SignatureCounter += 1
+		device.LastSignature = transaction.Signature
+		r.repo.Transaction[transaction.ID] = transaction
+		r.repo.DeviceTransaction[transaction.DeviceID] = append(r.repo.DeviceTransaction[transaction.DeviceID], transaction.ID)
 	}
-	r.repo.Device[id].SignatureCounter += 1
 	return nil
 }
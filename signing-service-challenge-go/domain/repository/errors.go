@@ -0,0 +1,30 @@
+package repository
+
+import "errors"
+
+var (
+	// ErrDeviceNotFound is returned when no device has the requested ID.
+	ErrDeviceNotFound = errors.New("Device not found")
+	// ErrDeviceExists is returned when a device ID is already taken.
+	ErrDeviceExists = errors.New("Device with the same ID already exists")
+	// ErrTransactionNotFound is returned when no transaction has the requested ID.
+	ErrTransactionNotFound = errors.New("Transaction not found")
+	// ErrTransactionExists is returned when a transaction ID is already taken.
+	ErrTransactionExists = errors.New("Transaction with the same ID already exists")
+	// ErrCounterOutOfSequence is returned when a transaction does not continue the device's signature chain.
+	ErrCounterOutOfSequence = errors.New("Signature counter out of sequence")
+	// ErrStoreNotEmpty is returned when devices are restored into a store that already holds devices or transactions.
+	ErrStoreNotEmpty = errors.New("Store is not empty")
+	// ErrWebhookNotFound is returned when no webhook has the requested ID.
+	ErrWebhookNotFound = errors.New("Webhook not found")
+	// ErrWebhookExists is returned when a webhook ID is already taken.
+	ErrWebhookExists = errors.New("Webhook with the same ID already exists")
+	// ErrDeliveryNotFound is returned when no webhook delivery has the requested ID.
+	ErrDeliveryNotFound = errors.New("Delivery not found")
+	// ErrDeliveryExists is returned when a webhook delivery ID is already taken.
+	ErrDeliveryExists = errors.New("Delivery with the same ID already exists")
+	// ErrAPIKeyNotFound is returned when no API key has the requested ID or hash.
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	// ErrAPIKeyExists is returned when an API key ID or hash is already taken.
+	ErrAPIKeyExists = errors.New("API key with the same ID already exists")
+)
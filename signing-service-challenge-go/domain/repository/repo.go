@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"time"
+
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/entity"
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
 )
@@ -13,17 +15,36 @@ func NewRepository(db *persistence.Database) *repository {
 	return &repository{repo: db}
 }
 
-
-
-
-
-
-
 type Repository interface {
 	CreateSignatureDevice(device *entity.Device) (*entity.Device, error)
 	GetSignatureDevice(id string) (*entity.Device, error)
 	GetTransaction(id string) (*entity.Transaction, error)
 	ListSignatureDevices(id, label, algorithm string) ([]*entity.Device, error)
 	ListTransactions(deviceID string) ([]*entity.Transaction, error)
+	GetTransactionByCounter(deviceID string, counter int) (*entity.Transaction, error)
+	ListTransactionsByCounter(deviceID string, counter, limit int) ([]*entity.Transaction, error)
 	SignTransaction(signature *entity.Transaction) (*entity.Transaction, error)
+	UpdateSignatureDevice(device *entity.Device) (*entity.Device, error)
+	RestoreDevices(devices []*entity.Device, transactions []*entity.Transaction) error
+	CreateWebhook(webhook *entity.Webhook) (*entity.Webhook, error)
+	GetWebhook(id string) (*entity.Webhook, error)
+	ListWebhooks() ([]*entity.Webhook, error)
+	DeleteWebhook(id string) error
+	CreateWebhookDelivery(delivery *entity.WebhookDelivery) (*entity.WebhookDelivery, error)
+	GetWebhookDelivery(id string) (*entity.WebhookDelivery, error)
+	UpdateWebhookDelivery(delivery *entity.WebhookDelivery) (*entity.WebhookDelivery, error)
+	ListWebhookDeliveries(webhookID string) ([]*entity.WebhookDelivery, error)
+	DueWebhookDeliveries(now time.Time) ([]*entity.WebhookDelivery, error)
+	CreateAPIKey(key *entity.APIKey) (*entity.APIKey, error)
+	GetAPIKey(id string) (*entity.APIKey, error)
+	GetAPIKeyByHash(hash []byte) (*entity.APIKey, error)
+	ListAPIKeys() ([]*entity.APIKey, error)
+	CountAPIKeys() (int, error)
+	DeleteAPIKey(id string) error
+	Close() error
+}
+
+// Close flushes and closes the underlying database.
+func (r *repository) Close() error {
+	return r.repo.Close()
 }
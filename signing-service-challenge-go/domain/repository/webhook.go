@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"sort"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/entity"
+)
+
+func (r *repository) CreateWebhook(webhook *entity.Webhook) (*entity.Webhook, error) {
+	r.repo.WebhookRWLock.Lock()
+	defer r.repo.WebhookRWLock.Unlock()
+
+	if _, exists := r.repo.Webhook[webhook.ID]; exists {
+		return nil, ErrWebhookExists
+	}
+
+	r.repo.Webhook[webhook.ID] = webhook
+	return webhook, nil
+}
+
+func (r *repository) GetWebhook(id string) (*entity.Webhook, error) {
+	r.repo.WebhookRWLock.RLock()
+	defer r.repo.WebhookRWLock.RUnlock()
+
+	webhook, exists := r.repo.Webhook[id]
+	if !exists {
+		return nil, ErrWebhookNotFound
+	}
+
+	return webhook, nil
+}
+
+func (r *repository) ListWebhooks() ([]*entity.Webhook, error) {
+	r.repo.WebhookRWLock.RLock()
+	defer r.repo.WebhookRWLock.RUnlock()
+
+	webhooks := make([]*entity.Webhook, 0, len(r.repo.Webhook))
+	for _, webhook := range r.repo.Webhook {
+		webhooks = append(webhooks, webhook)
+	}
+	sort.Slice(webhooks, func(i, j int) bool {
+		return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt)
+	})
+
+	return webhooks, nil
+}
+
+// DeleteWebhook removes a webhook together with its delivery log.
+func (r *repository) DeleteWebhook(id string) error {
+	r.repo.WebhookRWLock.Lock()
+	defer r.repo.WebhookRWLock.Unlock()
+
+	if _, exists := r.repo.Webhook[id]; !exists {
+		return ErrWebhookNotFound
+	}
+
+	delete(r.repo.Webhook, id)
+	for deliveryID, delivery := range r.repo.WebhookDelivery {
+		if delivery.WebhookID == id {
+			delete(r.repo.WebhookDelivery, deliveryID)
+		}
+	}
+	return nil
+}
+
+func (r *repository) CreateWebhookDelivery(delivery *entity.WebhookDelivery) (*entity.WebhookDelivery, error) {
+	r.repo.WebhookRWLock.Lock()
+	defer r.repo.WebhookRWLock.Unlock()
+
+	if _, exists := r.repo.Webhook[delivery.WebhookID]; !exists {
+		return nil, ErrWebhookNotFound
+	}
+	if _, exists := r.repo.WebhookDelivery[delivery.ID]; exists {
+		return nil, ErrDeliveryExists
+	}
+
+	r.repo.WebhookDelivery[delivery.ID] = delivery
+	return delivery, nil
+}
+
+func (r *repository) GetWebhookDelivery(id string) (*entity.WebhookDelivery, error) {
+	r.repo.WebhookRWLock.RLock()
+	defer r.repo.WebhookRWLock.RUnlock()
+
+	delivery, exists := r.repo.WebhookDelivery[id]
+	if !exists {
+		return nil, ErrDeliveryNotFound
+	}
+
+	return delivery, nil
+}
+
+// UpdateWebhookDelivery replaces a stored delivery. Stored deliveries are
+// never modified in place, so callers update a copy.
+func (r *repository) UpdateWebhookDelivery(delivery *entity.WebhookDelivery) (*entity.WebhookDelivery, error) {
+	r.repo.WebhookRWLock.Lock()
+	defer r.repo.WebhookRWLock.Unlock()
+
+	if _, exists := r.repo.WebhookDelivery[delivery.ID]; !exists {
+		return nil, ErrDeliveryNotFound
+	}
+
+	r.repo.WebhookDelivery[delivery.ID] = delivery
+	return delivery, nil
+}
+
+func (r *repository) ListWebhookDeliveries(webhookID string) ([]*entity.WebhookDelivery, error) {
+	r.repo.WebhookRWLock.RLock()
+	defer r.repo.WebhookRWLock.RUnlock()
+
+	var deliveries []*entity.WebhookDelivery
+	for _, delivery := range r.repo.WebhookDelivery {
+		if webhookID == "" || delivery.WebhookID == webhookID {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	sortDeliveries(deliveries)
+
+	return deliveries, nil
+}
+
+// DueWebhookDeliveries returns the pending deliveries whose next attempt is due.
+func (r *repository) DueWebhookDeliveries(now time.Time) ([]*entity.WebhookDelivery, error) {
+	r.repo.WebhookRWLock.RLock()
+	defer r.repo.WebhookRWLock.RUnlock()
+
+	var deliveries []*entity.WebhookDelivery
+	for _, delivery := range r.repo.WebhookDelivery {
+		if delivery.Status == entity.DeliveryPending && !delivery.NextAttemptAt.After(now) {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	sortDeliveries(deliveries)
+
+	return deliveries, nil
+}
+
+// sortDeliveries orders deliveries by creation, and by event for deliveries
+// created at the same time.
+func sortDeliveries(deliveries []*entity.WebhookDelivery) {
+	sort.Slice(deliveries, func(i, j int) bool {
+		if !deliveries[i].CreatedAt.Equal(deliveries[j].CreatedAt) {
+			return deliveries[i].CreatedAt.Before(deliveries[j].CreatedAt)
+		}
+		return deliveries[i].EventID < deliveries[j].EventID
+	})
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/entity"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/repository"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/validation"
+	"github.com/google/uuid"
+)
+
+// APIKeyPrefix starts every issued API key, so leaked keys are easy to
+// recognise, e.g. by secret scanners.
+const APIKeyPrefix = "ssk_"
+
+// apiKeyPrefixLength is how many characters of a key are kept to identify
+// it: the prefix and six random characters.
+const apiKeyPrefixLength = len(APIKeyPrefix) + 6
+
+type APIKeyService interface {
+	CreateAPIKey(input *validation.CreateAPIKeyInput) (*validation.CreateAPIKeyOutput, error)
+	ListAPIKey() (*validation.ListAPIKeyOutput, error)
+	GetAPIKey(input *validation.GetAPIKeyInput) (*validation.GetAPIKeyOutput, error)
+	RevokeAPIKey(input *validation.RevokeAPIKeyInput) (*validation.RevokeAPIKeyOutput, error)
+	// Authenticate returns the issued API key matching key, or
+	// repository.ErrAPIKeyNotFound.
+	Authenticate(key string) (*entity.APIKey, error)
+	// HasAPIKeys reports whether any API key has been issued.
+	HasAPIKeys() (bool, error)
+}
+
+type apiKeyService struct {
+	repo repository.Repository
+}
+
+func NewAPIKeyService(repo repository.Repository) APIKeyService {
+	return &apiKeyService{repo: repo}
+}
+
+// CreateAPIKey issues an API key with 256 random bits. Keys are looked up
+// by their SHA-256 hash; a slow password hash is not needed for keys of
+// this strength.
+func (a *apiKeyService) CreateAPIKey(input *validation.CreateAPIKeyInput) (*validation.CreateAPIKeyOutput, error) {
+	if err := input.IsValid(); err != nil {
+		return nil, err
+	}
+	for _, id := range input.DeviceIDs {
+		if _, err := a.repo.GetSignatureDevice(id); err != nil {
+			if errors.Is(err, repository.ErrDeviceNotFound) {
+				return nil, fmt.Errorf("unknown device: %s", id)
+			}
+			return nil, err
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	key := APIKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	hash := sha256.Sum256([]byte(key))
+
+	apiKey := &entity.APIKey{
+		ID:          uuid.New().String(),
+		Name:        input.Name,
+		Prefix:      key[:apiKeyPrefixLength],
+		Hash:        hash[:],
+		Permissions: input.Permissions,
+		DeviceIDs:   input.DeviceIDs,
+		CreatedAt:   time.Now().UTC(),
+	}
+
+	if _, err := a.repo.CreateAPIKey(apiKey); err != nil {
+		return nil, err
+	}
+
+	return &validation.CreateAPIKeyOutput{APIKey: apiKey, Key: key}, nil
+}
+
+func (a *apiKeyService) ListAPIKey() (*validation.ListAPIKeyOutput, error) {
+	keys, err := a.repo.ListAPIKeys()
+	if err != nil {
+		return nil, err
+	}
+	return &validation.ListAPIKeyOutput{APIKey: keys}, nil
+}
+
+func (a *apiKeyService) GetAPIKey(input *validation.GetAPIKeyInput) (*validation.GetAPIKeyOutput, error) {
+	key, err := a.repo.GetAPIKey(input.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &validation.GetAPIKeyOutput{APIKey: key}, nil
+}
+
+// RevokeAPIKey deletes an API key; requests with it fail from now on.
+func (a *apiKeyService) RevokeAPIKey(input *validation.RevokeAPIKeyInput) (*validation.RevokeAPIKeyOutput, error) {
+	if err := a.repo.DeleteAPIKey(input.ID); err != nil {
+		return nil, err
+	}
+	return &validation.RevokeAPIKeyOutput{Status: "API Key Revoked"}, nil
+}
+
+func (a *apiKeyService) Authenticate(key string) (*entity.APIKey, error) {
+	hash := sha256.Sum256([]byte(key))
+	return a.repo.GetAPIKeyByHash(hash[:])
+}
+
+func (a *apiKeyService) HasAPIKeys() (bool, error) {
+	count, err := a.repo.CountAPIKeys()
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
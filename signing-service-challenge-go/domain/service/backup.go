@@ -0,0 +1,140 @@
+package service
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/backup"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/entity"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/repository"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/validation"
+)
+
+// Backup returns an encrypted snapshot of all devices, including their
+// private keys, and their transactions. Signing is paused on all devices
+// while their state is copied, so the snapshot is a single point in time;
+// transactions signed afterwards are left out.
+func (d *deviceService) Backup() (*validation.BackupOutput, error) {
+	if d.backupKey == nil {
+		return nil, ErrNoBackupKey
+	}
+
+	devices, err := d.snapshotDevices()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &backup.Snapshot{
+		Version:   backup.SnapshotVersion,
+		CreatedAt: time.Now().UTC(),
+		Devices:   make([]backup.Device, 0, len(devices)),
+	}
+	transactions := 0
+	for _, device := range devices {
+		entry := backup.Device{Device: device, Transactions: make([]*entity.Transaction, 0, device.SignatureCounter)}
+		for counter := 0; counter < device.SignatureCounter; counter++ {
+			transaction, err := d.repo.GetTransactionByCounter(device.ID, counter)
+			if err != nil {
+				return nil, err
+			}
+			entry.Transactions = append(entry.Transactions, transaction)
+		}
+		transactions += len(entry.Transactions)
+		snapshot.Devices = append(snapshot.Devices, entry)
+	}
+
+	data, err := backup.Encrypt(d.backupKey, snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	return &validation.BackupOutput{
+		Backup:       data,
+		Devices:      len(snapshot.Devices),
+		Transactions: transactions,
+	}, nil
+}
+
+// snapshotDevices returns copies of all devices, taken while signing is
+// paused on all of them. Locks are taken in ID order, so concurrent
+// snapshots cannot deadlock.
+func (d *deviceService) snapshotDevices() ([]*entity.Device, error) {
+	devices, err := d.repo.ListSignatureDevices("", "", "")
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].ID < devices[j].ID
+	})
+
+	unlocks := make([]func(), 0, len(devices))
+	defer func() {
+		for _, unlock := range unlocks {
+			unlock()
+		}
+	}()
+	for _, device := range devices {
+		unlocks = append(unlocks, d.lockDevice(device.ID))
+	}
+
+	snapshots := make([]*entity.Device, 0, len(devices))
+	for _, device := range devices {
+		device, err := d.repo.GetSignatureDevice(device.ID)
+		if err != nil {
+			return nil, err
+		}
+		snapshot := *device
+		snapshots = append(snapshots, &snapshot)
+	}
+	return snapshots, nil
+}
+
+// Restore decrypts a snapshot written by Backup, verifies it and replays
+// it into the store, which must not hold any device or transaction. Nothing
+// is written unless every device's keys, counter and signature chain
+// verify. The devices and their transactions are stored at once, so no
+// device can be created or signed with while the snapshot is replayed.
+func (d *deviceService) Restore(input *validation.RestoreInput) (*validation.RestoreOutput, error) {
+	if err := input.IsValid(); err != nil {
+		return nil, err
+	}
+	if d.backupKey == nil {
+		return nil, ErrNoBackupKey
+	}
+	leave, err := d.drain.enter()
+	if err != nil {
+		return nil, err
+	}
+	defer leave()
+
+	snapshot, err := backup.Decrypt(d.backupKey, input.Backup)
+	if err != nil {
+		return nil, err
+	}
+	if err := backup.Verify(snapshot); err != nil {
+		return nil, err
+	}
+
+	// Devices start with a fresh counter, which their transactions advance
+	// to the backed up value.
+	output := &validation.RestoreOutput{}
+	devices := make([]*entity.Device, 0, len(snapshot.Devices))
+	var transactions []*entity.Transaction
+	for _, entry := range snapshot.Devices {
+		device := *entry.Device
+		device.SignatureCounter = 0
+		device.LastSignature = nil
+		devices = append(devices, &device)
+		transactions = append(transactions, entry.Transactions...)
+		output.Devices++
+		output.Transactions += len(entry.Transactions)
+	}
+	if err := d.repo.RestoreDevices(devices, transactions); err != nil {
+		if errors.Is(err, repository.ErrStoreNotEmpty) {
+			return nil, ErrStoreNotEmpty
+		}
+		return nil, err
+	}
+	return output, nil
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/backup"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/repository"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/validation"
+)
+
+var testBackupKey = bytes.Repeat([]byte{7}, backup.KeySize)
+
+// newBackup returns a backup of the test devices, each with three
+// transactions, and the store it was taken of.
+func newBackup(t *testing.T) ([]byte, *deviceService) {
+	t.Helper()
+	d, _ := newTestService(t, WithBackupKey(testBackupKey))
+	for _, id := range createTestDevices(t, d) {
+		for i := 0; i < 3; i++ {
+			if _, err := d.SignTransaction(&validation.SignTransactionInput{DeviceID: id, Data: []byte("data")}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	output, err := d.Backup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output.Devices != 3 || output.Transactions != 9 {
+		t.Fatalf("backup holds %d devices and %d transactions, want 3 and 9", output.Devices, output.Transactions)
+	}
+	return output.Backup, d
+}
+
+func TestBackupRestore(t *testing.T) {
+	data, source := newBackup(t)
+	d, repo := newTestService(t, WithBackupKey(testBackupKey))
+	output, err := d.Restore(&validation.RestoreInput{Backup: data})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output.Devices != 3 || output.Transactions != 9 {
+		t.Errorf("restored %d devices and %d transactions, want 3 and 9", output.Devices, output.Transactions)
+	}
+
+	for _, id := range []string{"ecc", "rsa-PSS", "rsa-PKCS1v15"} {
+		want, err := source.repo.GetSignatureDevice(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		device, err := repo.GetSignatureDevice(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if device.SignatureCounter != want.SignatureCounter || !bytes.Equal(device.LastSignature, want.LastSignature) || !bytes.Equal(device.PrivateKey, want.PrivateKey) {
+			t.Errorf("%s: restored device differs from the backed up one", id)
+		}
+		// The chain continues with the restored counter.
+		signed, err := d.SignTransaction(&validation.SignTransactionInput{DeviceID: id, Data: []byte("data")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if prefix := fmt.Sprintf("%d_data_%s", want.SignatureCounter, base64.StdEncoding.EncodeToString(want.LastSignature)); signed.SignedData != prefix {
+			t.Errorf("%s: signed %q, want %q", id, signed.SignedData, prefix)
+		}
+	}
+
+	if _, err := d.Restore(&validation.RestoreInput{Backup: data}); !errors.Is(err, ErrStoreNotEmpty) {
+		t.Errorf("restore into a store with devices: got %v, want ErrStoreNotEmpty", err)
+	}
+	other, _ := newTestService(t, WithBackupKey(bytes.Repeat([]byte{8}, backup.KeySize)))
+	if _, err := other.Restore(&validation.RestoreInput{Backup: data}); !errors.Is(err, backup.ErrDecrypt) {
+		t.Errorf("restore with another key: got %v, want ErrDecrypt", err)
+	}
+}
+
+func TestRestoreRejectsTamperedBackups(t *testing.T) {
+	data, _ := newBackup(t)
+	tests := []struct {
+		name   string
+		tamper func(*backup.Snapshot)
+	}{
+		{"transaction data", func(s *backup.Snapshot) { s.Devices[0].Transactions[1].Data = []byte("other") }},
+		{"transaction signature", func(s *backup.Snapshot) { s.Devices[1].Transactions[0].Signature[0] ^= 1 }},
+		{"dropped transaction", func(s *backup.Snapshot) {
+			transactions := s.Devices[2].Transactions
+			s.Devices[2].Transactions = append(transactions[:1:1], transactions[2:]...)
+		}},
+		{"counter", func(s *backup.Snapshot) { s.Devices[0].Device.SignatureCounter++ }},
+		{"private key", func(s *backup.Snapshot) { s.Devices[0].Device.PrivateKey = s.Devices[1].Device.PrivateKey }},
+		{"duplicate device", func(s *backup.Snapshot) { s.Devices[1].Device.ID = s.Devices[0].Device.ID }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snapshot, err := backup.Decrypt(testBackupKey, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := backup.Verify(snapshot); err != nil {
+				t.Fatalf("untampered snapshot: %v", err)
+			}
+			tt.tamper(snapshot)
+			if err := backup.Verify(snapshot); !errors.Is(err, backup.ErrInvalidSnapshot) {
+				t.Fatalf("got %v, want ErrInvalidSnapshot", err)
+			}
+
+			tampered, err := backup.Encrypt(testBackupKey, snapshot)
+			if err != nil {
+				t.Fatal(err)
+			}
+			d, repo := newTestService(t, WithBackupKey(testBackupKey))
+			if _, err := d.Restore(&validation.RestoreInput{Backup: tampered}); !errors.Is(err, backup.ErrInvalidSnapshot) {
+				t.Errorf("restore: got %v, want ErrInvalidSnapshot", err)
+			}
+			if devices, _ := repo.ListSignatureDevices("", "", ""); len(devices) != 0 {
+				t.Errorf("restore of a tampered backup stored %d devices", len(devices))
+			}
+		})
+	}
+}
+
+// TestRestoreIsAtomic creates a device of the snapshot during its restore:
+// either the restore fails and only the created device is stored, or the
+// device cannot be created next to the complete snapshot.
+func TestRestoreIsAtomic(t *testing.T) {
+	data, _ := newBackup(t)
+	for i := 0; i < 20; i++ {
+		d, repo := newTestService(t, WithBackupKey(testBackupKey))
+		var (
+			wg                    sync.WaitGroup
+			restoreErr, createErr error
+		)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, restoreErr = d.Restore(&validation.RestoreInput{Backup: data})
+		}()
+		go func() {
+			defer wg.Done()
+			_, createErr = d.CreateSignatureDevice(&validation.CreateSignatureDeviceInput{ID: "rsa-PSS", Algorithm: "ECC"})
+		}()
+		wg.Wait()
+
+		devices, err := repo.ListSignatureDevices("", "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		transactions, err := repo.ListTransactions("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case restoreErr == nil && errors.Is(createErr, repository.ErrDeviceExists):
+			if len(devices) != 3 || len(transactions) != 9 {
+				t.Fatalf("restore stored %d devices and %d transactions, want 3 and 9", len(devices), len(transactions))
+			}
+		case errors.Is(restoreErr, ErrStoreNotEmpty) && createErr == nil:
+			if len(devices) != 1 || len(transactions) != 0 {
+				t.Fatalf("failed restore left %d devices and %d transactions, want only the created device", len(devices), len(transactions))
+			}
+		default:
+			t.Fatalf("restore: %v, create: %v", restoreErr, createErr)
+		}
+	}
+}
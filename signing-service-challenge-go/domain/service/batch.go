@@ -0,0 +1,253 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/entity"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/validation"
+)
+
+// maxBatchSize closes a batch early so that a single signature never has to
+// cover an unbounded number of items.
+const maxBatchSize = 1 << 16
+
+// batchItem is an item waiting for the signature of its batch.
+type batchItem struct {
+	data   []byte
+	output *validation.SignBatchedOutput
+	err    error
+	done   chan struct{}
+}
+
+// batcher collects the items of one device for the device's batch window
+// and hands them to flush as a single batch.
+type batcher struct {
+	window time.Duration
+	flush  func(items []*batchItem)
+
+	mu         sync.Mutex
+	pending    []*batchItem
+	generation uint64
+	timer      *time.Timer
+}
+
+// add queues data for the next batch and blocks until the batch is signed.
+func (b *batcher) add(data []byte) *batchItem {
+	item := &batchItem{data: data, done: make(chan struct{})}
+
+	b.mu.Lock()
+	b.pending = append(b.pending, item)
+	switch {
+	case len(b.pending) >= maxBatchSize:
+		items := b.take()
+		b.mu.Unlock()
+		go b.flush(items)
+	case len(b.pending) == 1:
+		generation := b.generation
+		b.timer = time.AfterFunc(b.window, func() { b.expire(generation) })
+		b.mu.Unlock()
+	default:
+		b.mu.Unlock()
+	}
+
+	<-item.done
+	return item
+}
+
+// expire flushes the batch that started the timer, unless it was already
+// flushed because it became full.
+func (b *batcher) expire(generation uint64) {
+	b.mu.Lock()
+	if generation != b.generation {
+		b.mu.Unlock()
+		return
+	}
+	items := b.take()
+	b.mu.Unlock()
+
+	b.flush(items)
+}
+
+// take removes the pending batch. The caller must hold mu.
+func (b *batcher) take() []*batchItem {
+	items := b.pending
+	b.pending = nil
+	b.generation++
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	return items
+}
+
+// SignBatched adds the data to the device's current batch. When the batch
+// window closes the Merkle root of all items in the batch is signed as one
+// transaction of the device's signature chain, and every caller receives the
+// root signature together with the inclusion proof of its item.
+func (d *deviceService) SignBatched(input *validation.SignBatchedInput) (*validation.SignBatchedOutput, error) {
+	if err := input.IsValid(); err != nil {
+		return nil, err
+	}
+	leave, err := d.drain.enter()
+	if err != nil {
+		return nil, err
+	}
+	defer leave()
+
+	device, err := d.repo.GetSignatureDevice(input.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+	if device.BatchWindow <= 0 {
+		return nil, ErrBatchingDisabled
+	}
+
+	item := d.deviceBatcher(device).add(input.Data)
+	return item.output, item.err
+}
+
+// deviceBatcher returns the batcher of a device. A batcher created with
+// another batch window, e.g. before the device was restored, is replaced;
+// it still flushes the batch it holds.
+func (d *deviceService) deviceBatcher(device *entity.Device) *batcher {
+	for {
+		current, ok := d.batches.Load(device.ID)
+		if ok && current.(*batcher).window == device.BatchWindow {
+			return current.(*batcher)
+		}
+		b := &batcher{
+			window: device.BatchWindow,
+			flush: func(items []*batchItem) {
+				d.signBatch(device.ID, items)
+			},
+		}
+		if ok && d.batches.CompareAndSwap(device.ID, current, b) {
+			return b
+		}
+		if !ok {
+			if _, loaded := d.batches.LoadOrStore(device.ID, b); !loaded {
+				return b
+			}
+		}
+	}
+}
+
+// signBatch signs the Merkle root of the items and completes them.
+func (d *deviceService) signBatch(deviceID string, items []*batchItem) {
+	outputs, err := d.signMerkleRoot(deviceID, items)
+	for i, item := range items {
+		if err != nil {
+			item.err = err
+		} else {
+			item.output = outputs[i]
+		}
+		close(item.done)
+	}
+	if err != nil {
+		d.logger.Printf("signing batch of %d items for device %s failed: %v", len(items), deviceID, err)
+	}
+}
+
+func (d *deviceService) signMerkleRoot(deviceID string, items []*batchItem) ([]*validation.SignBatchedOutput, error) {
+	leaves := make([][]byte, len(items))
+	for i, item := range items {
+		leaves[i] = item.data
+	}
+	tree, err := crypto.NewMerkleTree(leaves)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock := d.lockDevice(deviceID)
+	defer unlock()
+
+	device, err := d.repo.GetSignatureDevice(deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := getSigner(device, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// The chain records the hex encoded root as transaction data.
+	transaction, err := d.newTransaction(device, []byte(hex.EncodeToString(tree.Root())), signer)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = d.repo.SignTransaction(transaction)
+	if err != nil {
+		return nil, err
+	}
+	d.publishTransaction(transaction)
+
+	signature := base64.StdEncoding.EncodeToString(transaction.Signature)
+	signedData := transaction.SecuredData()
+	outputs := make([]*validation.SignBatchedOutput, len(items))
+	for i := range items {
+		proof, err := tree.InclusionProof(i)
+		if err != nil {
+			return nil, err
+		}
+		outputs[i] = &validation.SignBatchedOutput{
+			TransactionID:  transaction.ID,
+			Signature:      signature,
+			SignedData:     signedData,
+			Root:           tree.Root(),
+			LeafIndex:      uint64(i),
+			TreeSize:       uint64(tree.Size()),
+			Proof:          proof,
+			TimestampToken: transaction.TimestampToken,
+		}
+	}
+	return outputs, nil
+}
+
+// VerifyInclusion checks that an item is included in a batch and that the
+// batch root was signed by the device.
+func (d *deviceService) VerifyInclusion(input *validation.VerifyInclusionInput) (*validation.VerifyInclusionOutput, error) {
+	if err := input.IsValid(); err != nil {
+		return nil, err
+	}
+
+	device, err := d.repo.GetSignatureDevice(input.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = crypto.VerifyMerkleInclusion(input.Data, input.LeafIndex, input.TreeSize, input.Proof, input.Root)
+	if errors.Is(err, crypto.ErrInvalidInclusionProof) {
+		return &validation.VerifyInclusionOutput{Valid: false}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The signed data is <signature_counter>_<root_hex>_<last_signature_base64>.
+	parts := strings.SplitN(input.SignedData, "_", 3)
+	if len(parts) != 3 || parts[1] != hex.EncodeToString(input.Root) {
+		return &validation.VerifyInclusionOutput{Valid: false}, nil
+	}
+
+	verifier, err := crypto.NewVerifier(device)
+	if err != nil {
+		return nil, err
+	}
+
+	err = verifier.Verify([]byte(input.SignedData), input.Signature)
+	if errors.Is(err, crypto.ErrInvalidSignature) {
+		return &validation.VerifyInclusionOutput{Valid: false}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &validation.VerifyInclusionOutput{Valid: true}, nil
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/validation"
+)
+
+func createBatchedDevice(t *testing.T, d *deviceService, id string, window time.Duration) {
+	t.Helper()
+	input := &validation.CreateSignatureDeviceInput{ID: id, Algorithm: "ECC", BatchWindowMS: int(window.Milliseconds())}
+	if _, err := d.CreateSignatureDevice(input); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// verifyInclusionInput returns the input that checks output for data.
+func verifyInclusionInput(t *testing.T, deviceID string, data []byte, output *validation.SignBatchedOutput) *validation.VerifyInclusionInput {
+	t.Helper()
+	signature, err := base64.StdEncoding.DecodeString(output.Signature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &validation.VerifyInclusionInput{
+		DeviceID:   deviceID,
+		Data:       data,
+		LeafIndex:  output.LeafIndex,
+		TreeSize:   output.TreeSize,
+		Proof:      output.Proof,
+		Root:       output.Root,
+		Signature:  signature,
+		SignedData: output.SignedData,
+	}
+}
+
+func TestSignBatchedSharesOneTransaction(t *testing.T) {
+	d, repo := newTestService(t)
+	createBatchedDevice(t, d, "device", 500*time.Millisecond)
+
+	// Seven items cover promoted nodes on two levels of the tree.
+	const n = 7
+	var (
+		wg      sync.WaitGroup
+		start   = make(chan struct{})
+		outputs = make([]*validation.SignBatchedOutput, n)
+		errs    = make([]error, n)
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			outputs[i], errs[i] = d.SignBatched(&validation.SignBatchedInput{DeviceID: "device", Data: []byte(fmt.Sprintf("item %d", i))})
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	indices := map[uint64]bool{}
+	for i, output := range outputs {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		if output.TransactionID != outputs[0].TransactionID || output.TreeSize != n {
+			t.Fatalf("item %d is in transaction %s of size %d, want one transaction of size %d", i, output.TransactionID, output.TreeSize, n)
+		}
+		indices[output.LeafIndex] = true
+
+		data := []byte(fmt.Sprintf("item %d", i))
+		verified, err := d.VerifyInclusion(verifyInclusionInput(t, "device", data, output))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !verified.Valid {
+			t.Errorf("item %d: inclusion proof is invalid", i)
+		}
+		verified, err = d.VerifyInclusion(verifyInclusionInput(t, "device", []byte("other"), output))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if verified.Valid {
+			t.Errorf("item %d: proof verified for other data", i)
+		}
+	}
+	if len(indices) != n {
+		t.Errorf("items got %d distinct leaf indices, want %d", len(indices), n)
+	}
+
+	device, err := repo.GetSignatureDevice("device")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if device.SignatureCounter != 1 {
+		t.Errorf("signature counter is %d, want 1", device.SignatureCounter)
+	}
+}
+
+func TestSignBatchedFollowsBatchWindow(t *testing.T) {
+	d, repo := newTestService(t)
+	createBatchedDevice(t, d, "device", 10*time.Millisecond)
+	sign := func() {
+		t.Helper()
+		if _, err := d.SignBatched(&validation.SignBatchedInput{DeviceID: "device", Data: []byte("data")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sign()
+
+	// Restoring a device may change its batch window.
+	unlock := d.lockDevice("device")
+	device, err := repo.GetSignatureDevice("device")
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed := *device
+	changed.BatchWindow = 30 * time.Millisecond
+	if _, err := repo.UpdateSignatureDevice(&changed); err != nil {
+		t.Fatal(err)
+	}
+	unlock()
+
+	sign()
+	b, ok := d.batches.Load("device")
+	if !ok || b.(*batcher).window != changed.BatchWindow {
+		t.Errorf("batcher does not use the new batch window of %s", changed.BatchWindow)
+	}
+}
@@ -1,13 +1,27 @@
 package service
 
 import (
+	"context"
+	gocrypto "crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"sync"
+	"time"
 
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/ca"
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/entity"
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/repository"
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/validation"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/events"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/export"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/timestamp"
 	"github.com/google/uuid"
 )
 
@@ -18,57 +32,172 @@ type DeviceService interface {
 	SignTransaction(input *validation.SignTransactionInput) (*validation.SignTransactionOutput, error)
 	ListTransaction(input *validation.ListTransactionInput) (*validation.ListTransactionOutput, error)
 	GetTransaction(input *validation.GetTransactionInput) (*validation.GetTransactionOutput, error)
+	MigrateLegacyKeys() (int, error)
+	GetDeviceCertificate(input *validation.GetDeviceCertificateInput) (*validation.GetDeviceCertificateOutput, error)
+	GetCACertificate() (*validation.GetCACertificateOutput, error)
+	CreateCertificateRequest(input *validation.CreateCertificateRequestInput) (*validation.CreateCertificateRequestOutput, error)
+	UploadDeviceCertificate(input *validation.UploadDeviceCertificateInput) (*validation.UploadDeviceCertificateOutput, error)
+	SignDocument(input *validation.SignDocumentInput) (*validation.SignDocumentOutput, error)
+	GetDeviceJWK(input *validation.GetDeviceJWKInput) (*validation.GetDeviceJWKOutput, error)
+	VerifySignature(input *validation.VerifySignatureInput) (*validation.VerifySignatureOutput, error)
+	SignBatched(input *validation.SignBatchedInput) (*validation.SignBatchedOutput, error)
+	VerifyInclusion(input *validation.VerifyInclusionInput) (*validation.VerifyInclusionOutput, error)
+	ExportDevice(input *validation.ExportDeviceInput) (*validation.ExportDeviceOutput, error)
+	Backup() (*validation.BackupOutput, error)
+	Restore(input *validation.RestoreInput) (*validation.RestoreOutput, error)
+	Drain(ctx context.Context) error
 }
 
 type deviceService struct {
-	repo   repository.Repository
-	logger *log.Logger
+	repo        repository.Repository
+	logger      *log.Logger
+	locks       sync.Map
+	batches     sync.Map
+	authority   *ca.Authority
+	trust       *ca.TrustStore
+	timestamper timestamp.Timestamper
+	// timestampTimeout bounds the request for a time-stamp token, which is
+	// made while the device is locked.
+	timestampTimeout time.Duration
+	events           *events.Bus
+	backupKey        []byte
+	algorithms       []string
+	rsaBits          int
+	drain            drainGate
 }
 
-func NewDeviceService(logger *log.Logger, repo repository.Repository) DeviceService {
-	return &deviceService{
+// Option configures optional dependencies of the device service.
+type Option func(*deviceService)
+
+// WithCertificateAuthority makes the service issue a certificate for the
+// public key of every newly created device.
+func WithCertificateAuthority(authority *ca.Authority) Option {
+	return func(d *deviceService) {
+		d.authority = authority
+	}
+}
+
+// WithTrustAnchors allows certificates issued by an external CA to be
+// uploaded for devices if they chain to one of the given trust anchors.
+func WithTrustAnchors(trust *ca.TrustStore) Option {
+	return func(d *deviceService) {
+		d.trust = trust
+	}
+}
+
+// WithTimestamper attaches an RFC 3161 time-stamp token over the signature
+// to every transaction. Signing fails if no token can be obtained within
+// timeout. The token covers the signature, which depends on the previous
+// one, so further transactions of the device wait for it.
+func WithTimestamper(timestamper timestamp.Timestamper, timeout time.Duration) Option {
+	return func(d *deviceService) {
+		d.timestamper = timestamper
+		d.timestampTimeout = timeout
+	}
+}
+
+// WithBackupKey enables encrypted backups of all devices and transactions
+// and their restore. The key must be backup.KeySize bytes long.
+func WithBackupKey(key []byte) Option {
+	return func(d *deviceService) {
+		d.backupKey = key
+	}
+}
+
+// WithKeyPolicy restricts new devices to the given algorithms and sets the
+// size of new RSA keys. All algorithms are allowed and RSA keys have
+// crypto.DefaultRSABits bits without it.
+func WithKeyPolicy(algorithms []string, rsaBits int) Option {
+	return func(d *deviceService) {
+		d.algorithms = algorithms
+		d.rsaBits = rsaBits
+	}
+}
+
+// WithEventBus publishes device and transaction events to the bus.
+func WithEventBus(bus *events.Bus) Option {
+	return func(d *deviceService) {
+		d.events = bus
+	}
+}
+
+func NewDeviceService(logger *log.Logger, repo repository.Repository, opts ...Option) DeviceService {
+	d := &deviceService{
 		logger: logger,
 		repo:   repo,
 	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
 }
 
 func (d *deviceService) CreateSignatureDevice(input *validation.CreateSignatureDeviceInput) (*validation.CreateSignatureDeviceOutput, error) {
 	if err := input.IsValid(); err != nil {
 		return nil, err
 	}
+	if !d.algorithmAllowed(input.Algorithm) {
+		return nil, fmt.Errorf("%s: %w", input.Algorithm, ErrAlgorithmNotAllowed)
+	}
 
 	device := &entity.Device{
-		ID:        input.ID,
-		Label:     input.Label,
-		Algorithm: input.Algorithm,
+		ID:            input.ID,
+		Label:         input.Label,
+		Algorithm:     input.Algorithm,
+		Deterministic: input.Deterministic,
+		BatchWindow:   time.Duration(input.BatchWindowMS) * time.Millisecond,
+	}
+	if input.Algorithm == "ECC" {
+		device.SignatureScheme = crypto.ECDSASchemeSHA384
+		device.SignatureFormat = crypto.ECDSAFormat(device, input.SignatureFormat)
+	}
+	if input.Algorithm == "RSA" {
+		device.SignatureScheme = input.SignatureScheme
+		if device.SignatureScheme == "" {
+			device.SignatureScheme = crypto.RSASchemePSS
+		}
 	}
 
 	var generator crypto.KeyPairGenerator
-	var marshaler crypto.KeyPairMarshaler
 
 	switch input.Algorithm {
 	case "ECC":
 		generator = &crypto.ECCGenerator{}
-		marshaler = crypto.NewECCMarshaler()
 	case "RSA":
-		generator = &crypto.RSAGenerator{}
-		marshaler = crypto.NewRSAMarshaler()
+		generator = &crypto.RSAGenerator{Bits: d.rsaBits}
+	}
+
+	marshaler, err := getMarshaler(input.Algorithm)
+	if err != nil {
+		return nil, err
 	}
 
 	keys, err := generator.Generate()
 	if err != nil {
 		return nil, err
 	}
-	_, device.PrivateKey, err = marshaler.Marshal(*keys)
+	device.PublicKey, device.PrivateKey, err = marshaler.Marshal(*keys)
 	if err != nil {
 		return nil, err
 	}
 
+	if d.authority != nil {
+		device.Certificate, err = d.authority.Issue(keys.Public, device.ID, device.Label)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	_, err = d.repo.CreateSignatureDevice(device)
 	if err != nil {
 		return nil, err
 	}
 
+	d.publish(events.EventDeviceCreated, device.ID, events.DeviceCreated{
+		Algorithm: device.Algorithm,
+		Label:     device.Label,
+	})
+
 	return &validation.CreateSignatureDeviceOutput{Status: "Device Created"}, nil
 }
 
@@ -77,7 +206,7 @@ func (d *deviceService) ListSignatureDevice(input *validation.ListSignatureDevic
 	if err != nil {
 		return nil, err
 	}
-	return &validation.ListSignatureDeviceOutput{Device: devices}, nil
+	return &validation.ListSignatureDeviceOutput{Device: validation.NewDevices(devices)}, nil
 }
 
 func (d *deviceService) GetSignatureDevice(input *validation.GetSignatureDeviceInput) (*validation.GetSignatureDeviceOutput, error) {
@@ -85,67 +214,190 @@ func (d *deviceService) GetSignatureDevice(input *validation.GetSignatureDeviceI
 	if err != nil {
 		return nil, err
 	}
-	return &validation.GetSignatureDeviceOutput{Device: device}, nil
+	return &validation.GetSignatureDeviceOutput{Device: validation.NewDevice(device)}, nil
 }
 
 func (d *deviceService) SignTransaction(input *validation.SignTransactionInput) (*validation.SignTransactionOutput, error) {
 	if err := input.IsValid(); err != nil {
 		return nil, err
 	}
+	leave, err := d.drain.enter()
+	if err != nil {
+		return nil, err
+	}
+	defer leave()
+
+	// Signing a transaction reads and advances the device's counter and last
+	// signature, so transactions of the same device must not interleave.
+	unlock := d.lockDevice(input.DeviceID)
+	defer unlock()
+
+	device, err := d.repo.GetSignatureDevice(input.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.SignatureFormat != "" && device.Algorithm != "ECC" {
+		return nil, errors.New("signature_format is only supported for ECC devices")
+	}
+
+	signer, err := getSigner(device, input.SignatureFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	transaction, err := d.newTransaction(device, input.Data, signer)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &validation.SignTransactionOutput{
+		Transaction:    base64.StdEncoding.EncodeToString(transaction.Signature),
+		SignedData:     transaction.SecuredData(),
+		TimestampToken: transaction.TimestampToken,
+	}
+
+	switch input.Output {
+	case validation.SignTransactionOutputJWS:
+		output.JWS, err = signJWS(device, transaction)
+	case validation.SignTransactionOutputCOSE:
+		output.COSE, err = signCOSE(device, transaction)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = d.repo.SignTransaction(transaction)
+	if err != nil {
+		return nil, err
+	}
+	d.publishTransaction(transaction)
+
+	return output, nil
+}
+
+// SignDocument signs uploaded content with a detached CMS signature. The
+// document digest is appended to the device's signature chain as the data
+// of a regular transaction, so document signatures consume the counter.
+func (d *deviceService) SignDocument(input *validation.SignDocumentInput) (*validation.SignDocumentOutput, error) {
+	if err := input.IsValid(); err != nil {
+		return nil, err
+	}
+	leave, err := d.drain.enter()
+	if err != nil {
+		return nil, err
+	}
+	defer leave()
 
 	device, err := d.repo.GetSignatureDevice(input.DeviceID)
 	if err != nil {
 		return nil, err
 	}
 
-	signer, err := getSigner(device)
+	// Hash the content before locking the device, uploads may be slow.
+	hash, err := crypto.CMSHash(device)
 	if err != nil {
 		return nil, err
 	}
+	digester := hash.New()
+	if _, err := io.Copy(digester, input.Content); err != nil {
+		return nil, err
+	}
+	digest := digester.Sum(nil)
+
+	unlock := d.lockDevice(input.DeviceID)
+	defer unlock()
 
-	signature, err := signer.Sign(input.Data)
+	device, err = d.repo.GetSignatureDevice(input.DeviceID)
 	if err != nil {
 		return nil, err
 	}
 
-	lastSignature, err := signer.Sign([]byte(input.DeviceID))
+	signer, err := getSigner(device, "")
 	if err != nil {
 		return nil, err
 	}
 
-	if device.SignatureCounter > 0 {
-		var max int
-		transactions, err := d.repo.ListTransactions(input.DeviceID)
+	transaction, err := d.newTransaction(device, []byte(hex.EncodeToString(digest)), signer)
+	if err != nil {
+		return nil, err
+	}
+
+	var certificate *x509.Certificate
+	if len(device.Certificate) > 0 {
+		certificate, err = ca.ParseCertificate(device.Certificate)
 		if err != nil {
 			return nil, err
 		}
-		for _, transaction := range transactions {
-			if transaction.SignatureCounter > max {
-				lastSignature = transaction.LastSignatureID
-			}
-		}
 	}
 
-	signatureEntity := &entity.Transaction{
-		ID:               uuid.New().String(),
-		DeviceID:         input.DeviceID,
-		SignatureCounter: device.SignatureCounter,
-		Data:             input.Data,
-		LastSignatureID:  lastSignature,
+	cms, err := crypto.SignDetachedCMS(device, digest, transaction.SignedAt, certificate)
+	if err != nil {
+		return nil, err
 	}
 
-	_, err = d.repo.SignTransaction(signatureEntity)
+	_, err = d.repo.SignTransaction(transaction)
 	if err != nil {
 		return nil, err
 	}
+	d.publishTransaction(transaction)
 
-	return &validation.SignTransactionOutput{
-		Transaction: string(signature),
-		SignedData:  fmt.Sprintf("%d_%s_%s", device.SignatureCounter, input.Data, lastSignature),
+	return &validation.SignDocumentOutput{
+		TransactionID:  transaction.ID,
+		Signature:      base64.StdEncoding.EncodeToString(transaction.Signature),
+		SignedData:     transaction.SecuredData(),
+		CMS:            cms,
+		TimestampToken: transaction.TimestampToken,
 	}, nil
 }
 
+// ExportDevice streams the archive of a device to input.Writer. The device
+// is exported as it was when the export started, transactions signed while
+// the archive is written are left out. The manifest is signed with the
+// device key.
+func (d *deviceService) ExportDevice(input *validation.ExportDeviceInput) (*validation.ExportDeviceOutput, error) {
+	if err := input.IsValid(); err != nil {
+		return nil, err
+	}
+
+	if _, err := d.repo.GetSignatureDevice(input.ID); err != nil {
+		return nil, err
+	}
+
+	// Copy the device while no transaction is being signed, so counter and
+	// last signature are consistent.
+	unlock := d.lockDevice(input.ID)
+	device, err := d.repo.GetSignatureDevice(input.ID)
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+	snapshot := *device
+	unlock()
+
+	signer, err := getSigner(&snapshot, "")
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := export.WriteArchive(input.Writer, &snapshot, func(counter int) (*entity.Transaction, error) {
+		return d.repo.GetTransactionByCounter(snapshot.ID, counter)
+	}, signer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &validation.ExportDeviceOutput{Manifest: manifest}, nil
+}
+
 func (d *deviceService) ListTransaction(input *validation.ListTransactionInput) (*validation.ListTransactionOutput, error) {
+	if input.Limit > 0 {
+		transactions, err := d.repo.ListTransactionsByCounter(input.DeviceID, input.Counter, input.Limit)
+		if err != nil {
+			return nil, err
+		}
+		return &validation.ListTransactionOutput{Transaction: transactions}, nil
+	}
 	transactions, err := d.repo.ListTransactions(input.DeviceID)
 	if err != nil {
 		return nil, err
@@ -161,10 +413,327 @@ func (d *deviceService) GetTransaction(input *validation.GetTransactionInput) (*
 	return &validation.GetTransactionOutput{Transaction: transaction}, nil
 }
 
-func getSigner(device *entity.Device) (crypto.Signer, error) {
+// GetDeviceJWK returns the public key of a device as a JWK.
+func (d *deviceService) GetDeviceJWK(input *validation.GetDeviceJWKInput) (*validation.GetDeviceJWKOutput, error) {
+	device, err := d.repo.GetSignatureDevice(input.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	jwk, err := crypto.NewDeviceJWK(device)
+	if err != nil {
+		return nil, err
+	}
+	return &validation.GetDeviceJWKOutput{JWK: jwk}, nil
+}
+
+// VerifySignature checks a signature over data against the device's public
+// key and signature scheme.
+func (d *deviceService) VerifySignature(input *validation.VerifySignatureInput) (*validation.VerifySignatureOutput, error) {
+	if err := input.IsValid(); err != nil {
+		return nil, err
+	}
+
+	device, err := d.repo.GetSignatureDevice(input.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	verifier, err := crypto.NewVerifier(device)
+	if err != nil {
+		return nil, err
+	}
+
+	err = verifier.Verify(input.Data, input.Signature)
+	if errors.Is(err, crypto.ErrInvalidSignature) {
+		return &validation.VerifySignatureOutput{Valid: false}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &validation.VerifySignatureOutput{Valid: true}, nil
+}
+
+// GetDeviceCertificate returns the certificate chain of a device.
+func (d *deviceService) GetDeviceCertificate(input *validation.GetDeviceCertificateInput) (*validation.GetDeviceCertificateOutput, error) {
+	device, err := d.repo.GetSignatureDevice(input.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(device.Certificate) == 0 {
+		return nil, ErrCertificateNotFound
+	}
+	return &validation.GetDeviceCertificateOutput{Certificate: device.Certificate}, nil
+}
+
+// CreateCertificateRequest creates a PKCS #10 request signed with the device
+// key, to be submitted to an external CA.
+func (d *deviceService) CreateCertificateRequest(input *validation.CreateCertificateRequestInput) (*validation.CreateCertificateRequestOutput, error) {
+	device, err := d.repo.GetSignatureDevice(input.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	keyPair, err := crypto.UnmarshalPrivateKey(device.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := keyPair.Private.(gocrypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T", keyPair.Private)
+	}
+
+	request, err := ca.CreateRequest(key, device.ID, device.Label)
+	if err != nil {
+		return nil, err
+	}
+	return &validation.CreateCertificateRequestOutput{CertificateRequest: request}, nil
+}
+
+// UploadDeviceCertificate stores a certificate issued by an external CA after
+// checking that it certifies the device key and chains to a trust anchor.
+func (d *deviceService) UploadDeviceCertificate(input *validation.UploadDeviceCertificateInput) (*validation.UploadDeviceCertificateOutput, error) {
+	if err := input.IsValid(); err != nil {
+		return nil, err
+	}
+	if d.trust == nil {
+		return nil, ErrNoTrustAnchors
+	}
+
+	// Hold the device while its copy is written back, so a transaction
+	// signed meanwhile cannot have its counter rolled back.
+	unlock := d.lockDevice(input.ID)
+	defer unlock()
+
+	device, err := d.repo.GetSignatureDevice(input.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, err := crypto.UnmarshalPublicKey(device.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	chain, err := d.trust.VerifyDeviceCertificate(input.Certificate, publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCertificate, err)
+	}
+
+	updated := *device
+	updated.Certificate = chain
+	if _, err := d.repo.UpdateSignatureDevice(&updated); err != nil {
+		return nil, err
+	}
+
+	return &validation.UploadDeviceCertificateOutput{Status: "Certificate Stored"}, nil
+}
+
+// GetCACertificate returns the certificate of the built-in CA.
+func (d *deviceService) GetCACertificate() (*validation.GetCACertificateOutput, error) {
+	if d.authority == nil {
+		return nil, ErrNoCertificateAuthority
+	}
+	return &validation.GetCACertificateOutput{Certificate: d.authority.Certificate()}, nil
+}
+
+// MigrateLegacyKeys re-encodes every stored device key that still uses the
+// legacy PEM labels as PKCS #8 / SubjectPublicKeyInfo. It returns the number
+// of migrated devices.
+func (d *deviceService) MigrateLegacyKeys() (int, error) {
+	devices, err := d.repo.ListSignatureDevices("", "", "")
+	if err != nil {
+		return 0, err
+	}
+
+	var migrated int
+	for _, device := range devices {
+		ok, err := d.migrateLegacyKey(device.ID)
+		if err != nil {
+			return migrated, err
+		}
+		if ok {
+			migrated++
+		}
+	}
+
+	return migrated, nil
+}
+
+// migrateLegacyKey re-encodes the key of one device if it is legacy encoded.
+// The device is locked and read again, so transactions signed while the
+// migration runs are not rolled back by writing back a stale copy.
+func (d *deviceService) migrateLegacyKey(id string) (bool, error) {
+	unlock := d.lockDevice(id)
+	defer unlock()
+
+	device, err := d.repo.GetSignatureDevice(id)
+	if err != nil {
+		return false, err
+	}
+	if !crypto.IsLegacyEncoded(device.PrivateKey) {
+		return false, nil
+	}
+
+	marshaler, err := getMarshaler(device.Algorithm)
+	if err != nil {
+		return false, err
+	}
+
+	updated := *device
+	updated.PublicKey, updated.PrivateKey, err = crypto.Reencode(marshaler, device.PrivateKey)
+	if err != nil {
+		return false, fmt.Errorf("device %s: %w", device.ID, err)
+	}
+
+	if _, err := d.repo.UpdateSignatureDevice(&updated); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// algorithmAllowed reports whether the key policy allows new devices with
+// the given algorithm.
+func (d *deviceService) algorithmAllowed(algorithm string) bool {
+	if d.algorithms == nil {
+		return true
+	}
+	for _, allowed := range d.algorithms {
+		if allowed == algorithm {
+			return true
+		}
+	}
+	return false
+}
+
+// getMarshaler returns the marshaler used to store keys of the given algorithm.
+func getMarshaler(algorithm string) (crypto.KeyPairMarshaler, error) {
+	switch algorithm {
+	case "ECC":
+		return crypto.NewECCMarshalerWithEncoding(crypto.StandardEncoding), nil
+	case "RSA":
+		return crypto.NewRSAMarshalerWithEncoding(crypto.StandardEncoding), nil
+	default:
+		return nil, fmt.Errorf("unsupported algorithm: %s", algorithm)
+	}
+}
+
+// newTransaction builds the next transaction of the device's signature chain
+// and signs its secured data. If a timestamper is configured the signature
+// is time-stamped as well. The caller must hold the device lock and store
+// the transaction with the repository.
+func (d *deviceService) newTransaction(device *entity.Device, data []byte, signer crypto.Signer) (*entity.Transaction, error) {
+	// The first transaction of a device is chained to the device ID.
+	lastSignature := device.LastSignature
+	if device.SignatureCounter == 0 {
+		lastSignature = []byte(device.ID)
+	}
+
+	transaction := &entity.Transaction{
+		ID:               uuid.New().String(),
+		DeviceID:         device.ID,
+		SignatureCounter: device.SignatureCounter,
+		Data:             data,
+		LastSignatureID:  lastSignature,
+		SignedAt:         time.Now().UTC(),
+	}
+
+	signature, err := signer.Sign([]byte(transaction.SecuredData()))
+	if err != nil {
+		return nil, err
+	}
+	transaction.Signature = signature
+
+	if d.timestamper != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), d.timestampTimeout)
+		defer cancel()
+		digest := sha256.Sum256(signature)
+		token, err := d.timestamper.Timestamp(ctx, digest[:], gocrypto.SHA256)
+		if err != nil {
+			return nil, fmt.Errorf("timestamp signature: %w", err)
+		}
+		transaction.TimestampToken = token.Raw
+		transaction.SignedAt = token.Time.UTC()
+	}
+
+	return transaction, nil
+}
+
+// publish hands an event to the event bus, if one is configured.
+func (d *deviceService) publish(eventType, deviceID string, data any) {
+	if d.events != nil {
+		d.events.Publish(eventType, deviceID, data)
+	}
+}
+
+func (d *deviceService) publishTransaction(transaction *entity.Transaction) {
+	d.publish(events.EventTransactionSigned, transaction.DeviceID, events.TransactionSigned{
+		TransactionID:    transaction.ID,
+		SignatureCounter: transaction.SignatureCounter,
+		Signature:        transaction.Signature,
+	})
+}
+
+// lockDevice serializes signing per device and returns the unlock function.
+func (d *deviceService) lockDevice(id string) func() {
+	lock, _ := d.locks.LoadOrStore(id, &sync.Mutex{})
+	mu := lock.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// signJWS returns a transaction as JWS compact serialization whose payload
+// is the secured data and whose header binds it to the device and counter.
+func signJWS(device *entity.Device, transaction *entity.Transaction) (string, error) {
+	alg, err := crypto.JWSAlgorithm(device)
+	if err != nil {
+		return "", err
+	}
+
+	// JWS requires r||s encoded ECDSA signatures.
+	signer, err := getSigner(device, crypto.ECDSAFormatPlain)
+	if err != nil {
+		return "", err
+	}
+
+	header := crypto.JWSHeader{
+		Algorithm: alg,
+		KeyID:     device.ID,
+		DeviceID:  device.ID,
+		Counter:   transaction.SignatureCounter,
+	}
+	return crypto.SignJWS(signer, header, []byte(transaction.SecuredData()))
+}
+
+// signCOSE returns a transaction as COSE_Sign1 whose payload is the secured
+// data and whose protected header binds it to the device and counter.
+func signCOSE(device *entity.Device, transaction *entity.Transaction) ([]byte, error) {
+	alg, err := crypto.COSEAlgorithm(device)
+	if err != nil {
+		return nil, err
+	}
+
+	// COSE requires r||s encoded ECDSA signatures.
+	signer, err := getSigner(device, crypto.ECDSAFormatPlain)
+	if err != nil {
+		return nil, err
+	}
+
+	header := crypto.COSEHeader{
+		Algorithm: alg,
+		KeyID:     []byte(device.ID),
+		DeviceID:  device.ID,
+		Counter:   transaction.SignatureCounter,
+	}
+	return crypto.SignCOSE(signer, header, []byte(transaction.SecuredData()))
+}
+
+// getSigner returns the signer for a device. The format overrides the
+// signature encoding of ECC devices if set.
+func getSigner(device *entity.Device, format string) (crypto.Signer, error) {
 	switch device.Algorithm {
 	case "ECC":
-		return &crypto.ECCSigner{Device: device}, nil
+		return &crypto.ECCSigner{Device: device, Format: format}, nil
 	case "RSA":
 		return &crypto.RSASigner{Device: device}, nil
 	default:
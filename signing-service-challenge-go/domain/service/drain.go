@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"sync"
+)
+
+// drainGate tracks the operations that advance signature counters, so that
+// shutdown can wait for them instead of cutting a signature chain short.
+type drainGate struct {
+	mu       sync.Mutex
+	draining bool
+	running  sync.WaitGroup
+}
+
+// enter registers a signing operation. It fails with ErrShuttingDown once
+// draining has started; otherwise the caller must call the returned func
+// when the operation is complete.
+func (g *drainGate) enter() (func(), error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.draining {
+		return nil, ErrShuttingDown
+	}
+	g.running.Add(1)
+	return g.running.Done, nil
+}
+
+// Drain refuses new signing operations with ErrShuttingDown and waits until
+// the running ones, including pending batches, have stored their
+// transactions, or until ctx is done.
+func (d *deviceService) Drain(ctx context.Context) error {
+	d.drain.mu.Lock()
+	d.drain.draining = true
+	d.drain.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.drain.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,24 @@
+package service
+
+import "errors"
+
+var (
+	// ErrCertificateNotFound is returned when a device has no certificate.
+	ErrCertificateNotFound = errors.New("certificate not found")
+	// ErrNoCertificateAuthority is returned when no built-in CA is configured.
+	ErrNoCertificateAuthority = errors.New("no certificate authority configured")
+	// ErrNoTrustAnchors is returned when certificates are uploaded but no trust anchors are configured.
+	ErrNoTrustAnchors = errors.New("no trust anchors configured")
+	// ErrInvalidCertificate is returned when an uploaded certificate fails validation.
+	ErrInvalidCertificate = errors.New("invalid certificate")
+	// ErrBatchingDisabled is returned when batched signing is requested for a device without a batch window.
+	ErrBatchingDisabled = errors.New("batched signing is not enabled for this device")
+	// ErrNoBackupKey is returned when backups are requested but no backup key is configured.
+	ErrNoBackupKey = errors.New("no backup key configured")
+	// ErrAlgorithmNotAllowed is returned when a device is created with an algorithm the key policy does not allow.
+	ErrAlgorithmNotAllowed = errors.New("algorithm not allowed")
+	// ErrShuttingDown is returned for signing requests once the service drains for shutdown.
+	ErrShuttingDown = errors.New("service is shutting down")
+	// ErrStoreNotEmpty is returned when a backup is restored into a store that already holds devices or transactions.
+	ErrStoreNotEmpty = errors.New("store is not empty")
+)
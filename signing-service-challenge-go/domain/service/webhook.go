@@ -0,0 +1,475 @@
+package service
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/entity"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/repository"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/validation"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/events"
+	"github.com/google/uuid"
+)
+
+// Headers of webhook deliveries.
+const (
+	WebhookIDHeader        = "Webhook-Id"
+	WebhookDeliveryHeader  = "Webhook-Delivery"
+	WebhookEventHeader     = "Webhook-Event"
+	WebhookSignatureHeader = "Webhook-Signature"
+)
+
+// Delivery schedule: a failed attempt is retried after webhookInitialBackoff,
+// doubling up to webhookMaxBackoff, until webhookMaxAttempts are used up.
+const (
+	webhookInitialBackoff = 5 * time.Second
+	webhookMaxBackoff     = time.Hour
+	webhookMaxAttempts    = 10
+	webhookTimeout        = 10 * time.Second
+	webhookPollInterval   = time.Second
+	webhookConcurrency    = 8
+	// webhookMaxErrorBody bounds how much of an error response is logged.
+	webhookMaxErrorBody = 512
+)
+
+var (
+	// ErrDeliveryNotFailed is returned when a delivery that has not failed is retried.
+	ErrDeliveryNotFailed = errors.New("only failed deliveries can be retried")
+	// ErrPrivateWebhookTarget is returned when a webhook targets a loopback, link-local or private address while such targets are not allowed.
+	ErrPrivateWebhookTarget = errors.New("webhook URL targets a private address")
+)
+
+// WebhookService registers webhooks and delivers events to them. Pending
+// deliveries are kept in the repository and are only as durable as it is;
+// with the in-memory storage, queued deliveries and their retries are lost
+// when the service restarts.
+type WebhookService interface {
+	CreateWebhook(input *validation.CreateWebhookInput) (*validation.CreateWebhookOutput, error)
+	ListWebhook() (*validation.ListWebhookOutput, error)
+	GetWebhook(input *validation.GetWebhookInput) (*validation.GetWebhookOutput, error)
+	DeleteWebhook(input *validation.DeleteWebhookInput) (*validation.DeleteWebhookOutput, error)
+	ListWebhookDelivery(input *validation.ListWebhookDeliveryInput) (*validation.ListWebhookDeliveryOutput, error)
+	RetryWebhookDelivery(input *validation.RetryWebhookDeliveryInput) (*validation.RetryWebhookDeliveryOutput, error)
+	// Run queues a delivery for every event of the bus that matches a
+	// webhook and delivers queued deliveries until stop is closed.
+	Run(stop <-chan struct{})
+}
+
+type webhookService struct {
+	repo           repository.Repository
+	logger         *log.Logger
+	bus            *events.Bus
+	client         *http.Client
+	privateTargets bool
+	wake           chan struct{}
+	inFlight       sync.Map
+	slots          chan struct{}
+}
+
+// WebhookOption configures the webhook service.
+type WebhookOption func(*webhookService)
+
+// WithWebhookPrivateTargets allows webhooks to target loopback, link-local
+// and private addresses. By default they are refused, so that webhooks
+// cannot be used to reach services on the internal network of the signing
+// service.
+func WithWebhookPrivateTargets() WebhookOption {
+	return func(w *webhookService) {
+		w.privateTargets = true
+	}
+}
+
+func NewWebhookService(logger *log.Logger, repo repository.Repository, bus *events.Bus, opts ...WebhookOption) WebhookService {
+	w := &webhookService{
+		repo:   repo,
+		logger: logger,
+		bus:    bus,
+		wake:   make(chan struct{}, 1),
+		slots:  make(chan struct{}, webhookConcurrency),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	w.client = &http.Client{Timeout: webhookTimeout}
+	if !w.privateTargets {
+		w.client.Transport = publicTransport()
+	}
+	return w
+}
+
+// publicTransport returns a transport that refuses to connect to private
+// addresses. The address is checked after name resolution, so host names
+// that resolve to private addresses are refused as well. Proxies are not
+// used, since they would hide the address of the receiver.
+func publicTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || privateIP(ip) {
+				return fmt.Errorf("%w: %s", ErrPrivateWebhookTarget, host)
+			}
+			return nil
+		},
+	}
+	transport.DialContext = dialer.DialContext
+	return transport
+}
+
+// privateIP reports whether ip is a loopback, link-local, private or
+// unspecified address.
+func privateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast()
+}
+
+// checkTarget refuses webhook URLs whose host is a private address or
+// localhost, unless private targets are allowed. Host names are checked
+// again when they are resolved for a delivery.
+func (w *webhookService) checkTarget(rawURL string) error {
+	if w.privateTargets {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrPrivateWebhookTarget
+	}
+	if ip := net.ParseIP(host); ip != nil && privateIP(ip) {
+		return ErrPrivateWebhookTarget
+	}
+	return nil
+}
+
+// CreateWebhook registers a webhook and generates the secret that signs
+// its deliveries.
+func (w *webhookService) CreateWebhook(input *validation.CreateWebhookInput) (*validation.CreateWebhookOutput, error) {
+	if err := input.IsValid(); err != nil {
+		return nil, err
+	}
+	if err := w.checkTarget(input.URL); err != nil {
+		return nil, err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	webhook := &entity.Webhook{
+		ID:         uuid.New().String(),
+		URL:        input.URL,
+		Secret:     base64.RawURLEncoding.EncodeToString(secret),
+		EventTypes: input.EventTypes,
+		DeviceIDs:  input.DeviceIDs,
+		CreatedAt:  time.Now().UTC(),
+	}
+
+	_, err := w.repo.CreateWebhook(webhook)
+	if err != nil {
+		return nil, err
+	}
+
+	return &validation.CreateWebhookOutput{Webhook: webhook, Secret: webhook.Secret}, nil
+}
+
+func (w *webhookService) ListWebhook() (*validation.ListWebhookOutput, error) {
+	webhooks, err := w.repo.ListWebhooks()
+	if err != nil {
+		return nil, err
+	}
+	return &validation.ListWebhookOutput{Webhook: webhooks}, nil
+}
+
+func (w *webhookService) GetWebhook(input *validation.GetWebhookInput) (*validation.GetWebhookOutput, error) {
+	webhook, err := w.repo.GetWebhook(input.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &validation.GetWebhookOutput{Webhook: webhook}, nil
+}
+
+// DeleteWebhook removes a webhook; its queued deliveries are dropped.
+func (w *webhookService) DeleteWebhook(input *validation.DeleteWebhookInput) (*validation.DeleteWebhookOutput, error) {
+	if err := w.repo.DeleteWebhook(input.ID); err != nil {
+		return nil, err
+	}
+	return &validation.DeleteWebhookOutput{Status: "Webhook Deleted"}, nil
+}
+
+// ListWebhookDelivery returns the delivery log of a webhook, optionally
+// restricted to deliveries in the given status.
+func (w *webhookService) ListWebhookDelivery(input *validation.ListWebhookDeliveryInput) (*validation.ListWebhookDeliveryOutput, error) {
+	if err := input.IsValid(); err != nil {
+		return nil, err
+	}
+	if _, err := w.repo.GetWebhook(input.WebhookID); err != nil {
+		return nil, err
+	}
+
+	deliveries, err := w.repo.ListWebhookDeliveries(input.WebhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := make([]*entity.WebhookDelivery, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		if input.Status == "" || delivery.Status == input.Status {
+			filtered = append(filtered, delivery)
+		}
+	}
+	return &validation.ListWebhookDeliveryOutput{Delivery: filtered}, nil
+}
+
+// RetryWebhookDelivery queues a failed delivery again with a fresh set of
+// attempts.
+func (w *webhookService) RetryWebhookDelivery(input *validation.RetryWebhookDeliveryInput) (*validation.RetryWebhookDeliveryOutput, error) {
+	delivery, err := w.repo.GetWebhookDelivery(input.DeliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.WebhookID != input.WebhookID {
+		return nil, repository.ErrDeliveryNotFound
+	}
+	if delivery.Status != entity.DeliveryFailed {
+		return nil, ErrDeliveryNotFailed
+	}
+
+	retry := *delivery
+	retry.Status = entity.DeliveryPending
+	retry.Attempts = 0
+	retry.NextAttemptAt = time.Now().UTC()
+	updated, err := w.repo.UpdateWebhookDelivery(&retry)
+	if err != nil {
+		return nil, err
+	}
+	w.notify()
+
+	return &validation.RetryWebhookDeliveryOutput{Delivery: updated}, nil
+}
+
+func (w *webhookService) Run(stop <-chan struct{}) {
+	go w.consume(stop)
+
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		case <-w.wake:
+		}
+		w.dispatch()
+	}
+}
+
+// consume queues deliveries for the events of the bus. If the subscription
+// is dropped because queueing fell behind, it resumes from the bus' replay
+// buffer.
+func (w *webhookService) consume(stop <-chan struct{}) {
+	var lastID uint64
+	for {
+		subscription, replay, complete := w.bus.Subscribe(events.Filter{}, lastID, lastID > 0)
+		if !complete {
+			w.logger.Printf("webhooks: events after %d were evicted before they could be queued", lastID)
+		}
+		for _, event := range replay {
+			w.enqueue(event)
+			lastID = event.ID
+		}
+
+	receive:
+		for {
+			select {
+			case <-stop:
+				subscription.Close()
+				return
+			case event, ok := <-subscription.Events():
+				if !ok {
+					break receive
+				}
+				w.enqueue(event)
+				lastID = event.ID
+			}
+		}
+	}
+}
+
+// enqueue stores a pending delivery of the event for every matching webhook.
+func (w *webhookService) enqueue(event events.Event) {
+	webhooks, err := w.repo.ListWebhooks()
+	if err != nil {
+		w.logger.Printf("webhooks: listing webhooks failed: %v", err)
+		return
+	}
+
+	var payload []byte
+	for _, webhook := range webhooks {
+		filter := events.Filter{DeviceIDs: webhook.DeviceIDs, Types: webhook.EventTypes}
+		if !filter.Matches(event) {
+			continue
+		}
+
+		if payload == nil {
+			if payload, err = json.Marshal(event); err != nil {
+				w.logger.Printf("webhooks: encoding event %d failed: %v", event.ID, err)
+				return
+			}
+		}
+
+		now := time.Now().UTC()
+		_, err := w.repo.CreateWebhookDelivery(&entity.WebhookDelivery{
+			ID:            uuid.New().String(),
+			WebhookID:     webhook.ID,
+			EventID:       event.ID,
+			EventType:     event.Type,
+			Payload:       payload,
+			Status:        entity.DeliveryPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		})
+		if err != nil {
+			w.logger.Printf("webhooks: queueing event %d for webhook %s failed: %v", event.ID, webhook.ID, err)
+		}
+	}
+	w.notify()
+}
+
+// notify wakes the dispatcher without waiting for the next poll.
+func (w *webhookService) notify() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// dispatch starts an attempt for every due delivery that is not already
+// being delivered, with at most webhookConcurrency attempts at a time.
+func (w *webhookService) dispatch() {
+	deliveries, err := w.repo.DueWebhookDeliveries(time.Now().UTC())
+	if err != nil {
+		w.logger.Printf("webhooks: listing due deliveries failed: %v", err)
+		return
+	}
+
+	for _, delivery := range deliveries {
+		if _, busy := w.inFlight.LoadOrStore(delivery.ID, struct{}{}); busy {
+			continue
+		}
+		w.slots <- struct{}{}
+		go func(delivery *entity.WebhookDelivery) {
+			defer func() {
+				<-w.slots
+				w.inFlight.Delete(delivery.ID)
+			}()
+			w.attempt(delivery)
+		}(delivery)
+	}
+}
+
+// attempt delivers the payload once and records the outcome, scheduling
+// the next attempt with exponential backoff if it failed.
+func (w *webhookService) attempt(delivery *entity.WebhookDelivery) {
+	// The due list may predate an attempt that finished in the meantime.
+	delivery, err := w.repo.GetWebhookDelivery(delivery.ID)
+	if err != nil {
+		// The webhook was deleted together with its deliveries.
+		return
+	}
+	now := time.Now().UTC()
+	if delivery.Status != entity.DeliveryPending || delivery.NextAttemptAt.After(now) {
+		return
+	}
+
+	webhook, err := w.repo.GetWebhook(delivery.WebhookID)
+	if err != nil {
+		return
+	}
+
+	updated := *delivery
+	updated.Attempts++
+	updated.LastAttemptAt = now
+
+	status, err := w.post(webhook, delivery, now)
+	updated.ResponseStatus = status
+	switch {
+	case err == nil:
+		updated.Status = entity.DeliverySucceeded
+		updated.LastError = ""
+	case updated.Attempts >= webhookMaxAttempts:
+		updated.Status = entity.DeliveryFailed
+		updated.LastError = err.Error()
+	default:
+		updated.LastError = err.Error()
+		updated.NextAttemptAt = now.Add(webhookBackoff(updated.Attempts))
+	}
+
+	if _, err := w.repo.UpdateWebhookDelivery(&updated); err != nil {
+		w.logger.Printf("webhooks: recording delivery %s failed: %v", delivery.ID, err)
+	}
+}
+
+// post sends the signed payload and returns the response status. Any
+// status other than 2xx is an error.
+func (w *webhookService) post(webhook *entity.Webhook, delivery *entity.WebhookDelivery, now time.Time) (int, error) {
+	request, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(WebhookIDHeader, webhook.ID)
+	request.Header.Set(WebhookDeliveryHeader, delivery.ID)
+	request.Header.Set(WebhookEventHeader, delivery.EventType)
+	request.Header.Set(WebhookSignatureHeader, crypto.SignWebhookPayload(webhook.Secret, now, delivery.Payload))
+
+	response, err := w.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, webhookMaxErrorBody))
+		return response.StatusCode, fmt.Errorf("receiver responded with %s: %s", response.Status, bytes.TrimSpace(body))
+	}
+	io.Copy(io.Discard, response.Body)
+	return response.StatusCode, nil
+}
+
+// webhookBackoff returns the delay before the attempt following the given
+// number of failed attempts.
+func webhookBackoff(attempts int) time.Duration {
+	backoff := webhookInitialBackoff
+	for i := 1; i < attempts && backoff < webhookMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > webhookMaxBackoff {
+		backoff = webhookMaxBackoff
+	}
+	return backoff
+}
@@ -0,0 +1,283 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/entity"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/repository"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/validation"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/events"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+)
+
+func newTestWebhookService(t *testing.T, opts ...WebhookOption) (*webhookService, repository.Repository) {
+	t.Helper()
+	repo := repository.NewRepository(persistence.NewDatabase())
+	return NewWebhookService(log.New(io.Discard, "", 0), repo, events.NewBus(16), opts...).(*webhookService), repo
+}
+
+// receiver is a webhook receiver that counts its requests and answers with
+// the status returned by its handler.
+type receiver struct {
+	*httptest.Server
+	requests atomic.Int32
+}
+
+func newReceiver(t *testing.T, handle func(r *http.Request, body []byte) int) *receiver {
+	t.Helper()
+	rec := &receiver{}
+	rec.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec.requests.Add(1)
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(handle(r, body))
+	}))
+	t.Cleanup(rec.Close)
+	return rec
+}
+
+// queueDelivery registers a webhook for url and queues one delivery for it.
+func queueDelivery(t *testing.T, w *webhookService, repo repository.Repository, url string) (*validation.CreateWebhookOutput, *entity.WebhookDelivery) {
+	t.Helper()
+	webhook, err := w.CreateWebhook(&validation.CreateWebhookInput{URL: url})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.enqueue(events.Event{ID: 1, Type: events.EventDeviceCreated, DeviceID: "device"})
+	deliveries, err := repo.ListWebhookDeliveries(webhook.Webhook.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 {
+		t.Fatalf("got %d deliveries, want 1", len(deliveries))
+	}
+	return webhook, deliveries[0]
+}
+
+// attemptNow makes the delivery due and attempts it.
+func attemptNow(t *testing.T, w *webhookService, repo repository.Repository, id string) *entity.WebhookDelivery {
+	t.Helper()
+	delivery, err := repo.GetWebhookDelivery(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	due := *delivery
+	due.NextAttemptAt = time.Now().UTC()
+	if _, err := repo.UpdateWebhookDelivery(&due); err != nil {
+		t.Fatal(err)
+	}
+	w.attempt(&due)
+	if delivery, err = repo.GetWebhookDelivery(id); err != nil {
+		t.Fatal(err)
+	}
+	return delivery
+}
+
+func TestWebhookDeliveryIsSigned(t *testing.T) {
+	w, repo := newTestWebhookService(t, WithWebhookPrivateTargets())
+	var secret string
+	received := make(chan *http.Request, 1)
+	rec := newReceiver(t, func(r *http.Request, body []byte) int {
+		if err := crypto.VerifyWebhookSignature(secret, r.Header.Get(WebhookSignatureHeader), body, time.Minute); err != nil {
+			t.Errorf("signature: %v", err)
+		}
+		received <- r
+		return http.StatusNoContent
+	})
+
+	webhook, err := w.CreateWebhook(&validation.CreateWebhookInput{URL: rec.URL, EventTypes: []string{events.EventTransactionSigned}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret = webhook.Secret
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go w.Run(stop)
+	// The first event does not match the webhook's event types.
+	w.enqueue(w.bus.Publish(events.EventDeviceCreated, "device", nil))
+	event := w.bus.Publish(events.EventTransactionSigned, "device", map[string]int{"signature_counter": 1})
+	w.enqueue(event)
+
+	var r *http.Request
+	select {
+	case r = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no delivery received")
+	}
+	if got := r.Header.Get(WebhookIDHeader); got != webhook.Webhook.ID {
+		t.Errorf("%s is %q, want %q", WebhookIDHeader, got, webhook.Webhook.ID)
+	}
+	if got := r.Header.Get(WebhookEventHeader); got != events.EventTransactionSigned {
+		t.Errorf("%s is %q, want %q", WebhookEventHeader, got, events.EventTransactionSigned)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		deliveries, err := repo.ListWebhookDeliveries(webhook.Webhook.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(deliveries) != 1 || deliveries[0].EventID != event.ID {
+			t.Fatalf("got %d deliveries, want one for event %d", len(deliveries), event.ID)
+		}
+		if deliveries[0].Status == entity.DeliverySucceeded {
+			if got := r.Header.Get(WebhookDeliveryHeader); got != deliveries[0].ID {
+				t.Errorf("%s is %q, want %q", WebhookDeliveryHeader, got, deliveries[0].ID)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("delivery is %s, want %s", deliveries[0].Status, entity.DeliverySucceeded)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWebhookDeliveryRetries(t *testing.T) {
+	w, repo := newTestWebhookService(t, WithWebhookPrivateTargets())
+	statuses := []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK}
+	rec := newReceiver(t, func(*http.Request, []byte) int {
+		status := statuses[0]
+		if len(statuses) > 1 {
+			statuses = statuses[1:]
+		}
+		return status
+	})
+	_, delivery := queueDelivery(t, w, repo, rec.URL)
+
+	delivery = attemptNow(t, w, repo, delivery.ID)
+	if delivery.Status != entity.DeliveryPending || delivery.Attempts != 1 || delivery.ResponseStatus != http.StatusInternalServerError || delivery.LastError == "" {
+		t.Fatalf("after a failed attempt: %+v", delivery)
+	}
+	if got := delivery.NextAttemptAt.Sub(delivery.LastAttemptAt); got != webhookInitialBackoff {
+		t.Errorf("next attempt after %s, want %s", got, webhookInitialBackoff)
+	}
+
+	// The delivery is not attempted again before it is due.
+	w.attempt(delivery)
+	if got := rec.requests.Load(); got != 1 {
+		t.Fatalf("receiver got %d requests before the delivery was due, want 1", got)
+	}
+
+	delivery = attemptNow(t, w, repo, delivery.ID)
+	if delivery.Attempts != 2 || delivery.ResponseStatus != http.StatusBadGateway {
+		t.Fatalf("after the second attempt: %+v", delivery)
+	}
+	if got := delivery.NextAttemptAt.Sub(delivery.LastAttemptAt); got != 2*webhookInitialBackoff {
+		t.Errorf("next attempt after %s, want %s", got, 2*webhookInitialBackoff)
+	}
+
+	delivery = attemptNow(t, w, repo, delivery.ID)
+	if delivery.Status != entity.DeliverySucceeded || delivery.Attempts != 3 || delivery.LastError != "" {
+		t.Errorf("after the third attempt: %+v", delivery)
+	}
+}
+
+func TestWebhookDeliveryFails(t *testing.T) {
+	w, repo := newTestWebhookService(t, WithWebhookPrivateTargets())
+	rec := newReceiver(t, func(*http.Request, []byte) int { return http.StatusServiceUnavailable })
+	webhook, delivery := queueDelivery(t, w, repo, rec.URL)
+
+	for i := 0; i < webhookMaxAttempts; i++ {
+		delivery = attemptNow(t, w, repo, delivery.ID)
+	}
+	if delivery.Status != entity.DeliveryFailed || delivery.Attempts != webhookMaxAttempts {
+		t.Fatalf("after %d attempts: %+v", webhookMaxAttempts, delivery)
+	}
+	attemptNow(t, w, repo, delivery.ID)
+	if got := rec.requests.Load(); got != webhookMaxAttempts {
+		t.Errorf("receiver got %d requests, want %d", got, webhookMaxAttempts)
+	}
+
+	retried, err := w.RetryWebhookDelivery(&validation.RetryWebhookDeliveryInput{WebhookID: webhook.Webhook.ID, DeliveryID: delivery.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retried.Delivery.Status != entity.DeliveryPending || retried.Delivery.Attempts != 0 {
+		t.Errorf("retried delivery: %+v", retried.Delivery)
+	}
+	if _, err := w.RetryWebhookDelivery(&validation.RetryWebhookDeliveryInput{WebhookID: webhook.Webhook.ID, DeliveryID: delivery.ID}); !errors.Is(err, ErrDeliveryNotFailed) {
+		t.Errorf("retrying a pending delivery: got %v, want ErrDeliveryNotFailed", err)
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 5 * time.Second},
+		{2, 10 * time.Second},
+		{3, 20 * time.Second},
+		{9, 1280 * time.Second},
+		{10, 2560 * time.Second},
+		{11, time.Hour},
+		{50, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := webhookBackoff(tt.attempts); got != tt.want {
+			t.Errorf("webhookBackoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestWebhookPrivateTargets(t *testing.T) {
+	w, repo := newTestWebhookService(t)
+
+	for _, url := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://localhost/hook",
+		"http://LOCALHOST./hook",
+		"http://api.localhost/hook",
+		"http://[::1]/hook",
+		"http://0.0.0.0/hook",
+		"http://10.1.2.3/hook",
+		"http://172.16.0.1/hook",
+		"http://192.168.0.1/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[fe80::1]/hook",
+		"http://[fd00::1]/hook",
+		"http://[::ffff:127.0.0.1]/hook",
+	} {
+		if _, err := w.CreateWebhook(&validation.CreateWebhookInput{URL: url}); !errors.Is(err, ErrPrivateWebhookTarget) {
+			t.Errorf("%s: got %v, want ErrPrivateWebhookTarget", url, err)
+		}
+	}
+	for _, url := range []string{"https://example.com/hook", "http://93.184.216.34/hook"} {
+		if _, err := w.CreateWebhook(&validation.CreateWebhookInput{URL: url}); err != nil {
+			t.Errorf("%s: %v", url, err)
+		}
+	}
+
+	// Host names are only resolved for a delivery, which refuses private
+	// addresses as well.
+	rec := newReceiver(t, func(*http.Request, []byte) int { return http.StatusOK })
+	webhook := &entity.Webhook{ID: "webhook", URL: rec.URL, Secret: "secret", CreatedAt: time.Now().UTC()}
+	if _, err := repo.CreateWebhook(webhook); err != nil {
+		t.Fatal(err)
+	}
+	w.enqueue(events.Event{ID: 1, Type: events.EventDeviceCreated, DeviceID: "device"})
+	deliveries, err := repo.ListWebhookDeliveries(webhook.ID)
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("got %d deliveries (%v), want 1", len(deliveries), err)
+	}
+	delivery := attemptNow(t, w, repo, deliveries[0].ID)
+	if delivery.Status != entity.DeliveryPending || !strings.Contains(delivery.LastError, ErrPrivateWebhookTarget.Error()) {
+		t.Errorf("delivery to a private address: %+v", delivery)
+	}
+	if got := rec.requests.Load(); got != 0 {
+		t.Errorf("receiver got %d requests, want 0", got)
+	}
+}
@@ -0,0 +1,63 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/auth"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/entity"
+)
+
+// CreateAPIKeyInput is the body expected from the CreateAPIKey request.
+// Empty device_ids allow all devices.
+type CreateAPIKeyInput struct {
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+	DeviceIDs   []string `json:"device_ids,omitempty"`
+}
+
+// Validate if CreateAPIKeyInput is correct
+func (c *CreateAPIKeyInput) IsValid() error {
+	if c.Name == "" {
+		return errors.New("name is a required field")
+	}
+	if len(c.Permissions) == 0 {
+		return errors.New("permissions is a required field")
+	}
+	for _, permission := range c.Permissions {
+		if !auth.IsPermission(permission) {
+			return fmt.Errorf("unknown permission: %s", permission)
+		}
+		if permission == string(auth.PermissionAdmin) && len(c.DeviceIDs) > 0 {
+			return errors.New("admin keys cannot be restricted to devices")
+		}
+	}
+	return nil
+}
+
+type GetAPIKeyInput struct {
+	ID string
+}
+
+type RevokeAPIKeyInput struct {
+	ID string
+}
+
+// CreateAPIKeyOutput holds the issued API key and the key itself. The key
+// is only returned on issue.
+type CreateAPIKeyOutput struct {
+	APIKey *entity.APIKey `json:"api_key"`
+	Key    string         `json:"key"`
+}
+
+type GetAPIKeyOutput struct {
+	APIKey *entity.APIKey `json:"api_key"`
+}
+
+type ListAPIKeyOutput struct {
+	APIKey []*entity.APIKey `json:"api_keys"`
+}
+
+type RevokeAPIKeyOutput struct {
+	Status string `json:"status"`
+}
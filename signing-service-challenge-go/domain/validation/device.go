@@ -2,8 +2,12 @@ package validation
 
 import (
 	"errors"
+	"io"
+	"time"
 
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/entity"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/export"
 )
 
 // CreateSignatureDeviceInput is the body expected from the CreateSignatureDevice request
@@ -11,8 +15,23 @@ type CreateSignatureDeviceInput struct {
 	ID        string `json:"id"`
 	Algorithm string `json:"algorithm"`
 	Label     string `json:"label,omitempty"`
+	// Deterministic selects RFC 6979 nonces for ECC devices, making
+	// signatures reproducible.
+	Deterministic bool `json:"deterministic,omitempty"`
+	// SignatureScheme selects the padding of RSA devices: "PSS" (default)
+	// or "PKCS1v15".
+	SignatureScheme string `json:"signature_scheme,omitempty"`
+	// SignatureFormat selects the default encoding of ECC signatures:
+	// "DER" (default) or "PLAIN" (r||s).
+	SignatureFormat string `json:"signature_format,omitempty"`
+	// BatchWindowMS enables batched signing: items submitted within the
+	// window are signed together as the root of a Merkle tree.
+	BatchWindowMS int `json:"batch_window_ms,omitempty"`
 }
 
+// MaxBatchWindowMS bounds how long a batched signing request may wait.
+const MaxBatchWindowMS = 60000
+
 // Validate if CreateSignatureDeviceInput is correct
 func (c *CreateSignatureDeviceInput) IsValid() error {
 	if c.ID == "" || c.Algorithm == "" {
@@ -21,6 +40,28 @@ func (c *CreateSignatureDeviceInput) IsValid() error {
 	if c.Algorithm != "ECC" && c.Algorithm != "RSA" {
 		return errors.New("unsupported algorithm")
 	}
+	if c.Deterministic && c.Algorithm != "ECC" {
+		return errors.New("deterministic signatures are only supported for ECC")
+	}
+	if c.SignatureScheme != "" {
+		if c.Algorithm != "RSA" {
+			return errors.New("signature_scheme is only supported for RSA")
+		}
+		if c.SignatureScheme != crypto.RSASchemePSS && c.SignatureScheme != crypto.RSASchemePKCS1v15 {
+			return errors.New("unsupported signature_scheme")
+		}
+	}
+	if c.SignatureFormat != "" {
+		if c.Algorithm != "ECC" {
+			return errors.New("signature_format is only supported for ECC")
+		}
+		if !isECDSAFormat(c.SignatureFormat) {
+			return errors.New("unsupported signature_format")
+		}
+	}
+	if c.BatchWindowMS < 0 || c.BatchWindowMS > MaxBatchWindowMS {
+		return errors.New("batch_window_ms is out of range")
+	}
 	return nil
 }
 
@@ -28,13 +69,92 @@ func (c *CreateSignatureDeviceInput) IsValid() error {
 type SignTransactionInput struct {
 	DeviceID string `json:"device_id"`
 	Data     []byte `json:"data"`
+	// SignatureFormat overrides the device's ECC signature encoding for
+	// this request.
+	SignatureFormat string `json:"signature_format,omitempty"`
+	// Output requests additional representations of the signed
+	// transaction. "jws" adds a JWS compact serialization, "cose" a
+	// COSE_Sign1 structure.
+	Output string `json:"output,omitempty"`
 }
 
+// Additional representations of a signed transaction.
+const (
+	SignTransactionOutputJWS  = "jws"
+	SignTransactionOutputCOSE = "cose"
+)
+
 // Validate if SignTransactionInput is correct
 func (s *SignTransactionInput) IsValid() error {
 	if s.DeviceID == "" || s.Data == nil {
 		return errors.New("id and algorithm are required fields")
 	}
+	if s.SignatureFormat != "" && !isECDSAFormat(s.SignatureFormat) {
+		return errors.New("unsupported signature_format")
+	}
+	if s.Output != "" && s.Output != SignTransactionOutputJWS && s.Output != SignTransactionOutputCOSE {
+		return errors.New("unsupported output")
+	}
+	return nil
+}
+
+func isECDSAFormat(format string) bool {
+	return format == crypto.ECDSAFormatDER || format == crypto.ECDSAFormatPlain
+}
+
+// SignBatchedInput is the body expected from the SignBatched request
+type SignBatchedInput struct {
+	DeviceID string `json:"device_id"`
+	Data     []byte `json:"data"`
+}
+
+// Validate if SignBatchedInput is correct
+func (s *SignBatchedInput) IsValid() error {
+	if s.DeviceID == "" || s.Data == nil {
+		return errors.New("device_id and data are required fields")
+	}
+	return nil
+}
+
+// SignDocumentInput holds the content to be signed with a detached CMS signature
+type SignDocumentInput struct {
+	DeviceID string
+	Content  io.Reader
+}
+
+// Validate if SignDocumentInput is correct
+func (s *SignDocumentInput) IsValid() error {
+	if s.DeviceID == "" || s.Content == nil {
+		return errors.New("device id and content are required fields")
+	}
+	return nil
+}
+
+// ExportDeviceInput holds the device to export and the writer the archive
+// is streamed to
+type ExportDeviceInput struct {
+	ID     string
+	Writer io.Writer
+}
+
+// Validate if ExportDeviceInput is correct
+func (e *ExportDeviceInput) IsValid() error {
+	if e.ID == "" || e.Writer == nil {
+		return errors.New("device id and writer are required fields")
+	}
+	return nil
+}
+
+// RestoreInput holds an encrypted backup to restore
+type RestoreInput struct {
+	Backup []byte
+}
+
+// Validate if RestoreInput is correct
+func (r *RestoreInput) IsValid() error {
+	if len(r.Backup) == 0 {
+		return errors.New("backup is a required field")
+	}
 	return nil
 }
 
@@ -50,29 +170,203 @@ type GetSignatureDeviceInput struct {
 
 type ListTransactionInput struct {
 	DeviceID string `json:"device_id,omitempty"`
+	// Counter and Limit select a page of the transactions of DeviceID in
+	// counter order. A zero Limit lists all transactions.
+	Counter int `json:"-"`
+	Limit   int `json:"-"`
 }
 
 type GetTransactionInput struct {
 	ID string
 }
 
+type GetDeviceJWKInput struct {
+	ID string
+}
+
+// VerifySignatureInput is the body expected from the VerifySignature request
+type VerifySignatureInput struct {
+	DeviceID  string `json:"-"`
+	Data      []byte `json:"data"`
+	Signature []byte `json:"signature"`
+}
+
+// Validate if VerifySignatureInput is correct
+func (v *VerifySignatureInput) IsValid() error {
+	if v.DeviceID == "" || v.Data == nil || v.Signature == nil {
+		return errors.New("device id, data and signature are required fields")
+	}
+	return nil
+}
+
+// VerifyInclusionInput is the body expected from the VerifyInclusion
+// request. It carries an item and the SignBatchedOutput returned for it.
+type VerifyInclusionInput struct {
+	DeviceID   string   `json:"-"`
+	Data       []byte   `json:"data"`
+	LeafIndex  uint64   `json:"leaf_index"`
+	TreeSize   uint64   `json:"tree_size"`
+	Proof      [][]byte `json:"proof"`
+	Root       []byte   `json:"root"`
+	Signature  []byte   `json:"signature"`
+	SignedData string   `json:"signed_data"`
+}
+
+// Validate if VerifyInclusionInput is correct
+func (v *VerifyInclusionInput) IsValid() error {
+	if v.DeviceID == "" || v.Data == nil || v.Root == nil || v.Signature == nil || v.SignedData == "" {
+		return errors.New("device id, data, root, signature and signed_data are required fields")
+	}
+	if v.TreeSize == 0 {
+		return errors.New("tree_size is required")
+	}
+	return nil
+}
+
+type GetDeviceCertificateInput struct {
+	ID string
+}
+
+type CreateCertificateRequestInput struct {
+	ID string
+}
+
+// UploadDeviceCertificateInput holds a PEM encoded certificate, optionally
+// followed by intermediate certificates, issued for a device.
+type UploadDeviceCertificateInput struct {
+	ID          string
+	Certificate []byte
+}
+
+// Validate if UploadDeviceCertificateInput is correct
+func (u *UploadDeviceCertificateInput) IsValid() error {
+	if u.ID == "" || len(u.Certificate) == 0 {
+		return errors.New("id and certificate are required fields")
+	}
+	return nil
+}
+
 // CreateSignatureDeviceOutput handles which data is returned by the API
 type CreateSignatureDeviceOutput struct {
 	Status string `json:"status"`
 }
 
 type ListSignatureDeviceOutput struct {
-	Device []*entity.Device `json:"devices"`
+	Device []*Device `json:"devices"`
 }
 
 type GetSignatureDeviceOutput struct {
-	Device *entity.Device `json:"device"`
+	Device *Device `json:"device"`
+}
+
+// Device is a signature device as returned by the API: everything but its
+// private key.
+type Device struct {
+	ID               string
+	Label            string
+	Algorithm        string
+	PublicKey        []byte
+	Certificate      []byte
+	Deterministic    bool
+	SignatureScheme  string
+	SignatureFormat  string
+	SignatureCounter int
+	LastSignature    []byte
+	BatchWindow      time.Duration
+}
+
+// NewDevice returns the public part of a device.
+func NewDevice(device *entity.Device) *Device {
+	return &Device{
+		ID:               device.ID,
+		Label:            device.Label,
+		Algorithm:        device.Algorithm,
+		PublicKey:        device.PublicKey,
+		Certificate:      device.Certificate,
+		Deterministic:    device.Deterministic,
+		SignatureScheme:  device.SignatureScheme,
+		SignatureFormat:  device.SignatureFormat,
+		SignatureCounter: device.SignatureCounter,
+		LastSignature:    device.LastSignature,
+		BatchWindow:      device.BatchWindow,
+	}
+}
+
+// NewDevices returns the public part of each device.
+func NewDevices(devices []*entity.Device) []*Device {
+	output := make([]*Device, 0, len(devices))
+	for _, device := range devices {
+		output = append(output, NewDevice(device))
+	}
+	return output
+}
+
+// Entity returns the device as an entity without private key, as needed
+// to verify its signatures.
+func (d *Device) Entity() *entity.Device {
+	return &entity.Device{
+		ID:               d.ID,
+		Label:            d.Label,
+		Algorithm:        d.Algorithm,
+		PublicKey:        d.PublicKey,
+		Certificate:      d.Certificate,
+		Deterministic:    d.Deterministic,
+		SignatureScheme:  d.SignatureScheme,
+		SignatureFormat:  d.SignatureFormat,
+		SignatureCounter: d.SignatureCounter,
+		LastSignature:    d.LastSignature,
+		BatchWindow:      d.BatchWindow,
+	}
 }
 
 // SignTransactionOutput handles which data is returned by the API
 type SignTransactionOutput struct {
-	Transaction string `json:"signature"`
-	SignedData  string `json:"signed_data"`
+	Transaction    string `json:"signature"`
+	SignedData     string `json:"signed_data"`
+	JWS            string `json:"jws,omitempty"`
+	COSE           []byte `json:"cose,omitempty"`
+	TimestampToken []byte `json:"timestamp_token,omitempty"`
+}
+
+// SignBatchedOutput holds the signature over the Merkle root of a batch and
+// the inclusion proof of one item in it.
+type SignBatchedOutput struct {
+	TransactionID  string   `json:"transaction_id"`
+	Signature      string   `json:"signature"`
+	SignedData     string   `json:"signed_data"`
+	Root           []byte   `json:"root"`
+	LeafIndex      uint64   `json:"leaf_index"`
+	TreeSize       uint64   `json:"tree_size"`
+	Proof          [][]byte `json:"proof"`
+	TimestampToken []byte   `json:"timestamp_token,omitempty"`
+}
+
+// SignDocumentOutput holds the DER encoded CMS SignedData and the
+// transaction that recorded the document digest in the signature chain.
+type SignDocumentOutput struct {
+	TransactionID  string `json:"transaction_id"`
+	Signature      string `json:"signature"`
+	SignedData     string `json:"signed_data"`
+	CMS            []byte `json:"cms"`
+	TimestampToken []byte `json:"timestamp_token,omitempty"`
+}
+
+// ExportDeviceOutput holds the manifest of a written device archive.
+type ExportDeviceOutput struct {
+	Manifest *export.Manifest `json:"manifest"`
+}
+
+// BackupOutput holds an encrypted backup and what it contains.
+type BackupOutput struct {
+	Backup       []byte `json:"backup"`
+	Devices      int    `json:"devices"`
+	Transactions int    `json:"transactions"`
+}
+
+// RestoreOutput holds what was restored from a backup.
+type RestoreOutput struct {
+	Devices      int `json:"devices"`
+	Transactions int `json:"transactions"`
 }
 
 type ListTransactionOutput struct {
@@ -82,3 +376,35 @@ type ListTransactionOutput struct {
 type GetTransactionOutput struct {
 	Transaction *entity.Transaction `json:"transaction"`
 }
+
+type GetDeviceJWKOutput struct {
+	JWK *crypto.JWK `json:"jwk"`
+}
+
+type VerifySignatureOutput struct {
+	Valid bool `json:"valid"`
+}
+
+type VerifyInclusionOutput struct {
+	Valid bool `json:"valid"`
+}
+
+// GetDeviceCertificateOutput holds the PEM encoded device certificate
+// followed by the certificate of its issuer, if known.
+type GetDeviceCertificateOutput struct {
+	Certificate []byte
+}
+
+// CreateCertificateRequestOutput holds the PEM encoded PKCS #10 request.
+type CreateCertificateRequestOutput struct {
+	CertificateRequest []byte
+}
+
+type UploadDeviceCertificateOutput struct {
+	Status string `json:"status"`
+}
+
+// GetCACertificateOutput holds the PEM encoded certificate of the built-in CA.
+type GetCACertificateOutput struct {
+	Certificate []byte
+}
@@ -0,0 +1,99 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/entity"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/events"
+)
+
+// CreateWebhookInput is the body expected from the CreateWebhook request.
+// Empty event_types or device_ids subscribe to all events.
+type CreateWebhookInput struct {
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types,omitempty"`
+	DeviceIDs  []string `json:"device_ids,omitempty"`
+}
+
+// Validate if CreateWebhookInput is correct
+func (c *CreateWebhookInput) IsValid() error {
+	if c.URL == "" {
+		return errors.New("url is a required field")
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	for _, eventType := range c.EventTypes {
+		if !isEventType(eventType) {
+			return fmt.Errorf("unknown event type: %s", eventType)
+		}
+	}
+	return nil
+}
+
+func isEventType(eventType string) bool {
+	for _, t := range events.Types {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+type GetWebhookInput struct {
+	ID string
+}
+
+type DeleteWebhookInput struct {
+	ID string
+}
+
+type ListWebhookDeliveryInput struct {
+	WebhookID string
+	Status    string
+}
+
+// Validate if ListWebhookDeliveryInput is correct
+func (l *ListWebhookDeliveryInput) IsValid() error {
+	switch l.Status {
+	case "", entity.DeliveryPending, entity.DeliverySucceeded, entity.DeliveryFailed:
+		return nil
+	default:
+		return errors.New("unsupported status")
+	}
+}
+
+type RetryWebhookDeliveryInput struct {
+	WebhookID  string
+	DeliveryID string
+}
+
+// CreateWebhookOutput holds the registered webhook and the secret that
+// signs its deliveries. The secret is only returned on registration.
+type CreateWebhookOutput struct {
+	Webhook *entity.Webhook `json:"webhook"`
+	Secret  string          `json:"secret"`
+}
+
+type GetWebhookOutput struct {
+	Webhook *entity.Webhook `json:"webhook"`
+}
+
+type ListWebhookOutput struct {
+	Webhook []*entity.Webhook `json:"webhooks"`
+}
+
+type DeleteWebhookOutput struct {
+	Status string `json:"status"`
+}
+
+type ListWebhookDeliveryOutput struct {
+	Delivery []*entity.WebhookDelivery `json:"deliveries"`
+}
+
+type RetryWebhookDeliveryOutput struct {
+	Delivery *entity.WebhookDelivery `json:"delivery"`
+}
@@ -0,0 +1,212 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Event types published by the device service.
+const (
+	EventDeviceCreated     = "device.created"
+	EventTransactionSigned = "transaction.signed"
+)
+
+// Types lists all known event types. Subscriptions and webhooks may only
+// filter by these.
+var Types = []string{EventDeviceCreated, EventTransactionSigned}
+
+// DefaultReplayBuffer is the number of recent events kept for resumption
+// when no size is configured.
+const DefaultReplayBuffer = 1024
+
+// subscriberBuffer is the number of events a subscriber may fall behind
+// before it is disconnected.
+const subscriberBuffer = 256
+
+// Event is a notification about a change of a signature device.
+type Event struct {
+	ID       uint64    `json:"id"`
+	Type     string    `json:"type"`
+	DeviceID string    `json:"device_id"`
+	Time     time.Time `json:"time"`
+	Data     any       `json:"data,omitempty"`
+}
+
+// Filter selects events by device and type. Empty fields match everything.
+type Filter struct {
+	DeviceIDs []string
+	Types     []string
+}
+
+// Matches reports whether the event passes the filter.
+func (f Filter) Matches(event Event) bool {
+	return matchesAny(f.DeviceIDs, event.DeviceID) && matchesAny(f.Types, event.Type)
+}
+
+func matchesAny(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Bus distributes events to subscribers and keeps the most recent events
+// in a bounded buffer so that subscribers can resume after reconnecting.
+type Bus struct {
+	mu          sync.Mutex
+	lastID      uint64
+	replay      []Event
+	next        int
+	full        bool
+	subscribers map[*Subscription]struct{}
+}
+
+// NewBus creates a Bus that keeps the given number of events for replay,
+// or DefaultReplayBuffer if size is not positive.
+func NewBus(size int) *Bus {
+	if size <= 0 {
+		size = DefaultReplayBuffer
+	}
+	return &Bus{
+		replay:      make([]Event, size),
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish assigns the next ID to an event and delivers it to all matching
+// subscribers. Subscribers that cannot keep up are disconnected instead of
+// blocking the publisher; they can resume from the replay buffer.
+func (b *Bus) Publish(eventType, deviceID string, data any) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	event := Event{
+		ID:       b.lastID,
+		Type:     eventType,
+		DeviceID: deviceID,
+		Time:     time.Now().UTC(),
+		Data:     data,
+	}
+
+	b.replay[b.next] = event
+	b.next = (b.next + 1) % len(b.replay)
+	if b.next == 0 {
+		b.full = true
+	}
+
+	for subscription := range b.subscribers {
+		if !subscription.filter.Matches(event) {
+			continue
+		}
+		select {
+		case subscription.events <- event:
+		default:
+			b.unsubscribe(subscription)
+		}
+	}
+
+	return event
+}
+
+// Subscription receives the events of a Bus that match its filter.
+type Subscription struct {
+	bus    *Bus
+	filter Filter
+	events chan Event
+}
+
+// Events returns the channel of live events. It is closed when the
+// subscription ends, either by Close or because the subscriber fell behind.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close ends the subscription.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.unsubscribe(s)
+}
+
+// Subscribe registers a subscriber for events matching the filter. If
+// resume is set, the buffered events after lastEventID that match the
+// filter are returned for replay; live events start right after them.
+// complete is false if events after lastEventID were already evicted from
+// the replay buffer.
+func (b *Bus) Subscribe(filter Filter, lastEventID uint64, resume bool) (subscription *Subscription, replay []Event, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	complete = true
+	if resume {
+		replay, complete = b.since(lastEventID, filter)
+	}
+
+	subscription = &Subscription{
+		bus:    b,
+		filter: filter,
+		events: make(chan Event, subscriberBuffer),
+	}
+	b.subscribers[subscription] = struct{}{}
+
+	return subscription, replay, complete
+}
+
+// Subscribers returns the number of active subscriptions.
+func (b *Bus) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers)
+}
+
+// since returns the buffered events after id. The caller must hold mu.
+func (b *Bus) since(id uint64, filter Filter) ([]Event, bool) {
+	buffered := b.replay[:b.next]
+	if b.full {
+		buffered = append(append([]Event(nil), b.replay[b.next:]...), b.replay[:b.next]...)
+	}
+
+	// An ID the bus has not issued yet comes from before a restart, so
+	// everything that is still buffered is new to the subscriber.
+	stale := id > b.lastID
+	if stale {
+		id = 0
+	}
+
+	complete := !stale && (len(buffered) == 0 || buffered[0].ID <= id+1)
+	var events []Event
+	for _, event := range buffered {
+		if event.ID > id && filter.Matches(event) {
+			events = append(events, event)
+		}
+	}
+	return events, complete
+}
+
+// unsubscribe removes a subscription. The caller must hold mu.
+func (b *Bus) unsubscribe(subscription *Subscription) {
+	if _, ok := b.subscribers[subscription]; !ok {
+		return
+	}
+	delete(b.subscribers, subscription)
+	close(subscription.events)
+}
+
+// DeviceCreated is the data of EventDeviceCreated.
+type DeviceCreated struct {
+	Algorithm string `json:"algorithm"`
+	Label     string `json:"label,omitempty"`
+}
+
+// TransactionSigned is the data of EventTransactionSigned.
+type TransactionSigned struct {
+	TransactionID    string `json:"transaction_id"`
+	SignatureCounter int    `json:"signature_counter"`
+	Signature        []byte `json:"signature"`
+}
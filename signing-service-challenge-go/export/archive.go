@@ -0,0 +1,283 @@
+package export
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/entity"
+)
+
+// Names of the files of a device archive, in archive order. The certificate
+// is only present if the device has one.
+const (
+	DeviceFile            = "device.json"
+	PublicKeyFile         = "public_key.pem"
+	CertificateFile       = "certificate.pem"
+	TransactionsCSVFile   = "transactions.csv"
+	TransactionsJSONFile  = "transactions.json"
+	ManifestFile          = "manifest.json"
+	ManifestSignatureFile = "manifest.sig"
+)
+
+// transactionsCSVHeader names the columns of transactions.csv. Binary
+// values are base64 encoded.
+var transactionsCSVHeader = []string{
+	"signature_counter",
+	"id",
+	"signed_at",
+	"data",
+	"last_signature",
+	"signature",
+	"signed_data",
+	"timestamp_token",
+}
+
+// Manifest lists the checksums of all files of a device archive. It is
+// signed with the device key; the signature is stored in manifest.sig.
+type Manifest struct {
+	Version          int             `json:"version"`
+	DeviceID         string          `json:"device_id"`
+	Algorithm        string          `json:"algorithm"`
+	SignatureCounter int             `json:"signature_counter"`
+	ExportedAt       time.Time       `json:"exported_at"`
+	Files            []ManifestEntry `json:"files"`
+}
+
+// ManifestEntry is the size and hex encoded SHA-256 checksum of one file.
+type ManifestEntry struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// TransactionSource returns the transaction of a device with the given
+// signature counter.
+type TransactionSource func(counter int) (*entity.Transaction, error)
+
+// WriteArchive writes the TAR archive of a device: its metadata, public key
+// and certificate, its transactions 0 to device.SignatureCounter-1 as CSV
+// and JSON, and a manifest signed by signer. The device must not change
+// while the archive is written, so callers pass a copy.
+//
+// TAR headers carry the file size, so the transaction files are rendered
+// twice, once to measure and once to write them. Transactions are fetched
+// from source one at a time and the history is never held in memory.
+func WriteArchive(w io.Writer, device *entity.Device, source TransactionSource, signer crypto.Signer) (*Manifest, error) {
+	a := &archiveWriter{
+		tar: tar.NewWriter(w),
+		manifest: &Manifest{
+			Version:          BundleVersion,
+			DeviceID:         device.ID,
+			Algorithm:        device.Algorithm,
+			SignatureCounter: device.SignatureCounter,
+			ExportedAt:       time.Now().UTC(),
+			Files:            []ManifestEntry{},
+		},
+	}
+
+	deviceJSON, err := json.MarshalIndent(NewDevice(device), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := a.addFile(DeviceFile, append(deviceJSON, '\n')); err != nil {
+		return nil, err
+	}
+	if err := a.addFile(PublicKeyFile, device.PublicKey); err != nil {
+		return nil, err
+	}
+	if len(device.Certificate) > 0 {
+		if err := a.addFile(CertificateFile, device.Certificate); err != nil {
+			return nil, err
+		}
+	}
+
+	transactions := func(counter int) (Transaction, error) {
+		transaction, err := source(counter)
+		if err != nil {
+			return Transaction{}, err
+		}
+		return NewTransaction(transaction), nil
+	}
+	err = a.addStream(TransactionsCSVFile, func(w io.Writer) error {
+		return writeTransactionsCSV(w, device.SignatureCounter, transactions)
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = a.addStream(TransactionsJSONFile, func(w io.Writer) error {
+		return writeTransactionsJSON(w, device.SignatureCounter, transactions)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	manifestJSON, err := json.MarshalIndent(a.manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	manifestJSON = append(manifestJSON, '\n')
+	signature, err := signer.Sign(manifestJSON)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.writeFile(ManifestFile, manifestJSON); err != nil {
+		return nil, err
+	}
+	if err := a.writeFile(ManifestSignatureFile, signature); err != nil {
+		return nil, err
+	}
+
+	return a.manifest, a.tar.Close()
+}
+
+// archiveWriter writes files to a TAR archive and records them in the
+// manifest.
+type archiveWriter struct {
+	tar      *tar.Writer
+	manifest *Manifest
+}
+
+// addFile writes a file and records it in the manifest.
+func (a *archiveWriter) addFile(name string, content []byte) error {
+	return a.add(name, int64(len(content)), func(w io.Writer) error {
+		_, err := w.Write(content)
+		return err
+	})
+}
+
+// addStream measures the output of render, then writes it as a file and
+// records it in the manifest. render must produce the same output twice.
+func (a *archiveWriter) addStream(name string, render func(io.Writer) error) error {
+	counter := &countingWriter{}
+	if err := render(counter); err != nil {
+		return err
+	}
+	return a.add(name, counter.n, render)
+}
+
+func (a *archiveWriter) add(name string, size int64, render func(io.Writer) error) error {
+	if err := a.writeHeader(name, size); err != nil {
+		return err
+	}
+	hash := sha256.New()
+	if err := render(io.MultiWriter(a.tar, hash)); err != nil {
+		return err
+	}
+	// Flush fails if render wrote fewer bytes than measured.
+	if err := a.tar.Flush(); err != nil {
+		return err
+	}
+	a.manifest.Files = append(a.manifest.Files, ManifestEntry{
+		Name:   name,
+		Size:   size,
+		SHA256: hex.EncodeToString(hash.Sum(nil)),
+	})
+	return nil
+}
+
+// writeFile writes a file without recording it in the manifest.
+func (a *archiveWriter) writeFile(name string, content []byte) error {
+	if err := a.writeHeader(name, int64(len(content))); err != nil {
+		return err
+	}
+	_, err := a.tar.Write(content)
+	return err
+}
+
+func (a *archiveWriter) writeHeader(name string, size int64) error {
+	return a.tar.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0o644,
+		Size:     size,
+		ModTime:  a.manifest.ExportedAt,
+	})
+}
+
+// countingWriter discards its input and counts the bytes.
+type countingWriter struct {
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
+
+// writeTransactionsCSV writes count transactions, fetched by counter, as
+// CSV with a header row.
+func writeTransactionsCSV(w io.Writer, count int, transactions func(counter int) (Transaction, error)) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(transactionsCSVHeader); err != nil {
+		return err
+	}
+	for counter := 0; counter < count; counter++ {
+		transaction, err := transactions(counter)
+		if err != nil {
+			return err
+		}
+		err = writer.Write([]string{
+			strconv.Itoa(transaction.SignatureCounter),
+			transaction.ID,
+			transaction.SignedAt.Format(time.RFC3339Nano),
+			base64.StdEncoding.EncodeToString(transaction.Data),
+			base64.StdEncoding.EncodeToString(transaction.LastSignature),
+			base64.StdEncoding.EncodeToString(transaction.Signature),
+			transaction.SignedData,
+			base64.StdEncoding.EncodeToString(transaction.TimestampToken),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// writeTransactionsJSON writes count transactions, fetched by counter, as
+// JSON array with one transaction per line.
+func writeTransactionsJSON(w io.Writer, count int, transactions func(counter int) (Transaction, error)) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+	for counter := 0; counter < count; counter++ {
+		transaction, err := transactions(counter)
+		if err != nil {
+			return err
+		}
+		line, err := json.Marshal(transaction)
+		if err != nil {
+			return err
+		}
+		separator := ",\n"
+		if counter == 0 {
+			separator = "\n"
+		}
+		if _, err := io.WriteString(w, separator); err != nil {
+			return err
+		}
+		if _, err := w.Write(line); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "\n]\n")
+	return err
+}
+
+// renderTransactions renders transactions with one of the writers above.
+func renderTransactions(write func(io.Writer, int, func(int) (Transaction, error)) error, transactions []Transaction) ([]byte, error) {
+	var buf bytes.Buffer
+	err := write(&buf, len(transactions), func(counter int) (Transaction, error) {
+		return transactions[counter], nil
+	})
+	return buf.Bytes(), err
+}
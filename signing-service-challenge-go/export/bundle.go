@@ -0,0 +1,125 @@
+// Package export defines the device bundle and the device archive handed to
+// auditors: the public part of a signature device and its complete
+// signature chain.
+package export
+
+import (
+	"sort"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/entity"
+)
+
+// BundleVersion is the version of the bundle format written by NewBundle.
+const BundleVersion = 1
+
+// Bundle is an exported signature device with its transactions in counter
+// order.
+type Bundle struct {
+	Version      int           `json:"version"`
+	ExportedAt   time.Time     `json:"exported_at"`
+	Device       Device        `json:"device"`
+	Transactions []Transaction `json:"transactions"`
+}
+
+// Device is the public part of a signature device. PublicKey and
+// Certificate are PEM encoded.
+type Device struct {
+	ID               string `json:"id"`
+	Label            string `json:"label,omitempty"`
+	Algorithm        string `json:"algorithm"`
+	SignatureScheme  string `json:"signature_scheme,omitempty"`
+	SignatureFormat  string `json:"signature_format,omitempty"`
+	SignatureCounter int    `json:"signature_counter"`
+	PublicKey        string `json:"public_key"`
+	Certificate      string `json:"certificate,omitempty"`
+}
+
+// Transaction is one link of the signature chain. SignedData is the string
+// that was signed, <signature_counter>_<data>_<last_signature_base64_encoded>;
+// verifiers must recompute it from the other fields rather than trust it.
+type Transaction struct {
+	ID               string    `json:"id"`
+	SignatureCounter int       `json:"signature_counter"`
+	Data             []byte    `json:"data"`
+	LastSignature    []byte    `json:"last_signature"`
+	Signature        []byte    `json:"signature"`
+	SignedData       string    `json:"signed_data"`
+	SignedAt         time.Time `json:"signed_at"`
+	TimestampToken   []byte    `json:"timestamp_token,omitempty"`
+}
+
+// NewBundle exports a device and its transactions. The private key of the
+// device is never exported.
+func NewBundle(device *entity.Device, transactions []*entity.Transaction) *Bundle {
+	bundle := &Bundle{
+		Version:      BundleVersion,
+		ExportedAt:   time.Now().UTC(),
+		Device:       NewDevice(device),
+		Transactions: make([]Transaction, 0, len(transactions)),
+	}
+
+	for _, transaction := range transactions {
+		bundle.Transactions = append(bundle.Transactions, NewTransaction(transaction))
+	}
+	sort.Slice(bundle.Transactions, func(i, j int) bool {
+		return bundle.Transactions[i].SignatureCounter < bundle.Transactions[j].SignatureCounter
+	})
+	return bundle
+}
+
+// NewDevice exports the public part of a device.
+func NewDevice(device *entity.Device) Device {
+	return Device{
+		ID:               device.ID,
+		Label:            device.Label,
+		Algorithm:        device.Algorithm,
+		SignatureScheme:  device.SignatureScheme,
+		SignatureFormat:  device.SignatureFormat,
+		SignatureCounter: device.SignatureCounter,
+		PublicKey:        string(device.PublicKey),
+		Certificate:      string(device.Certificate),
+	}
+}
+
+// NewTransaction exports a single transaction.
+func NewTransaction(transaction *entity.Transaction) Transaction {
+	return Transaction{
+		ID:               transaction.ID,
+		SignatureCounter: transaction.SignatureCounter,
+		Data:             transaction.Data,
+		LastSignature:    transaction.LastSignatureID,
+		Signature:        transaction.Signature,
+		SignedData:       transaction.SecuredData(),
+		SignedAt:         transaction.SignedAt,
+		TimestampToken:   transaction.TimestampToken,
+	}
+}
+
+// Entity returns the device as entity, without private key.
+func (d Device) Entity() *entity.Device {
+	return &entity.Device{
+		ID:               d.ID,
+		Label:            d.Label,
+		Algorithm:        d.Algorithm,
+		PublicKey:        []byte(d.PublicKey),
+		Certificate:      []byte(d.Certificate),
+		SignatureScheme:  d.SignatureScheme,
+		SignatureFormat:  d.SignatureFormat,
+		SignatureCounter: d.SignatureCounter,
+	}
+}
+
+// Entity returns the transaction of the device with the given ID as entity.
+func (t Transaction) Entity(deviceID string) *entity.Transaction {
+	return &entity.Transaction{
+		ID:               t.ID,
+		DeviceID:         deviceID,
+		SignatureCounter: t.SignatureCounter,
+		Data:             t.Data,
+		LastSignatureID:  t.LastSignature,
+		Signature:        t.Signature,
+		SignedAt:         t.SignedAt,
+		TimestampToken:   t.TimestampToken,
+	}
+}
@@ -0,0 +1,266 @@
+package export
+
+import (
+	"archive/tar"
+	"bytes"
+	gocrypto "crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/timestamp"
+)
+
+// Report is the result of verifying a bundle.
+type Report struct {
+	DeviceID     string              `json:"device_id"`
+	Algorithm    string              `json:"algorithm"`
+	Transactions []TransactionResult `json:"transactions"`
+	// Errors lists problems of the bundle as a whole, e.g. a device key
+	// that cannot be parsed or missing transactions.
+	Errors []string `json:"errors,omitempty"`
+	Valid  bool     `json:"valid"`
+}
+
+// TransactionResult is the verification result of one transaction.
+type TransactionResult struct {
+	ID               string `json:"id"`
+	SignatureCounter int    `json:"signature_counter"`
+	// SignedData is the recomputed signed string.
+	SignedData string `json:"signed_data"`
+	// Timestamp is the time of the time-stamp token, if there is one.
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+	Errors    []string   `json:"errors,omitempty"`
+	Valid     bool       `json:"valid"`
+}
+
+// SignedData returns the string a device signs for a transaction:
+// <signature_counter>_<data>_<last_signature_base64_encoded>.
+func SignedData(signatureCounter int, data, lastSignature []byte) string {
+	return fmt.Sprintf("%d_%s_%s", signatureCounter, data, base64.StdEncoding.EncodeToString(lastSignature))
+}
+
+// Verify checks a bundle without trusting any derived value in it. For
+// every transaction it recomputes the signed string from counter, data
+// and last signature and verifies the signature with the public key of
+// the device. It checks that counters start at zero without gaps, that
+// the first transaction is chained to the device ID and every further one
+// to the signature of its predecessor, and that the bundle holds as many
+// transactions as the device counter says. Time-stamp tokens must cover
+// the signature and a certificate, if any, must certify the device key.
+// Time-stamp and certificate signatures are not verified, as no trust
+// anchors are known offline.
+func Verify(bundle *Bundle) *Report {
+	report := &Report{
+		DeviceID:     bundle.Device.ID,
+		Algorithm:    bundle.Device.Algorithm,
+		Transactions: make([]TransactionResult, 0, len(bundle.Transactions)),
+	}
+
+	device := bundle.Device.Entity()
+	verifier, err := crypto.NewVerifier(device)
+	if err == nil {
+		_, err = crypto.UnmarshalPublicKey(device.PublicKey)
+	}
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("device public key: %v", err))
+		verifier = nil
+	}
+	if bundle.Device.Certificate != "" {
+		if err := checkCertificate(bundle.Device.Certificate, device.PublicKey); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("device certificate: %v", err))
+		}
+	}
+	if bundle.Device.SignatureCounter != len(bundle.Transactions) {
+		report.Errors = append(report.Errors, fmt.Sprintf("device signature counter is %d, but the bundle holds %d transactions",
+			bundle.Device.SignatureCounter, len(bundle.Transactions)))
+	}
+
+	valid := len(report.Errors) == 0
+	lastSignature := []byte(bundle.Device.ID)
+	for i, transaction := range bundle.Transactions {
+		result := TransactionResult{
+			ID:               transaction.ID,
+			SignatureCounter: transaction.SignatureCounter,
+			SignedData:       SignedData(transaction.SignatureCounter, transaction.Data, transaction.LastSignature),
+		}
+		fail := func(format string, args ...any) {
+			result.Errors = append(result.Errors, fmt.Sprintf(format, args...))
+		}
+
+		if transaction.SignatureCounter != i {
+			fail("expected signature counter %d", i)
+		}
+		if !bytes.Equal(transaction.LastSignature, lastSignature) {
+			if i == 0 {
+				fail("not chained to the device ID")
+			} else {
+				fail("not chained to the signature of transaction %d", i-1)
+			}
+		}
+		if transaction.SignedData != "" && transaction.SignedData != result.SignedData {
+			fail("signed_data does not match the recomputed signed data")
+		}
+		if verifier != nil {
+			if err := verifier.Verify([]byte(result.SignedData), transaction.Signature); err != nil {
+				fail("signature: %v", err)
+			}
+		}
+		if len(transaction.TimestampToken) > 0 {
+			digest := sha256.Sum256(transaction.Signature)
+			token, err := timestamp.ParseToken(transaction.TimestampToken, digest[:], gocrypto.SHA256)
+			if err != nil {
+				fail("time-stamp token: %v", err)
+			} else {
+				result.Timestamp = &token.Time
+			}
+		}
+
+		result.Valid = len(result.Errors) == 0
+		valid = valid && result.Valid
+		report.Transactions = append(report.Transactions, result)
+		lastSignature = transaction.Signature
+	}
+
+	report.Valid = valid
+	return report
+}
+
+// checkCertificate checks that the first certificate of a PEM chain
+// certifies the given PEM public key.
+func checkCertificate(chain string, publicKey []byte) error {
+	block, _ := pem.Decode([]byte(chain))
+	if block == nil {
+		return crypto.ErrNoPEMBlock
+	}
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return err
+	}
+	key, err := crypto.UnmarshalPublicKey(publicKey)
+	if err != nil {
+		return err
+	}
+	if equal, ok := key.(interface{ Equal(gocrypto.PublicKey) bool }); !ok || !equal.Equal(certificate.PublicKey) {
+		return fmt.Errorf("certificate does not match the device key")
+	}
+	return nil
+}
+
+// VerifyArchive checks a device archive written by WriteArchive. It checks
+// the manifest signature against the device key, the size and checksum of
+// every file listed in the manifest, that no file is missing from it and
+// that the CSV and key files agree with device.json and transactions.json.
+// The device and its transactions are then checked like a bundle. An error
+// is returned only if the archive cannot be read.
+func VerifyArchive(r io.Reader) (*Report, error) {
+	type file struct {
+		content []byte
+		size    int64
+		sha256  string
+	}
+	files := map[string]*file{}
+	var names []string
+
+	archive := tar.NewReader(r)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		content, err := io.ReadAll(archive)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", header.Name, err)
+		}
+		checksum := sha256.Sum256(content)
+		files[header.Name] = &file{content: content, size: int64(len(content)), sha256: hex.EncodeToString(checksum[:])}
+		names = append(names, header.Name)
+	}
+
+	for _, name := range []string{DeviceFile, TransactionsJSONFile, ManifestFile, ManifestSignatureFile} {
+		if files[name] == nil {
+			return nil, fmt.Errorf("archive has no %s", name)
+		}
+	}
+	bundle := &Bundle{}
+	if err := json.Unmarshal(files[DeviceFile].content, &bundle.Device); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", DeviceFile, err)
+	}
+	if err := json.Unmarshal(files[TransactionsJSONFile].content, &bundle.Transactions); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", TransactionsJSONFile, err)
+	}
+	manifest := &Manifest{}
+	if err := json.Unmarshal(files[ManifestFile].content, manifest); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", ManifestFile, err)
+	}
+	if manifest.Version != BundleVersion {
+		return nil, fmt.Errorf("unsupported archive version %d", manifest.Version)
+	}
+	bundle.Version = manifest.Version
+	bundle.ExportedAt = manifest.ExportedAt
+
+	var problems []string
+	fail := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	device := bundle.Device.Entity()
+	if verifier, err := crypto.NewVerifier(device); err == nil {
+		if err := verifier.Verify(files[ManifestFile].content, files[ManifestSignatureFile].content); err != nil {
+			fail("%s: %v", ManifestSignatureFile, err)
+		}
+	}
+	if manifest.DeviceID != bundle.Device.ID {
+		fail("manifest is for device %s, not %s", manifest.DeviceID, bundle.Device.ID)
+	}
+	if manifest.SignatureCounter != bundle.Device.SignatureCounter {
+		fail("manifest signature counter is %d, but the device's is %d", manifest.SignatureCounter, bundle.Device.SignatureCounter)
+	}
+
+	listed := map[string]bool{ManifestFile: true, ManifestSignatureFile: true}
+	for _, entry := range manifest.Files {
+		listed[entry.Name] = true
+		f := files[entry.Name]
+		switch {
+		case f == nil:
+			fail("%s is listed in the manifest but missing", entry.Name)
+		case f.size != entry.Size:
+			fail("%s has %d bytes, the manifest says %d", entry.Name, f.size, entry.Size)
+		case f.sha256 != entry.SHA256:
+			fail("%s does not match its checksum in the manifest", entry.Name)
+		}
+	}
+	for _, name := range names {
+		if !listed[name] {
+			fail("%s is not listed in the manifest", name)
+		}
+	}
+
+	if f := files[PublicKeyFile]; f == nil || string(f.content) != bundle.Device.PublicKey {
+		fail("%s does not match %s", PublicKeyFile, DeviceFile)
+	}
+	if f := files[CertificateFile]; (f == nil && bundle.Device.Certificate != "") || (f != nil && string(f.content) != bundle.Device.Certificate) {
+		fail("%s does not match %s", CertificateFile, DeviceFile)
+	}
+	if csv, err := renderTransactions(writeTransactionsCSV, bundle.Transactions); err != nil || files[TransactionsCSVFile] == nil || !bytes.Equal(csv, files[TransactionsCSVFile].content) {
+		fail("%s does not match %s", TransactionsCSVFile, TransactionsJSONFile)
+	}
+
+	report := Verify(bundle)
+	report.Errors = append(problems, report.Errors...)
+	report.Valid = report.Valid && len(problems) == 0
+	return report, nil
+}
@@ -2,6 +2,30 @@ module github.com/fiskaly/coding-challenges/signing-service-challenge
 
 go 1.20
 
-require github.com/google/uuid v1.3.1
+require (
+	filippo.io/bigmod v0.0.1
+	filippo.io/nistec v0.0.3
+	github.com/fxamacker/cbor/v2 v2.9.4
+	github.com/getkin/kin-openapi v0.120.0
+	github.com/google/uuid v1.3.1
+	github.com/gorilla/mux v1.8.0
+	google.golang.org/grpc v1.58.3
+	google.golang.org/protobuf v1.31.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
-require github.com/gorilla/mux v1.8.0 // indirect
+require (
+	github.com/go-openapi/jsonpointer v0.19.6 // indirect
+	github.com/go-openapi/swag v0.22.4 // indirect
+	github.com/golang/protobuf v1.5.3 // indirect
+	github.com/invopop/yaml v0.2.0 // indirect
+	github.com/josharian/intern v1.0.0 // indirect
+	github.com/mailru/easyjson v0.7.7 // indirect
+	github.com/mohae/deepcopy v0.0.0-20170929034955-c48cc78d4826 // indirect
+	github.com/perimeterx/marshmallow v1.1.5 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
+	golang.org/x/net v0.12.0 // indirect
+	golang.org/x/sys v0.10.0 // indirect
+	golang.org/x/text v0.11.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20230711160842-782d3b101e98 // indirect
+)
@@ -1,19 +1,247 @@
 package main
 
 import (
+	"context"
+	"encoding/asn1"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/api"
-)
-
-const (
-	ListenAddress = ":8080"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/auth"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/backup"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/ca"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/config"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/repository"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain/service"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/events"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/timestamp"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tlsconfig"
 )
 
 func main() {
-	server := api.NewServer(ListenAddress)
+	cfg, err := config.Load("signing-service", os.Args[1:], os.Getenv, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	logger := log.New(os.Stdout, cfg.Log.Prefix, log.LstdFlags)
+	infoLog := logger
+	if cfg.Log.Level == config.LogLevelError {
+		infoLog = log.New(io.Discard, "", 0)
+	}
+
+	db, err := openStorage(cfg.Storage)
+	if err != nil {
+		logger.Fatal("Could not open storage: ", err)
+	}
+	repo := repository.NewRepository(db)
+	bus := events.NewBus(cfg.Events.ReplayBuffer)
+
+	opts, err := deviceServiceOptions(cfg)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	opts = append(opts, service.WithEventBus(bus))
+	deviceSvc := service.NewDeviceService(logger, repo, opts...)
+	migrated, err := deviceSvc.MigrateLegacyKeys()
+	if err != nil {
+		logger.Fatal("Could not migrate device keys: ", err)
+	}
+	if migrated > 0 {
+		infoLog.Printf("migrated %d device keys to PKCS #8 encoding", migrated)
+	}
+	var webhookOpts []service.WebhookOption
+	if cfg.Webhooks.AllowPrivateTargets {
+		webhookOpts = append(webhookOpts, service.WithWebhookPrivateTargets())
+	}
+	webhookSvc := service.NewWebhookService(logger, repo, bus, webhookOpts...)
+	apiKeySvc := service.NewAPIKeyService(repo)
+
+	serverOpts, err := serverOptions(cfg, logger)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	server := api.NewServer(cfg.ListenAddress, deviceSvc, webhookSvc, apiKeySvc, bus, serverOpts...)
+
+	infoLog.Printf("listening on %s", cfg.ListenAddress)
+	if cfg.GRPCAddress != "" {
+		infoLog.Printf("gRPC listening on %s", cfg.GRPCAddress)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- server.Run()
+	}()
+
+	select {
+	case err := <-stopped:
+		logger.Fatal("Could not start server on ", cfg.ListenAddress, ": ", err)
+	case sig := <-signals:
+		infoLog.Printf("received %s, draining requests for up to %s", sig, cfg.ShutdownTimeout)
+	}
+	// A second signal skips the drain.
+	signal.Reset(syscall.SIGINT, syscall.SIGTERM)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Printf("Shutdown incomplete: %v", err)
+	}
+	if err := repo.Close(); err != nil {
+		logger.Fatal("Could not close storage: ", err)
+	}
+	infoLog.Printf("stopped")
+}
+
+// openStorage opens the storage selected by the configuration.
+func openStorage(storage config.Storage) (*persistence.Database, error) {
+	switch storage.Driver {
+	case config.StorageMemory:
+		return persistence.NewDatabase(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", storage.Driver)
+	}
+}
+
+// deviceServiceOptions loads the CA, trust anchors, TSA and backup key of
+// the configuration.
+func deviceServiceOptions(cfg *config.Config) ([]service.Option, error) {
+	opts := []service.Option{service.WithKeyPolicy(cfg.Keys.Algorithms, cfg.Keys.RSABits)}
+
+	if cfg.CA.CertFile != "" {
+		authority, err := ca.LoadAuthority(cfg.CA.CertFile, cfg.CA.KeyFile, cfg.CA.Validity)
+		if err != nil {
+			return nil, fmt.Errorf("could not load certificate authority: %w", err)
+		}
+		opts = append(opts, service.WithCertificateAuthority(authority))
+	}
 
-	if err := server.Run(); err != nil {
-		log.Fatal("Could not start server on ", ListenAddress)
+	if cfg.TrustAnchorsFile != "" {
+		trust, err := ca.LoadTrustStore(cfg.TrustAnchorsFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load trust anchors: %w", err)
+		}
+		opts = append(opts, service.WithTrustAnchors(trust))
+	}
+
+	timestamper, err := loadTimestamper(cfg.TSA)
+	if err != nil {
+		return nil, fmt.Errorf("could not load timestamp authority: %w", err)
+	}
+	if timestamper != nil {
+		opts = append(opts, service.WithTimestamper(timestamper, cfg.TSA.Timeout))
+	}
+
+	if cfg.BackupKeyFile != "" {
+		key, err := backup.LoadKey(cfg.BackupKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load backup key: %w", err)
+		}
+		opts = append(opts, service.WithBackupKey(key))
+	}
+
+	return opts, nil
+}
+
+// clientPrincipals maps the common names of client certificates to their
+// principals.
+func clientPrincipals(clients []config.TLSClient) map[string]*auth.Principal {
+	principals := make(map[string]*auth.Principal, len(clients))
+	for _, client := range clients {
+		principals[client.CommonName] = &auth.Principal{
+			Name:        client.CommonName,
+			Tenant:      client.Tenant,
+			Permissions: auth.ParsePermissions(client.Permissions),
+		}
+	}
+	return principals
+}
+
+// serverOptions configures the API server: gRPC, TLS, API keys, rate
+// limits and logging.
+func serverOptions(cfg *config.Config, logger *log.Logger) ([]api.Option, error) {
+	opts := []api.Option{api.WithLogger(logger)}
+
+	if cfg.GRPCAddress != "" {
+		opts = append(opts, api.WithGRPCAddress(cfg.GRPCAddress))
+	}
+	if cfg.TLS.Enabled() {
+		tlsConfig, err := tlsconfig.New(tlsconfig.Options{
+			CertFile:          cfg.TLS.CertFile,
+			KeyFile:           cfg.TLS.KeyFile,
+			MinVersion:        cfg.TLS.MinVersion,
+			CipherSuites:      cfg.TLS.CipherSuites,
+			ClientCAFile:      cfg.TLS.ClientCAFile,
+			RequireClientCert: cfg.TLS.ClientAuth == config.ClientAuthRequire,
+		}, logger)
+		if err != nil {
+			return nil, fmt.Errorf("could not configure TLS: %w", err)
+		}
+		opts = append(opts, api.WithTLS(tlsConfig))
+	}
+	if len(cfg.TLS.Clients) > 0 {
+		opts = append(opts, api.WithClientCertificates(clientPrincipals(cfg.TLS.Clients)))
+	}
+	if cfg.Auth.Enabled() {
+		opts = append(opts, api.WithAPIKeys(cfg.Auth.KeyHashes()))
+	}
+	if cfg.RateLimit.Enabled() {
+		opts = append(opts, api.WithRateLimit(cfg.RateLimit.RequestsPerSecond, cfg.RateLimit.Burst))
+	}
+	if cfg.Log.Level == config.LogLevelDebug {
+		opts = append(opts, api.WithAccessLog(logger))
+	}
+
+	return opts, nil
+}
+
+func loadTimestamper(tsa config.TSA) (timestamp.Timestamper, error) {
+	if tsa.URL != "" {
+		return timestamp.LoadRemoteAuthority(tsa.URL, tsa.CertFile)
+	}
+	if tsa.CertFile == "" {
+		return nil, nil
+	}
+
+	var policy asn1.ObjectIdentifier
+	if tsa.Policy != "" {
+		var err error
+		if policy, err = parseOID(tsa.Policy); err != nil {
+			return nil, err
+		}
+	}
+
+	return timestamp.LoadLocalAuthority(tsa.CertFile, tsa.KeyFile, policy)
+}
+
+// parseOID parses an object identifier in dotted notation.
+func parseOID(s string) (asn1.ObjectIdentifier, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid object identifier: %q", s)
+	}
+	oid := make(asn1.ObjectIdentifier, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid object identifier: %q", s)
+		}
+		oid[i] = n
 	}
+	return oid, nil
 }
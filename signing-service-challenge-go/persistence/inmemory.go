@@ -7,14 +7,44 @@ import (
 )
 
 type Database struct {
-	Device          map[string]*entity.Device
-	DeviceRWLock    sync.RWMutex
-	Transaction     map[string]*entity.Transaction
-	SignatureRWLock sync.RWMutex
+	Device       map[string]*entity.Device
+	DeviceRWLock sync.RWMutex
+	Transaction  map[string]*entity.Transaction
+	// DeviceTransaction holds the transaction IDs of every device in
+	// signature counter order.
+	DeviceTransaction map[string][]string
+	SignatureRWLock   sync.RWMutex
+	Webhook           map[string]*entity.Webhook
+	WebhookDelivery   map[string]*entity.WebhookDelivery
+	WebhookRWLock     sync.RWMutex
+	APIKey            map[string]*entity.APIKey
+	// APIKeyHash maps the key hashes to the IDs of the API keys.
+	APIKeyHash   map[string]string
+	APIKeyRWLock sync.RWMutex
 }
 
 func NewDatabase() *Database {
 	deviceMap := make(map[string]*entity.Device, 0)
 	signatureMap := make(map[string]*entity.Transaction, 0)
-	return &Database{Device: deviceMap, Transaction: signatureMap}
+	webhookMap := make(map[string]*entity.Webhook, 0)
+	deliveryMap := make(map[string]*entity.WebhookDelivery, 0)
+	deviceSignatureMap := make(map[string][]string, 0)
+	apiKeyMap := make(map[string]*entity.APIKey, 0)
+	apiKeyHashMap := make(map[string]string, 0)
+	return &Database{Device: deviceMap, Transaction: signatureMap, DeviceTransaction: deviceSignatureMap, Webhook: webhookMap, WebhookDelivery: deliveryMap, APIKey: apiKeyMap, APIKeyHash: apiKeyHashMap}
+}
+
+// Close flushes and closes the database. The in-memory database has
+// nothing to flush; Close waits for running writes to complete. Locks are
+// taken in the order the repository takes them.
+func (db *Database) Close() error {
+	db.SignatureRWLock.Lock()
+	defer db.SignatureRWLock.Unlock()
+	db.DeviceRWLock.Lock()
+	defer db.DeviceRWLock.Unlock()
+	db.WebhookRWLock.Lock()
+	defer db.WebhookRWLock.Unlock()
+	db.APIKeyRWLock.Lock()
+	defer db.APIKeyRWLock.Unlock()
+	return nil
 }
@@ -0,0 +1,5 @@
+// Package signingv0 contains the protobuf messages and gRPC service of the
+// signing API.
+package signingv0
+
+//go:generate protoc -I ../.. --go_out=../.. --go_opt=paths=source_relative --go-grpc_out=../.. --go-grpc_opt=paths=source_relative signing/v0/signing.proto
@@ -0,0 +1,363 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: signing/v0/signing.proto
+
+package signingv0
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	DeviceService_CreateSignatureDevice_FullMethodName = "/signing.v0.DeviceService/CreateSignatureDevice"
+	DeviceService_GetSignatureDevice_FullMethodName    = "/signing.v0.DeviceService/GetSignatureDevice"
+	DeviceService_ListSignatureDevices_FullMethodName  = "/signing.v0.DeviceService/ListSignatureDevices"
+	DeviceService_SignTransaction_FullMethodName       = "/signing.v0.DeviceService/SignTransaction"
+	DeviceService_GetTransaction_FullMethodName        = "/signing.v0.DeviceService/GetTransaction"
+	DeviceService_ListTransactions_FullMethodName      = "/signing.v0.DeviceService/ListTransactions"
+	DeviceService_VerifySignature_FullMethodName       = "/signing.v0.DeviceService/VerifySignature"
+)
+
+// DeviceServiceClient is the client API for DeviceService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type DeviceServiceClient interface {
+	CreateSignatureDevice(ctx context.Context, in *CreateSignatureDeviceRequest, opts ...grpc.CallOption) (*SignatureDevice, error)
+	GetSignatureDevice(ctx context.Context, in *GetSignatureDeviceRequest, opts ...grpc.CallOption) (*SignatureDevice, error)
+	ListSignatureDevices(ctx context.Context, in *ListSignatureDevicesRequest, opts ...grpc.CallOption) (*ListSignatureDevicesResponse, error)
+	SignTransaction(ctx context.Context, in *SignTransactionRequest, opts ...grpc.CallOption) (*SignTransactionResponse, error)
+	GetTransaction(ctx context.Context, in *GetTransactionRequest, opts ...grpc.CallOption) (*Transaction, error)
+	// ListTransactions streams the transactions of a device, or of all
+	// devices if no device is given, ordered by device and signature counter.
+	ListTransactions(ctx context.Context, in *ListTransactionsRequest, opts ...grpc.CallOption) (DeviceService_ListTransactionsClient, error)
+	VerifySignature(ctx context.Context, in *VerifySignatureRequest, opts ...grpc.CallOption) (*VerifySignatureResponse, error)
+}
+
+type deviceServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDeviceServiceClient(cc grpc.ClientConnInterface) DeviceServiceClient {
+	return &deviceServiceClient{cc}
+}
+
+func (c *deviceServiceClient) CreateSignatureDevice(ctx context.Context, in *CreateSignatureDeviceRequest, opts ...grpc.CallOption) (*SignatureDevice, error) {
+	out := new(SignatureDevice)
+	err := c.cc.Invoke(ctx, DeviceService_CreateSignatureDevice_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *deviceServiceClient) GetSignatureDevice(ctx context.Context, in *GetSignatureDeviceRequest, opts ...grpc.CallOption) (*SignatureDevice, error) {
+	out := new(SignatureDevice)
+	err := c.cc.Invoke(ctx, DeviceService_GetSignatureDevice_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *deviceServiceClient) ListSignatureDevices(ctx context.Context, in *ListSignatureDevicesRequest, opts ...grpc.CallOption) (*ListSignatureDevicesResponse, error) {
+	out := new(ListSignatureDevicesResponse)
+	err := c.cc.Invoke(ctx, DeviceService_ListSignatureDevices_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *deviceServiceClient) SignTransaction(ctx context.Context, in *SignTransactionRequest, opts ...grpc.CallOption) (*SignTransactionResponse, error) {
+	out := new(SignTransactionResponse)
+	err := c.cc.Invoke(ctx, DeviceService_SignTransaction_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *deviceServiceClient) GetTransaction(ctx context.Context, in *GetTransactionRequest, opts ...grpc.CallOption) (*Transaction, error) {
+	out := new(Transaction)
+	err := c.cc.Invoke(ctx, DeviceService_GetTransaction_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *deviceServiceClient) ListTransactions(ctx context.Context, in *ListTransactionsRequest, opts ...grpc.CallOption) (DeviceService_ListTransactionsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &DeviceService_ServiceDesc.Streams[0], DeviceService_ListTransactions_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &deviceServiceListTransactionsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type DeviceService_ListTransactionsClient interface {
+	Recv() (*Transaction, error)
+	grpc.ClientStream
+}
+
+type deviceServiceListTransactionsClient struct {
+	grpc.ClientStream
+}
+
+func (x *deviceServiceListTransactionsClient) Recv() (*Transaction, error) {
+	m := new(Transaction)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *deviceServiceClient) VerifySignature(ctx context.Context, in *VerifySignatureRequest, opts ...grpc.CallOption) (*VerifySignatureResponse, error) {
+	out := new(VerifySignatureResponse)
+	err := c.cc.Invoke(ctx, DeviceService_VerifySignature_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DeviceServiceServer is the server API for DeviceService service.
+// All implementations must embed UnimplementedDeviceServiceServer
+// for forward compatibility
+type DeviceServiceServer interface {
+	CreateSignatureDevice(context.Context, *CreateSignatureDeviceRequest) (*SignatureDevice, error)
+	GetSignatureDevice(context.Context, *GetSignatureDeviceRequest) (*SignatureDevice, error)
+	ListSignatureDevices(context.Context, *ListSignatureDevicesRequest) (*ListSignatureDevicesResponse, error)
+	SignTransaction(context.Context, *SignTransactionRequest) (*SignTransactionResponse, error)
+	GetTransaction(context.Context, *GetTransactionRequest) (*Transaction, error)
+	// ListTransactions streams the transactions of a device, or of all
+	// devices if no device is given, ordered by device and signature counter.
+	ListTransactions(*ListTransactionsRequest, DeviceService_ListTransactionsServer) error
+	VerifySignature(context.Context, *VerifySignatureRequest) (*VerifySignatureResponse, error)
+	mustEmbedUnimplementedDeviceServiceServer()
+}
+
+// UnimplementedDeviceServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedDeviceServiceServer struct {
+}
+
+func (UnimplementedDeviceServiceServer) CreateSignatureDevice(context.Context, *CreateSignatureDeviceRequest) (*SignatureDevice, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateSignatureDevice not implemented")
+}
+func (UnimplementedDeviceServiceServer) GetSignatureDevice(context.Context, *GetSignatureDeviceRequest) (*SignatureDevice, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSignatureDevice not implemented")
+}
+func (UnimplementedDeviceServiceServer) ListSignatureDevices(context.Context, *ListSignatureDevicesRequest) (*ListSignatureDevicesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSignatureDevices not implemented")
+}
+func (UnimplementedDeviceServiceServer) SignTransaction(context.Context, *SignTransactionRequest) (*SignTransactionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SignTransaction not implemented")
+}
+func (UnimplementedDeviceServiceServer) GetTransaction(context.Context, *GetTransactionRequest) (*Transaction, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTransaction not implemented")
+}
+func (UnimplementedDeviceServiceServer) ListTransactions(*ListTransactionsRequest, DeviceService_ListTransactionsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ListTransactions not implemented")
+}
+func (UnimplementedDeviceServiceServer) VerifySignature(context.Context, *VerifySignatureRequest) (*VerifySignatureResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VerifySignature not implemented")
+}
+func (UnimplementedDeviceServiceServer) mustEmbedUnimplementedDeviceServiceServer() {}
+
+// UnsafeDeviceServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DeviceServiceServer will
+// result in compilation errors.
+type UnsafeDeviceServiceServer interface {
+	mustEmbedUnimplementedDeviceServiceServer()
+}
+
+func RegisterDeviceServiceServer(s grpc.ServiceRegistrar, srv DeviceServiceServer) {
+	s.RegisterService(&DeviceService_ServiceDesc, srv)
+}
+
+func _DeviceService_CreateSignatureDevice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateSignatureDeviceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DeviceServiceServer).CreateSignatureDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DeviceService_CreateSignatureDevice_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DeviceServiceServer).CreateSignatureDevice(ctx, req.(*CreateSignatureDeviceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DeviceService_GetSignatureDevice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSignatureDeviceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DeviceServiceServer).GetSignatureDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DeviceService_GetSignatureDevice_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DeviceServiceServer).GetSignatureDevice(ctx, req.(*GetSignatureDeviceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DeviceService_ListSignatureDevices_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSignatureDevicesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DeviceServiceServer).ListSignatureDevices(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DeviceService_ListSignatureDevices_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DeviceServiceServer).ListSignatureDevices(ctx, req.(*ListSignatureDevicesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DeviceService_SignTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SignTransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DeviceServiceServer).SignTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DeviceService_SignTransaction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DeviceServiceServer).SignTransaction(ctx, req.(*SignTransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DeviceService_GetTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DeviceServiceServer).GetTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DeviceService_GetTransaction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DeviceServiceServer).GetTransaction(ctx, req.(*GetTransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DeviceService_ListTransactions_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListTransactionsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DeviceServiceServer).ListTransactions(m, &deviceServiceListTransactionsServer{stream})
+}
+
+type DeviceService_ListTransactionsServer interface {
+	Send(*Transaction) error
+	grpc.ServerStream
+}
+
+type deviceServiceListTransactionsServer struct {
+	grpc.ServerStream
+}
+
+func (x *deviceServiceListTransactionsServer) Send(m *Transaction) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _DeviceService_VerifySignature_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VerifySignatureRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DeviceServiceServer).VerifySignature(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DeviceService_VerifySignature_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DeviceServiceServer).VerifySignature(ctx, req.(*VerifySignatureRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// DeviceService_ServiceDesc is the grpc.ServiceDesc for DeviceService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var DeviceService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "signing.v0.DeviceService",
+	HandlerType: (*DeviceServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateSignatureDevice",
+			Handler:    _DeviceService_CreateSignatureDevice_Handler,
+		},
+		{
+			MethodName: "GetSignatureDevice",
+			Handler:    _DeviceService_GetSignatureDevice_Handler,
+		},
+		{
+			MethodName: "ListSignatureDevices",
+			Handler:    _DeviceService_ListSignatureDevices_Handler,
+		},
+		{
+			MethodName: "SignTransaction",
+			Handler:    _DeviceService_SignTransaction_Handler,
+		},
+		{
+			MethodName: "GetTransaction",
+			Handler:    _DeviceService_GetTransaction_Handler,
+		},
+		{
+			MethodName: "VerifySignature",
+			Handler:    _DeviceService_VerifySignature_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ListTransactions",
+			Handler:       _DeviceService_ListTransactions_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "signing/v0/signing.proto",
+}
@@ -0,0 +1,189 @@
+package timestamp
+
+import (
+	"context"
+	gocrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/ca"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+)
+
+var (
+	oidECDSAWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidSHA256WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+)
+
+type essCertIDv2 struct {
+	CertHash []byte
+}
+
+type signingCertificateV2 struct {
+	Certs []essCertIDv2
+}
+
+// LocalAuthority is a Time-Stamp Authority that issues tokens itself with
+// a key and certificate from configuration. It works fully offline.
+type LocalAuthority struct {
+	certificate *x509.Certificate
+	key         gocrypto.Signer
+	policy      asn1.ObjectIdentifier
+	now         func() time.Time
+}
+
+// NewLocalAuthority creates a LocalAuthority from a PEM encoded TSA
+// certificate, which must be valid for time stamping, and its PEM encoded
+// private key. If policy is nil, DefaultPolicy is used.
+func NewLocalAuthority(certificatePEM, privateKeyPEM []byte, policy asn1.ObjectIdentifier) (*LocalAuthority, error) {
+	certificate, err := ca.ParseCertificate(certificatePEM)
+	if err != nil {
+		return nil, err
+	}
+	if !hasTimeStampingUsage(certificate) {
+		return nil, errors.New("timestamp: certificate is not valid for time stamping")
+	}
+
+	keyPair, err := crypto.UnmarshalPrivateKey(privateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := keyPair.Private.(gocrypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("timestamp: unsupported key type %T", keyPair.Private)
+	}
+	if _, err := signatureAlgorithm(key); err != nil {
+		return nil, err
+	}
+	if publicKey, ok := certificate.PublicKey.(interface{ Equal(gocrypto.PublicKey) bool }); !ok || !publicKey.Equal(key.Public()) {
+		return nil, errors.New("timestamp: private key does not match certificate")
+	}
+
+	if policy == nil {
+		policy = DefaultPolicy
+	}
+
+	return &LocalAuthority{
+		certificate: certificate,
+		key:         key,
+		policy:      policy,
+		now:         time.Now,
+	}, nil
+}
+
+// LoadLocalAuthority reads the TSA certificate and private key from the given files.
+func LoadLocalAuthority(certificateFile, privateKeyFile string, policy asn1.ObjectIdentifier) (*LocalAuthority, error) {
+	certificatePEM, err := os.ReadFile(certificateFile)
+	if err != nil {
+		return nil, err
+	}
+	privateKeyPEM, err := os.ReadFile(privateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	return NewLocalAuthority(certificatePEM, privateKeyPEM, policy)
+}
+
+// Timestamp issues a TimeStampToken (RFC 3161) over the digest. The token
+// embeds the TSA certificate and identifies it with a signingCertificateV2
+// attribute (RFC 5816). Tokens are issued without blocking, so ctx is only
+// checked before issuing.
+func (a *LocalAuthority) Timestamp(ctx context.Context, digest []byte, hash gocrypto.Hash) (*Token, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	imprint, err := newMessageImprint(digest, hash)
+	if err != nil {
+		return nil, err
+	}
+	return a.issue(imprint, nil)
+}
+
+// issue signs a TSTInfo over the message imprint. A nonce of the request is
+// echoed if it is not nil.
+func (a *LocalAuthority) issue(imprint messageImprint, nonce *big.Int) (*Token, error) {
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	genTime := a.now().UTC().Truncate(time.Second)
+	info, err := asn1.Marshal(tstInfo{
+		Version:        1,
+		Policy:         a.policy,
+		MessageImprint: imprint,
+		SerialNumber:   serialNumber,
+		GenTime:        genTime,
+		Nonce:          nonce,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	certHash := sha256.Sum256(a.certificate.Raw)
+	signingCertificate, err := asn1.Marshal(signingCertificateV2{
+		Certs: []essCertIDv2{{CertHash: certHash[:]}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	algorithm, err := signatureAlgorithm(a.key)
+	if err != nil {
+		return nil, err
+	}
+	infoDigest := sha256.Sum256(info)
+
+	raw, err := crypto.BuildSignedData(crypto.CMSOptions{
+		ContentType:        oidTSTInfo,
+		Content:            info,
+		Digest:             infoDigest[:],
+		DigestAlgorithm:    pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue},
+		SignatureAlgorithm: algorithm,
+		Sign: func(signedAttributes []byte) ([]byte, error) {
+			digest := sha256.Sum256(signedAttributes)
+			return a.key.Sign(rand.Reader, digest[:], gocrypto.SHA256)
+		},
+		Certificate: a.certificate,
+		Attributes: []crypto.CMSAttribute{
+			{Type: oidSigningCertificateV2, Value: signingCertificate},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Token{Raw: raw, Time: genTime}, nil
+}
+
+// signatureAlgorithm returns the CMS signature algorithm of the TSA key,
+// which always signs SHA-256 digests.
+func signatureAlgorithm(key gocrypto.Signer) (pkix.AlgorithmIdentifier, error) {
+	switch key.(type) {
+	case *ecdsa.PrivateKey:
+		return pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA256}, nil
+	case *rsa.PrivateKey:
+		return pkix.AlgorithmIdentifier{Algorithm: oidSHA256WithRSA, Parameters: asn1.NullRawValue}, nil
+	default:
+		return pkix.AlgorithmIdentifier{}, fmt.Errorf("timestamp: unsupported key type %T", key)
+	}
+}
+
+func hasTimeStampingUsage(certificate *x509.Certificate) bool {
+	for _, usage := range certificate.ExtKeyUsage {
+		if usage == x509.ExtKeyUsageTimeStamping {
+			return true
+		}
+	}
+	return false
+}
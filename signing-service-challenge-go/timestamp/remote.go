@@ -0,0 +1,151 @@
+package timestamp
+
+import (
+	"bytes"
+	"context"
+	gocrypto "crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/ca"
+)
+
+// Media types of the RFC 3161 HTTP transport.
+const (
+	contentTypeQuery = "application/timestamp-query"
+	contentTypeReply = "application/timestamp-reply"
+)
+
+// maxResponseSize bounds the size of TSA responses that are read.
+const maxResponseSize = 1 << 20
+
+type timeStampReq struct {
+	Version        int
+	MessageImprint messageImprint
+	Nonce          *big.Int `asn1:"optional"`
+	CertReq        bool     `asn1:"optional,default:false"`
+}
+
+type pkiStatusInfo struct {
+	Status       int
+	StatusString asn1.RawValue  `asn1:"optional"`
+	FailInfo     asn1.BitString `asn1:"optional"`
+}
+
+type timeStampResp struct {
+	Status         pkiStatusInfo
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
+// RemoteAuthority requests time-stamp tokens from an external
+// Time-Stamp Authority over HTTP (RFC 3161, section 3.4).
+type RemoteAuthority struct {
+	url         string
+	certificate *x509.Certificate
+	client      *http.Client
+}
+
+// NewRemoteAuthority creates a RemoteAuthority for the TSA at url. Tokens
+// are verified with the PEM encoded TSA certificate, which must be valid
+// for time stamping.
+func NewRemoteAuthority(url string, certificatePEM []byte) (*RemoteAuthority, error) {
+	certificate, err := ca.ParseCertificate(certificatePEM)
+	if err != nil {
+		return nil, err
+	}
+	if !hasTimeStampingUsage(certificate) {
+		return nil, errors.New("timestamp: certificate is not valid for time stamping")
+	}
+
+	return &RemoteAuthority{
+		url:         url,
+		certificate: certificate,
+		client:      &http.Client{},
+	}, nil
+}
+
+// LoadRemoteAuthority reads the TSA certificate from the given file.
+func LoadRemoteAuthority(url, certificateFile string) (*RemoteAuthority, error) {
+	certificatePEM, err := os.ReadFile(certificateFile)
+	if err != nil {
+		return nil, err
+	}
+	return NewRemoteAuthority(url, certificatePEM)
+}
+
+// Timestamp requests a token over the digest from the TSA and checks that
+// the returned token covers it and is signed by the TSA certificate.
+func (a *RemoteAuthority) Timestamp(ctx context.Context, digest []byte, hash gocrypto.Hash) (*Token, error) {
+	imprint, err := newMessageImprint(digest, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := asn1.Marshal(timeStampReq{
+		Version:        1,
+		MessageImprint: imprint,
+		Nonce:          nonce,
+		CertReq:        true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(request))
+	if err != nil {
+		return nil, err
+	}
+	httpRequest.Header.Set("Content-Type", contentTypeQuery)
+	response, err := a.client.Do(httpRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("timestamp: TSA responded with %s", response.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxResponseSize))
+	if err != nil {
+		return nil, err
+	}
+
+	var reply timeStampResp
+	if _, err := asn1.Unmarshal(body, &reply); err != nil {
+		return nil, err
+	}
+	// 0 is granted, 1 is grantedWithMods.
+	if reply.Status.Status > 1 {
+		return nil, fmt.Errorf("timestamp: TSA rejected the request with status %d", reply.Status.Status)
+	}
+	if len(reply.TimeStampToken.FullBytes) == 0 {
+		return nil, errors.New("timestamp: TSA response does not contain a token")
+	}
+
+	token, err := VerifyToken(reply.TimeStampToken.FullBytes, digest, hash, a.certificate)
+	if err != nil {
+		return nil, err
+	}
+	info, err := parseTSTInfo(token.Raw)
+	if err != nil {
+		return nil, err
+	}
+	if info.Nonce == nil || info.Nonce.Cmp(nonce) != 0 {
+		return nil, errors.New("timestamp: token does not echo the request nonce")
+	}
+
+	return token, nil
+}
@@ -0,0 +1,185 @@
+package timestamp
+
+import (
+	"bytes"
+	"context"
+	gocrypto "crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+)
+
+// Object identifiers used by RFC 3161 and RFC 5816.
+var (
+	oidTSTInfo              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+	oidSigningCertificateV2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 47}
+	oidSHA256               = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384               = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512               = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+	oidSignedData           = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+)
+
+var errUnsupportedHashAlgorithm = errors.New("timestamp: unsupported hash algorithm")
+
+// DefaultPolicy is the TSA policy used by a LocalAuthority when none is
+// configured (anyPolicy, RFC 5280).
+var DefaultPolicy = asn1.ObjectIdentifier{2, 5, 29, 32, 0}
+
+// Timestamper obtains RFC 3161 time-stamp tokens.
+type Timestamper interface {
+	// Timestamp returns a time-stamp token over the digest, which was
+	// computed with hash. It gives up when ctx is done.
+	Timestamp(ctx context.Context, digest []byte, hash gocrypto.Hash) (*Token, error)
+}
+
+// Token is a DER encoded TimeStampToken and the time it asserts.
+type Token struct {
+	Raw  []byte
+	Time time.Time
+}
+
+type messageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint messageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time     `asn1:"generalized"`
+	Accuracy       accuracy      `asn1:"optional"`
+	Ordering       bool          `asn1:"optional,default:false"`
+	Nonce          *big.Int      `asn1:"optional"`
+	TSA            asn1.RawValue `asn1:"optional,tag:0"`
+	Extensions     asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+// accuracy is the deviation of GenTime a TSA may declare. It must be a
+// typed SEQUENCE: as an untagged optional RawValue it would swallow the
+// nonce of tokens without accuracy.
+type accuracy struct {
+	Seconds int `asn1:"optional"`
+	Millis  int `asn1:"optional,tag:0"`
+	Micros  int `asn1:"optional,tag:1"`
+}
+
+func hashAlgorithm(hash gocrypto.Hash) (pkix.AlgorithmIdentifier, error) {
+	var oid asn1.ObjectIdentifier
+	switch hash {
+	case gocrypto.SHA256:
+		oid = oidSHA256
+	case gocrypto.SHA384:
+		oid = oidSHA384
+	case gocrypto.SHA512:
+		oid = oidSHA512
+	default:
+		return pkix.AlgorithmIdentifier{}, errUnsupportedHashAlgorithm
+	}
+	return pkix.AlgorithmIdentifier{Algorithm: oid, Parameters: asn1.NullRawValue}, nil
+}
+
+func newMessageImprint(digest []byte, hash gocrypto.Hash) (messageImprint, error) {
+	algorithm, err := hashAlgorithm(hash)
+	if err != nil {
+		return messageImprint{}, err
+	}
+	if len(digest) != hash.Size() {
+		return messageImprint{}, fmt.Errorf("timestamp: digest must be %d bytes", hash.Size())
+	}
+	return messageImprint{HashAlgorithm: algorithm, HashedMessage: digest}, nil
+}
+
+// ParseToken extracts the TSTInfo of a DER encoded TimeStampToken and checks
+// that it covers the given digest. It does not verify the TSA signature; use
+// VerifyToken when the certificate of the TSA is known.
+func ParseToken(raw []byte, digest []byte, hash gocrypto.Hash) (*Token, error) {
+	info, err := parseTSTInfo(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := info.covers(digest, hash); err != nil {
+		return nil, err
+	}
+	return &Token{Raw: raw, Time: info.GenTime}, nil
+}
+
+// VerifyToken checks that a DER encoded TimeStampToken covers the given
+// digest and was signed with the key of the TSA certificate while the
+// certificate was valid.
+func VerifyToken(raw []byte, digest []byte, hash gocrypto.Hash, certificate *x509.Certificate) (*Token, error) {
+	contentType, content, err := crypto.VerifySignedData(raw, certificate)
+	if err != nil {
+		return nil, fmt.Errorf("timestamp: %w", err)
+	}
+	if !contentType.Equal(oidTSTInfo) {
+		return nil, errors.New("timestamp: token does not contain a TSTInfo")
+	}
+
+	var info tstInfo
+	if _, err := asn1.Unmarshal(content, &info); err != nil {
+		return nil, err
+	}
+	if err := info.covers(digest, hash); err != nil {
+		return nil, err
+	}
+	if info.GenTime.Before(certificate.NotBefore) || info.GenTime.After(certificate.NotAfter) {
+		return nil, errors.New("timestamp: token was issued outside the validity of the TSA certificate")
+	}
+
+	return &Token{Raw: raw, Time: info.GenTime}, nil
+}
+
+// covers checks that the token was issued over the digest.
+func (info *tstInfo) covers(digest []byte, hash gocrypto.Hash) error {
+	expected, err := newMessageImprint(digest, hash)
+	if err != nil {
+		return err
+	}
+	if !info.MessageImprint.HashAlgorithm.Algorithm.Equal(expected.HashAlgorithm.Algorithm) ||
+		!bytes.Equal(info.MessageImprint.HashedMessage, expected.HashedMessage) {
+		return errors.New("timestamp: token does not cover the requested digest")
+	}
+	return nil
+}
+
+func parseTSTInfo(raw []byte) (*tstInfo, error) {
+	var contentInfo struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue `asn1:"tag:0"`
+	}
+	if _, err := asn1.Unmarshal(raw, &contentInfo); err != nil {
+		return nil, err
+	}
+	if !contentInfo.ContentType.Equal(oidSignedData) {
+		return nil, errors.New("timestamp: token is not a SignedData structure")
+	}
+
+	var signedData struct {
+		Version          int
+		DigestAlgorithms asn1.RawValue
+		EncapContentInfo struct {
+			ContentType asn1.ObjectIdentifier
+			Content     []byte `asn1:"explicit,tag:0"`
+		}
+	}
+	if _, err := asn1.Unmarshal(contentInfo.Content.Bytes, &signedData); err != nil {
+		return nil, err
+	}
+	if !signedData.EncapContentInfo.ContentType.Equal(oidTSTInfo) {
+		return nil, errors.New("timestamp: token does not contain a TSTInfo")
+	}
+
+	var info tstInfo
+	if _, err := asn1.Unmarshal(signedData.EncapContentInfo.Content, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
@@ -0,0 +1,201 @@
+package timestamp
+
+import (
+	"context"
+	gocrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestTSA returns the PEM encoded certificate and private key of a TSA.
+func newTestTSA(t *testing.T, key gocrypto.Signer) ([]byte, []byte) {
+	t.Helper()
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Test TSA"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+}
+
+func newTestAuthority(t *testing.T, key gocrypto.Signer) *LocalAuthority {
+	t.Helper()
+	certificatePEM, keyPEM := newTestTSA(t, key)
+	authority, err := NewLocalAuthority(certificatePEM, keyPEM, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return authority
+}
+
+func newECDSAKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestVerifyToken(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := newTestAuthority(t, newECDSAKey(t))
+	digest := sha256.Sum256([]byte("signature"))
+
+	for name, key := range map[string]gocrypto.Signer{"ECDSA": newECDSAKey(t), "RSA": rsaKey} {
+		t.Run(name, func(t *testing.T) {
+			authority := newTestAuthority(t, key)
+			token, err := authority.Timestamp(context.Background(), digest[:], gocrypto.SHA256)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			verified, err := VerifyToken(token.Raw, digest[:], gocrypto.SHA256, authority.certificate)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !verified.Time.Equal(token.Time) {
+				t.Errorf("token time is %s, want %s", verified.Time, token.Time)
+			}
+
+			if _, err := VerifyToken(token.Raw, digest[:], gocrypto.SHA256, other.certificate); err == nil {
+				t.Error("token verified with the certificate of another TSA")
+			}
+			otherDigest := sha256.Sum256([]byte("other"))
+			if _, err := VerifyToken(token.Raw, otherDigest[:], gocrypto.SHA256, authority.certificate); err == nil {
+				t.Error("token verified for another digest")
+			}
+			// The signature is the last field of the token.
+			tampered := append([]byte(nil), token.Raw...)
+			tampered[len(tampered)-1] ^= 0x01
+			if _, err := VerifyToken(tampered, digest[:], gocrypto.SHA256, authority.certificate); err == nil {
+				t.Error("token with a tampered signature verified")
+			}
+
+			if _, err := ParseToken(token.Raw, digest[:], gocrypto.SHA256); err != nil {
+				t.Errorf("ParseToken: %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyTokenOutsideValidity(t *testing.T) {
+	authority := newTestAuthority(t, newECDSAKey(t))
+	authority.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	digest := sha256.Sum256([]byte("signature"))
+	token, err := authority.Timestamp(context.Background(), digest[:], gocrypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyToken(token.Raw, digest[:], gocrypto.SHA256, authority.certificate); err == nil {
+		t.Error("token issued after the certificate expired verified")
+	}
+}
+
+// newTestServer serves RFC 3161 requests with the local authority.
+func newTestServer(t *testing.T, authority *LocalAuthority, delay time.Duration) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		var request timeStampReq
+		if _, err := asn1.Unmarshal(body, &request); err != nil {
+			t.Error(err)
+			return
+		}
+		token, err := authority.issue(request.MessageImprint, request.Nonce)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		reply, err := asn1.Marshal(timeStampResp{TimeStampToken: asn1.RawValue{FullBytes: token.Raw}})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		w.Header().Set("Content-Type", contentTypeReply)
+		w.Write(reply)
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestRemoteAuthority(t *testing.T) {
+	authority := newTestAuthority(t, newECDSAKey(t))
+	digest := sha256.Sum256([]byte("signature"))
+
+	ts := newTestServer(t, authority, 0)
+	remote, err := NewRemoteAuthority(ts.URL, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: authority.certificate.Raw}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := remote.Timestamp(context.Background(), digest[:], gocrypto.SHA256); err != nil {
+		t.Fatal(err)
+	}
+
+	// Tokens of the TSA must not verify with the certificate of another.
+	otherPEM, _ := newTestTSA(t, newECDSAKey(t))
+	untrusted, err := NewRemoteAuthority(ts.URL, otherPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := untrusted.Timestamp(context.Background(), digest[:], gocrypto.SHA256); err == nil {
+		t.Error("token of an untrusted TSA was accepted")
+	}
+}
+
+func TestRemoteAuthorityTimeout(t *testing.T) {
+	authority := newTestAuthority(t, newECDSAKey(t))
+	ts := newTestServer(t, authority, time.Minute)
+	remote, err := NewRemoteAuthority(ts.URL, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: authority.certificate.Raw}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	digest := sha256.Sum256([]byte("signature"))
+	start := time.Now()
+	if _, err := remote.Timestamp(ctx, digest[:], gocrypto.SHA256); err == nil {
+		t.Fatal("slow TSA did not time out")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("timed out after %s", elapsed)
+	}
+}
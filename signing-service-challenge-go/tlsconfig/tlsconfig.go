@@ -0,0 +1,237 @@
+// Package tlsconfig builds the TLS configuration of the service. The server
+// certificate is reloaded from disk when its files change, so certificates
+// can be renewed without a restart.
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// reloadInterval is how often the certificate files are checked for
+// changes. Checks happen during handshakes, so an idle server does no work.
+const reloadInterval = 5 * time.Second
+
+// versions are the supported minimum TLS versions by name.
+var versions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ParseVersion returns the TLS version with the given name, "1.2" or "1.3".
+func ParseVersion(name string) (uint16, error) {
+	version, ok := versions[name]
+	if !ok {
+		return 0, fmt.Errorf("unsupported TLS version %q, supported: 1.2, 1.3", name)
+	}
+	return version, nil
+}
+
+// http2Suites are the cipher suites HTTP/2 requires; a restricted list must
+// contain at least one of them.
+var http2Suites = []uint16{
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+}
+
+// ParseCipherSuites returns the IDs of cipher suites given by their IANA
+// names, e.g. TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256. Suites with known
+// weaknesses are rejected, as are lists without a suite required by HTTP/2.
+// TLS 1.3 suites are not configurable in Go.
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	suites := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		suites[suite.Name] = suite.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := suites[name]
+		if !ok {
+			known := make([]string, 0, len(suites))
+			for name := range suites {
+				known = append(known, name)
+			}
+			sort.Strings(known)
+			return nil, fmt.Errorf("unsupported cipher suite %q, supported: %s", name, strings.Join(known, ", "))
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) > 0 && !containsAny(ids, http2Suites) {
+		return nil, fmt.Errorf("cipher suites must include %s or %s, which HTTP/2 requires",
+			tls.CipherSuiteName(http2Suites[0]), tls.CipherSuiteName(http2Suites[1]))
+	}
+	return ids, nil
+}
+
+func containsAny(ids, wanted []uint16) bool {
+	for _, id := range ids {
+		for _, w := range wanted {
+			if id == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Options configures a server TLS configuration.
+type Options struct {
+	CertFile string
+	KeyFile  string
+	// MinVersion is the minimum TLS version by name, "1.2" if empty.
+	MinVersion string
+	// CipherSuites restricts the TLS 1.2 cipher suites; Go's defaults are
+	// used if empty.
+	CipherSuites []string
+	// ClientCAFile is a PEM bundle of CAs client certificates are verified
+	// against. Without it, clients are not asked for a certificate.
+	ClientCAFile string
+	// RequireClientCert rejects handshakes without a valid client
+	// certificate. Otherwise a certificate is optional, but verified if
+	// presented.
+	RequireClientCert bool
+}
+
+// New returns a server TLS configuration. The certificate is reloaded as
+// described for Reloader; errors are logged to logger.
+func New(opts Options, logger *log.Logger) (*tls.Config, error) {
+	var minVersion uint16 = tls.VersionTLS12
+	if opts.MinVersion != "" {
+		version, err := ParseVersion(opts.MinVersion)
+		if err != nil {
+			return nil, err
+		}
+		minVersion = version
+	}
+	cipherSuites, err := ParseCipherSuites(opts.CipherSuites)
+	if err != nil {
+		return nil, err
+	}
+
+	reloader, err := NewReloader(opts.CertFile, opts.KeyFile, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		MinVersion:     minVersion,
+		GetCertificate: reloader.GetCertificate,
+	}
+	if len(cipherSuites) > 0 {
+		config.CipherSuites = cipherSuites
+	}
+
+	if opts.ClientCAFile != "" {
+		pem, err := os.ReadFile(opts.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("tlsconfig: no certificates in %s", opts.ClientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+		if opts.RequireClientCert {
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+
+	return config, nil
+}
+
+// fileStamp identifies a version of a file.
+type fileStamp struct {
+	modTime int64
+	size    int64
+}
+
+func stat(name string) (fileStamp, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{modTime: info.ModTime().UnixNano(), size: info.Size()}, nil
+}
+
+// Reloader serves a certificate and key pair from files and reloads it when
+// either file changes. If the new files cannot be loaded, e.g. because only
+// one of them has been replaced yet, the previous certificate stays in use.
+type Reloader struct {
+	certFile string
+	keyFile  string
+	logger   *log.Logger
+
+	mu          sync.Mutex
+	certificate *tls.Certificate
+	certStamp   fileStamp
+	keyStamp    fileStamp
+	checked     time.Time
+	failed      bool
+}
+
+// NewReloader loads the certificate and key pair. Unlike reloads, the
+// initial load must succeed.
+func NewReloader(certFile, keyFile string, logger *log.Logger) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile, logger: logger}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	r.checked = time.Now()
+	return r, nil
+}
+
+// GetCertificate returns the current certificate, for tls.Config.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if now := time.Now(); now.Sub(r.checked) >= reloadInterval {
+		r.checked = now
+		if err := r.load(); err != nil {
+			// Log a failed reload once, not on every handshake.
+			if !r.failed {
+				r.logger.Printf("tls: keeping the current certificate, reload failed: %v", err)
+			}
+			r.failed = true
+		} else {
+			r.failed = false
+		}
+	}
+	return r.certificate, nil
+}
+
+// load reads the files if they changed since the last load. The caller must
+// hold mu, unless it is NewReloader.
+func (r *Reloader) load() error {
+	certStamp, err := stat(r.certFile)
+	if err != nil {
+		return err
+	}
+	keyStamp, err := stat(r.keyFile)
+	if err != nil {
+		return err
+	}
+	if r.certificate != nil && certStamp == r.certStamp && keyStamp == r.keyStamp {
+		return nil
+	}
+
+	certificate, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	if r.certificate != nil {
+		r.logger.Printf("tls: reloaded certificate from %s", r.certFile)
+	}
+	r.certificate = &certificate
+	r.certStamp, r.keyStamp = certStamp, keyStamp
+	return nil
+}